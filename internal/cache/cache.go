@@ -68,7 +68,7 @@ func (cache FsCache) Set(ct Type, key string, value io.Reader) error {
 	if errMkdir := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); errMkdir != nil {
 		return errMkdir
 	}
-	of, errOf := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE, 0660)
+	of, errOf := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
 	if errOf != nil {
 		return errOf
 	}
@@ -90,7 +90,9 @@ func (cache FsCache) Get(ct Type, key string, receiver io.Writer) error {
 	if errStat != nil {
 		return ErrCacheExpired
 	}
-	if time.Since(stat.ModTime()) > cache.maxAge {
+	// Lists are revalidated against their source using the cached validators, so they are kept
+	// regardless of age to allow loading them while offline.
+	if ct != TypeLists && time.Since(stat.ModTime()) > cache.maxAge {
 		return ErrCacheExpired
 	}
 	_, errCopy := io.Copy(receiver, of)
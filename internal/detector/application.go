@@ -34,7 +34,6 @@ type BD struct {
 	// TODO
 	// - estimate private steam account ages (find nearby non-private account)
 	// - "unmark" players, overriding any lists that may match
-	// - track rage quits
 	// - install vote fail mod
 	// - wipe map session stats k/d
 	// - track k/d over entire session?
@@ -58,6 +57,15 @@ type BD struct {
 	triggerUpdate      chan any
 	gameStateUpdate    chan updateStateEvent
 	cache              cache.FsCache
+	listStatus         map[string]model.ListStatus
+	listStatusMu       *sync.RWMutex
+	listSources        map[string]bool
+	chatSpam           *chatSpamDetector
+	voiceMutes         *voiceMuteList
+	votes              *voteTracker
+	kicks              *kickScheduler
+	coordinator        *kickCoordinator
+	weaponAnomalies    *weaponAnomalyReports
 	startupTime        time.Time
 	gameHasStartedOnce bool
 	logger             *zap.Logger
@@ -83,6 +91,15 @@ func New(ctx context.Context, logger *zap.Logger, settings *model.Settings, stor
 		triggerUpdate:      make(chan any),
 		gameStateUpdate:    make(chan updateStateEvent, 50),
 		cache:              cache,
+		listStatus:         map[string]model.ListStatus{},
+		listStatusMu:       &sync.RWMutex{},
+		listSources:        map[string]bool{},
+		chatSpam:           newChatSpamDetector(),
+		voiceMutes:         newVoiceMuteList(),
+		votes:              newVoteTracker(),
+		kicks:              newKickScheduler(),
+		coordinator:        newKickCoordinator(),
+		weaponAnomalies:    newWeaponAnomalyReports(),
 		logParser:          newLogParser(logger, logChan, eventChan),
 		startupTime:        time.Now(),
 		gameHasStartedOnce: isRunning,
@@ -149,8 +166,19 @@ func (bd *BD) createLogReader() {
 	bd.logReader = reader
 }
 
+// ExportVoiceBans writes the voice_ban.dt file muting the newest entries with an attribute that has a voice mute
+// policy, along with the players muted by policy during this session
 func (bd *BD) ExportVoiceBans() error {
-	bannedIds := bd.rules.FindNewestEntries(200, bd.settings.GetKickTags())
+	bannedIds := bd.rules.FindNewestEntries(200, policyAttributes(bd.settings.GetPolicies(), model.ActionVoiceMute))
+	known := map[steamid.SID64]bool{}
+	for _, sid64 := range bannedIds {
+		known[sid64] = true
+	}
+	for _, muted := range bd.voiceMutes.all() {
+		if !known[muted] {
+			bannedIds = append(bannedIds, muted)
+		}
+	}
 	if len(bannedIds) == 0 {
 		return nil
 	}
@@ -209,13 +237,14 @@ func (bd *BD) OnUnMark(sid64 steamid.SID64) error {
 	return nil
 }
 
-func (bd *BD) OnMark(sid64 steamid.SID64, attrs []string) error {
+func (bd *BD) OnMark(sid64 steamid.SID64, attrs []string, duration model.MarkDuration) error {
 	bd.gameStateUpdate <- updateStateEvent{
 		kind:   updateMark,
 		source: bd.settings.GetSteamId(),
 		data: updateMarkEvent{
-			target: sid64,
-			attrs:  attrs,
+			target:   sid64,
+			attrs:    attrs,
+			duration: duration,
 		},
 	}
 	return nil
@@ -317,6 +346,26 @@ func (bd *BD) GetPlayer(sid64 steamid.SID64) *model.Player {
 	return nil
 }
 
+// playerTeam returns the team of the player. Team is updated from the lobby while holding playersMu rather than the
+// player lock, so it must not be read while holding a player lock.
+func (bd *BD) playerTeam(sid64 steamid.SID64) (model.Team, bool) {
+	bd.playersMu.RLock()
+	defer bd.playersMu.RUnlock()
+	for _, player := range bd.players {
+		if player.SteamId == sid64 {
+			return player.Team, true
+		}
+	}
+	return 0, false
+}
+
+// teammate returns true when the player is on our team
+func (bd *BD) teammate(sid64 steamid.SID64) bool {
+	ourTeam, foundUs := bd.playerTeam(bd.settings.GetSteamId())
+	theirTeam, foundThem := bd.playerTeam(sid64)
+	return foundUs && foundThem && ourTeam == theirTeam
+}
+
 func (bd *BD) getPlayerByName(name string) *model.Player {
 	bd.playersMu.RLock()
 	defer bd.playersMu.RUnlock()
@@ -336,12 +385,13 @@ func (bd *BD) checkHandler(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-checkTimer.C:
-			p := bd.GetPlayer(bd.settings.GetSteamId())
-			if p == nil {
+			ourTeam, found := bd.playerTeam(bd.settings.GetSteamId())
+			if !found {
 				// We have not connected yet.
 				continue
 			}
-			bd.checkPlayerStates(ctx, p.Team)
+			bd.checkPlayerStates(ctx, ourTeam)
+			bd.processKickQueue()
 		}
 	}
 }
@@ -349,17 +399,32 @@ func (bd *BD) checkHandler(ctx context.Context) {
 func (bd *BD) cleanupHandler(ctx context.Context) {
 	defer bd.logger.Debug("cleanupHandler exited")
 	deleteTimer := time.NewTicker(model.DurationPlayerExpired)
+	sweepTimer := time.NewTicker(model.DurationMarkSweepTimer)
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-sweepTimer.C:
+			if removed := bd.rules.SweepExpired(time.Now()); removed > 0 {
+				bd.logger.Info("Removed expired marks", zap.Int("count", removed))
+				if errExport := bd.exportLocalPlayers(); errExport != nil {
+					bd.logger.Error("Failed to save player list", zap.Error(errExport))
+				}
+			}
 		case <-deleteTimer.C:
 			bd.logger.Debug("Delete update input received", zap.String("state", "start"))
 			bd.serverMu.Lock()
+			disconnected := bd.server.Addr != nil && time.Since(bd.server.LastUpdate) > model.DurationDisconnected
 			if time.Since(bd.server.LastUpdate) > model.DurationDisconnected {
 				bd.server = model.Server{}
 			}
 			bd.serverMu.Unlock()
+			if disconnected {
+				bd.clearTransientMarks()
+				bd.endVoteSession(ctx)
+				bd.kicks.reset()
+				bd.coordinator.reset()
+			}
 			var valid model.PlayerCollection
 			expired := 0
 			for _, ps := range bd.players {
@@ -388,14 +453,49 @@ func (bd *BD) cleanupHandler(ctx context.Context) {
 	}
 }
 
+// performAvatarDownload computes the perceptual hash of the players avatar, which the avatar rules are matched
+// against along with the rest of the player state in checkPlayerStates. The hash is stored with the player so
+// that known avatars are only fetched when the gui needs to display them.
 func (bd *BD) performAvatarDownload(ctx context.Context, sid64 steamid.SID64, hash string) {
+	player := bd.GetPlayer(sid64)
+	if player == nil {
+		return
+	}
+	bd.playersMu.RLock()
+	perceptualHash := player.AvatarPerceptualHash
+	bd.playersMu.RUnlock()
+	if perceptualHash == "" {
+		bd.updatePerceptualHash(ctx, player, hash)
+	}
+	if bd.gui == nil {
+		return
+	}
 	avatar, errDownload := bd.fetchAvatar(ctx, hash)
 	if errDownload != nil {
 		bd.logger.Error("Failed to download avatar", zap.String("hash", hash), zap.Error(errDownload))
 		return
 	}
-	if bd.gui != nil {
-		bd.gui.SetAvatar(sid64, avatar)
+	bd.gui.SetAvatar(sid64, avatar)
+}
+
+// updatePerceptualHash downloads the avatar and stores its perceptual hash with the player
+func (bd *BD) updatePerceptualHash(ctx context.Context, player *model.Player, hash string) {
+	avatar, errDownload := bd.fetchAvatar(ctx, hash)
+	if errDownload != nil {
+		bd.logger.Error("Failed to download avatar", zap.String("hash", hash), zap.Error(errDownload))
+		return
+	}
+	perceptualHash, errHash := rules.PerceptualHash(avatar)
+	if errHash != nil {
+		bd.logger.Warn("Failed to compute perceptual avatar hash", zap.String("hash", hash), zap.Error(errHash))
+		return
+	}
+	bd.playersMu.Lock()
+	defer bd.playersMu.Unlock()
+	// The avatar may have changed while downloading
+	if player.AvatarHash == hash {
+		player.AvatarPerceptualHash = perceptualHash
+		player.Touch()
 	}
 }
 
@@ -459,7 +559,7 @@ func (bd *BD) gameStateUpdater(ctx context.Context) {
 			case updateKill:
 				e, ok := update.data.(killEvent)
 				if ok {
-					bd.onUpdateKill(e)
+					bd.onUpdateKill(ctx, e)
 				}
 			case updateBans:
 				bd.onUpdateBans(update.source, update.data.(steamweb.PlayerBanState))
@@ -481,10 +581,22 @@ func (bd *BD) gameStateUpdater(ctx context.Context) {
 				}
 			case updateLobby:
 				bd.onUpdateLobby(update.source, update.data.(lobbyEvent))
+			case updateVoteStart:
+				bd.onVoteStart(ctx, update.data.(voteStartEvent))
+			case updateVoteCast:
+				bd.onVoteCast(update.data.(voteCastEvent))
+			case updateVoteResult:
+				bd.onVoteResult(ctx, update.data.(voteResultEvent))
+			case updateVoteCooldown:
+				bd.onVoteCooldown(update.data.(voteCooldownEvent))
+			case updatePlayerDisconnect:
+				bd.onPlayerDisconnect(ctx, update.data.(playerDisconnectEvent))
 			case updateTags:
 				bd.onUpdateTags(update.data.(tagsEvent))
 			case updateHostname:
 				bd.onUpdateHostname(update.data.(hostnameEvent))
+			case updateAddress:
+				bd.onUpdateAddress(update.data.(addressEvent))
 			case updateMap:
 				bd.onUpdateMap(update.data.(mapEvent))
 			case changeMap:
@@ -513,6 +625,28 @@ func (bd *BD) onUpdateMap(event mapEvent) {
 	bd.serverMu.Unlock()
 }
 
+// onUpdateAddress records the address of the server, clearing the transient marks and ending the vote session when
+// connecting to a different server
+func (bd *BD) onUpdateAddress(event addressEvent) {
+	bd.serverMu.Lock()
+	changed := bd.server.Addr != nil && (!bd.server.Addr.Equal(event.ip) || bd.server.Port != event.port)
+	bd.server.Addr = event.ip
+	bd.server.Port = event.port
+	bd.serverMu.Unlock()
+	if changed {
+		bd.clearTransientMarks()
+		bd.endVoteSession(bd.ctx)
+		bd.kicks.reset()
+		bd.coordinator.reset()
+	}
+}
+
+func (bd *BD) clearTransientMarks() {
+	if cleared := bd.rules.ClearTransient(); cleared > 0 {
+		bd.logger.Info("Cleared transient marks", zap.Int("count", cleared))
+	}
+}
+
 func (bd *BD) onUpdateHostname(event hostnameEvent) {
 	bd.serverMu.Lock()
 	bd.server.ServerName = event.hostname
@@ -540,6 +674,11 @@ func (bd *BD) onUpdateLobby(steamID steamid.SID64, evt lobbyEvent) {
 }
 
 func (bd *BD) onUpdateMessage(ctx context.Context, msg messageEvent, store store.DataStore) error {
+	// Kick coordination messages from other bd instances are not player chat
+	if partyMsg, ok := trustedPartyMessage(msg, bd.getPlayerByName); ok {
+		bd.onPartyMessage(partyMsg)
+		return nil
+	}
 	player := bd.getPlayerByName(msg.name)
 	if player == nil {
 		return errors.Errorf("Unknown name: %v", msg.name)
@@ -551,6 +690,8 @@ func (bd *BD) onUpdateMessage(ctx context.Context, msg messageEvent, store store
 	um.Team = player.Team
 	um.PlayerSID = player.SteamId
 	um.UserId = player.UserId
+	// The rest of the player state is included so that rules combining it with chat triggers can fire
+	input := player.MatchInput()
 	bd.playersMu.RUnlock()
 	um.Message = msg.message
 	um.Created = msg.createdAt
@@ -560,9 +701,15 @@ func (bd *BD) onUpdateMessage(ctx context.Context, msg messageEvent, store store
 	if errSaveMsg := store.SaveMessage(ctx, &um); errSaveMsg != nil {
 		bd.logger.Error("Error trying to store user message log", zap.Error(errSaveMsg))
 	}
-	if match := bd.rules.MatchMessage(um.Message); match != nil {
-		bd.triggerMatch(player, match)
+	input.Message = um.Message
+	if matches := bd.rules.MatchChat(input); len(matches) > 0 {
+		bd.triggerMatch(player, matches)
 	}
+	sentAt := um.Created
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+	bd.checkChatSpam(um.PlayerSID, um.Message, sentAt)
 	if bd.gui != nil {
 		bd.gui.AddUserMessage(um)
 		bd.gui.Refresh()
@@ -570,27 +717,40 @@ func (bd *BD) onUpdateMessage(ctx context.Context, msg messageEvent, store store
 	return nil
 }
 
-func (bd *BD) onUpdateKill(kill killEvent) {
+func (bd *BD) onUpdateKill(ctx context.Context, kill killEvent) {
+	kill.sourceName, kill.assisterName = splitAssist(kill.sourceName, func(name string) bool {
+		return bd.nameToSid(bd.players, name).Valid()
+	})
 	source := bd.nameToSid(bd.players, kill.sourceName)
 	target := bd.nameToSid(bd.players, kill.victimName)
 	if !source.Valid() || !target.Valid() {
 		return
 	}
+	var assister steamid.SID64
+	if kill.assisterName != "" {
+		assister = bd.nameToSid(bd.players, kill.assisterName)
+	}
 	ourSid := bd.settings.GetSteamId()
 	sourcePlayer := bd.GetPlayer(source)
 	targetPlayer := bd.GetPlayer(target)
 	bd.playersMu.Lock()
-	sourcePlayer.Kills++
-	targetPlayer.Deaths++
-	if targetPlayer.SteamId == ourSid {
-		sourcePlayer.DeathsBy++
-	}
-	if sourcePlayer.SteamId == ourSid {
-		targetPlayer.KillsOn++
+	if kill.suicide {
+		targetPlayer.Deaths++
+	} else {
+		sourcePlayer.Kills++
+		targetPlayer.Deaths++
+		if targetPlayer.SteamId == ourSid {
+			sourcePlayer.DeathsBy++
+		}
+		if sourcePlayer.SteamId == ourSid {
+			targetPlayer.KillsOn++
+			targetPlayer.KilledByUsLast = time.Now()
+		}
 	}
 	sourcePlayer.Touch()
 	targetPlayer.Touch()
 	bd.playersMu.Unlock()
+	bd.saveKill(ctx, kill, source, assister, target)
 }
 
 func (bd *BD) onMapChange() {
@@ -613,9 +773,16 @@ func (bd *BD) onUpdateBans(steamID steamid.SID64, ban steamweb.PlayerBanState) {
 	player.NumberOfVACBans = ban.NumberOfVACBans
 	player.NumberOfGameBans = ban.NumberOfGameBans
 	player.CommunityBanned = ban.CommunityBanned
-	if ban.DaysSinceLastBan > 0 {
-		subTime := time.Now().AddDate(0, 0, -ban.DaysSinceLastBan)
-		player.LastVACBanOn = &subTime
+	// Steam only reports the days since the most recent ban of either kind, so a player with both is assumed to
+	// have received them on the same day
+	lastBanOn := time.Now().AddDate(0, 0, -ban.DaysSinceLastBan)
+	player.LastVACBanOn = nil
+	if ban.NumberOfVACBans > 0 {
+		player.LastVACBanOn = &lastBanOn
+	}
+	player.LastGameBanOn = nil
+	if ban.NumberOfGameBans > 0 {
+		player.LastGameBanOn = &lastBanOn
 	}
 	player.EconomyBan = ban.EconomyBan != "none"
 	player.Touch()
@@ -626,6 +793,9 @@ func (bd *BD) onUpdateProfile(steamID steamid.SID64, summary steamweb.PlayerSumm
 	bd.playersMu.Lock()
 	defer bd.playersMu.Unlock()
 	player.Visibility = model.ProfileVisibility(summary.CommunityVisibilityState)
+	if player.AvatarHash != summary.AvatarHash {
+		player.AvatarPerceptualHash = ""
+	}
 	player.AvatarHash = summary.AvatarHash
 	player.AccountCreatedOn = time.Unix(int64(summary.TimeCreated), 0)
 	player.RealName = summary.RealName
@@ -663,19 +833,69 @@ func (bd *BD) onUpdateStatus(ctx context.Context, store store.DataStore, steamID
 }
 
 func (bd *BD) onUpdateWhitelist(event updateWhitelistEvent) error {
+	name := ""
 	player := bd.GetPlayer(event.target)
-	if player == nil {
-		return errors.New("Unknown player, cannot update whitelist")
+	if player != nil {
+		name = player.GetName()
+	}
+	if event.enabled {
+		if errWhitelist := bd.rules.Whitelist(event.target, name); errWhitelist != nil {
+			return errors.Wrap(errWhitelist, "Failed to add player to whitelist")
+		}
+	} else if !bd.rules.Unwhitelist(event.target) {
+		return errors.New("Player is not on the local whitelist")
+	}
+	if player != nil {
+		bd.updateWhitelistState(player)
+	}
+	if errExport := bd.exportWhitelist(); errExport != nil {
+		return errExport
 	}
-	bd.playersMu.Lock()
-	player.Whitelisted = event.enabled
-	player.Touch()
-	bd.playersMu.Unlock()
 	bd.logger.Info("Update player whitelist status successfully",
-		zap.Int64("steam_id", player.SteamId.Int64()), zap.Bool("enabled", event.enabled))
+		zap.Int64("steam_id", event.target.Int64()), zap.Bool("enabled", event.enabled))
 	return nil
 }
 
+// updateWhitelistState records if the player is on any of the loaded whitelists
+func (bd *BD) updateWhitelistState(ps *model.Player) {
+	whitelist := bd.rules.MatchWhitelist(ps.GetSteamID())
+	ps.Lock()
+	ps.WhitelistMatch = whitelist
+	ps.Whitelisted = whitelist != nil
+	ps.Unlock()
+}
+
+// migrateWhitelist moves the players whitelisted using the legacy database column to the local whitelist
+func (bd *BD) migrateWhitelist(ctx context.Context) {
+	whitelisted, errFetch := bd.store.FetchWhitelisted(ctx)
+	if errFetch != nil {
+		bd.logger.Error("Failed to fetch legacy whitelist", zap.Error(errFetch))
+		return
+	}
+	if len(whitelisted) == 0 {
+		return
+	}
+	for _, sid64 := range whitelisted {
+		name := ""
+		player := model.NewPlayer(sid64, "")
+		if errPlayer := bd.store.GetPlayer(ctx, sid64, player); errPlayer == nil {
+			name = player.NamePrevious
+		}
+		if errWhitelist := bd.rules.Whitelist(sid64, name); errWhitelist != nil && !errors.Is(errWhitelist, rules.ErrDuplicateSteamID) {
+			bd.logger.Error("Failed to migrate whitelisted player", zap.Int64("steam_id", sid64.Int64()), zap.Error(errWhitelist))
+		}
+	}
+	if errExport := bd.exportWhitelist(); errExport != nil {
+		bd.logger.Error("Failed to save migrated whitelist", zap.Error(errExport))
+		return
+	}
+	if errClear := bd.store.ClearWhitelisted(ctx); errClear != nil {
+		bd.logger.Error("Failed to clear legacy whitelist", zap.Error(errClear))
+		return
+	}
+	bd.logger.Info("Migrated whitelisted players", zap.Int("count", len(whitelisted)))
+}
+
 func (bd *BD) onUpdateMark(status updateMarkEvent) error {
 	player := bd.GetPlayer(status.target)
 	if player == nil {
@@ -693,29 +913,56 @@ func (bd *BD) onUpdateMark(status updateMarkEvent) error {
 		bd.playersMu.Lock()
 		for idx := range bd.players {
 			if bd.players[idx].SteamId == status.target {
-				bd.players[idx].Match = nil
+				bd.players[idx].Matches = nil
 				break
 			}
 		}
 		bd.playersMu.Unlock()
 		bd.gui.UpdatePlayerState(bd.players)
 	} else {
-		if errMark := bd.rules.Mark(rules.MarkOpts{
+		opts := rules.MarkOpts{
 			SteamID:    status.target,
 			Attributes: status.attrs,
 			Name:       name,
-		}); errMark != nil {
+			Transient:  status.duration == model.MarkTransient,
+		}
+		if status.duration > 0 {
+			opts.ExpiresOn = time.Now().Add(time.Duration(status.duration))
+		}
+		if errMark := bd.rules.Mark(opts); errMark != nil {
 			return errors.Wrap(errMark, "Failed to add mark")
 		}
+		if opts.Transient {
+			// Nothing to persist
+			return nil
+		}
 	}
-	of, errOf := os.OpenFile(bd.settings.LocalPlayerListPath(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	return bd.exportLocalPlayers()
+}
+
+// exportLocalPlayers writes the local player list to disk
+func (bd *BD) exportLocalPlayers() error {
+	return bd.exportList(bd.settings.LocalPlayerListPath(), func(w io.Writer) error {
+		return bd.rules.ExportPlayers(rules.LocalRuleName, w)
+	})
+}
+
+// exportWhitelist writes the local whitelist to disk
+func (bd *BD) exportWhitelist() error {
+	return bd.exportList(bd.settings.LocalWhitelistPath(), func(w io.Writer) error {
+		return bd.rules.ExportWhitelist(rules.LocalWhitelistName, w)
+	})
+}
+
+func (bd *BD) exportList(listPath string, export func(w io.Writer) error) error {
+	of, errOf := os.OpenFile(listPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if errOf != nil {
-		return errors.Wrap(errOf, "Failed to open player list for updating")
+		return errors.Wrap(errOf, "Failed to open list for updating")
 	}
-	if errExport := bd.rules.ExportPlayers(rules.LocalRuleName, of); errExport != nil {
-		bd.logger.Error("Failed to export player list", zap.Error(errExport))
+	defer util.LogClose(bd.logger, of)
+	if errExport := export(of); errExport != nil {
+		return errors.Wrap(errExport, "Failed to export list")
 	}
-	util.LogClose(bd.logger, of)
 	return nil
 }
 
@@ -726,18 +973,106 @@ func (bd *BD) AttachGui(gui model.UserInterface) {
 	bd.gui = gui
 }
 
+// ListStatus returns the status of the most recent update of the list with the url provided
+func (bd *BD) ListStatus(url string) (model.ListStatus, bool) {
+	bd.listStatusMu.RLock()
+	defer bd.listStatusMu.RUnlock()
+	status, found := bd.listStatus[url]
+	return status, found
+}
+
+func (bd *BD) updateListStatus(result listResult) {
+	bd.listStatusMu.Lock()
+	defer bd.listStatusMu.Unlock()
+	status := bd.listStatus[result.url]
+	status.Duration = result.duration
+	status.LastError = result.err
+	if result.err == nil {
+		status.LastSuccess = time.Now()
+	}
+	if result.parsed {
+		status.Count = result.count
+		status.Dropped = result.dropped
+	}
+	bd.listStatus[result.url] = status
+}
+
+// listUpdater loads the configured lists and then periodically checks them for updates
+func (bd *BD) listUpdater(ctx context.Context) {
+	defer bd.logger.Debug("listUpdater exited")
+	bd.refreshLists(ctx)
+	refreshTimer := time.NewTicker(bd.settings.GetListRefreshInterval())
+	defer refreshTimer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refreshTimer.C:
+			bd.refreshLists(ctx)
+			// Pick up any changes to the configured interval
+			refreshTimer.Reset(bd.settings.GetListRefreshInterval())
+		}
+	}
+}
+
 func (bd *BD) refreshLists(ctx context.Context) {
-	playerLists, ruleLists := downloadLists(ctx, bd.logger, bd.settings.GetLists())
-	for _, list := range playerLists {
-		count, errImport := bd.rules.ImportPlayers(&list)
+	lists := bd.settings.GetLists()
+	bd.removeStaleLists(lists)
+	bd.loadLists(ctx, lists)
+}
+
+// removeStaleLists unloads the lists which were enabled at the last refresh but have since been disabled, deleted
+// or had their url changed in the configured lists. listSources is only accessed by listUpdater so needs no lock.
+func (bd *BD) removeStaleLists(lists model.ListConfigCollection) {
+	sources := map[string]bool{}
+	for _, listConfig := range lists {
+		if listConfig.Enabled {
+			sources[listConfig.URL] = true
+		}
+	}
+	for source := range bd.listSources {
+		if !sources[source] && bd.rules.RemoveList(source) {
+			bd.logger.Info("Removed stale list", zap.String("url", source))
+		}
+	}
+	bd.listSources = sources
+}
+
+// loadLists updates and imports the enabled lists provided
+func (bd *BD) loadLists(ctx context.Context, lists model.ListConfigCollection) {
+	playerLists, ruleLists, whitelists, results := downloadLists(ctx, bd.logger, bd.cache, lists, bd.rules.IsLoaded)
+	for _, result := range results {
+		bd.updateListStatus(result)
+	}
+	for i := range playerLists {
+		list := &playerLists[i]
+		count, errImport := bd.rules.ImportPlayers(list)
 		if errImport != nil {
 			bd.logger.Error("Failed to import player list", zap.String("name", list.FileInfo.Title), zap.Error(errImport))
 		} else {
 			bd.logger.Info("Imported player list", zap.String("name", list.FileInfo.Title), zap.Int("count", count))
 		}
 	}
-	for _, list := range ruleLists {
-		count, errImport := bd.rules.ImportRules(&list)
+	for i := range whitelists {
+		list := &whitelists[i]
+		count, errImport := bd.rules.ImportWhitelist(list)
+		if errImport != nil {
+			bd.logger.Error("Failed to import whitelist", zap.String("name", list.FileInfo.Title), zap.Error(errImport))
+		} else {
+			bd.logger.Info("Imported whitelist", zap.String("name", list.FileInfo.Title), zap.Int("count", count))
+		}
+	}
+	for i := range ruleLists {
+		list := &ruleLists[i]
+		count, errImport := bd.rules.ImportRules(list)
+		var ruleErrs rules.RuleErrors
+		if errors.As(errImport, &ruleErrs) {
+			for _, ruleErr := range ruleErrs {
+				bd.logger.Warn("Skipped invalid rule", zap.String("name", ruleErr.List),
+					zap.Int("index", ruleErr.Index), zap.Error(ruleErr.Err))
+			}
+			errImport = nil
+		}
 		if errImport != nil {
 			bd.logger.Error("Failed to import rules list (%s): %v\n", zap.String("name", list.FileInfo.Title), zap.Error(errImport))
 		} else {
@@ -751,22 +1086,23 @@ func (bd *BD) refreshLists(ctx context.Context) {
 }
 
 func (bd *BD) checkPlayerStates(ctx context.Context, validTeam model.Team) {
+	nameStealers := findNameStealers(bd.players, bd.settings.GetSteamId())
 	for _, ps := range bd.players {
 		if ps.IsDisconnected() {
 			continue
 		}
-		if matchSteam := bd.rules.MatchSteam(ps.GetSteamID()); matchSteam != nil {
-			ps.Match = matchSteam
-			if validTeam == ps.Team {
-				bd.triggerMatch(ps, matchSteam)
-			}
-		} else if ps.Name != "" {
-			if matchName := bd.rules.MatchName(ps.GetName()); matchName != nil && validTeam == ps.Team {
-				ps.Match = matchSteam
-				if validTeam == ps.Team {
-					bd.triggerMatch(ps, matchSteam)
-				}
-			}
+		matches := bd.rules.MatchPlayer(ps.GetSteamID(), ps.MatchInput())
+		if stealer, found := nameStealers[ps.GetSteamID()]; found {
+			matches = append(matches, stealer)
+		}
+		matches = append(matches, bd.chatSpam.matches(ps.GetSteamID(), time.Now())...)
+		bd.applyTransientMarks(ps, matches)
+		bd.updateWhitelistState(ps)
+		ps.Lock()
+		ps.Matches = matches
+		ps.Unlock()
+		if len(matches) > 0 && validTeam == ps.Team {
+			bd.triggerMatch(ps, matches)
 		}
 		if ps.Dirty {
 			if errSave := bd.store.SavePlayer(ctx, ps); errSave != nil {
@@ -781,50 +1117,50 @@ func (bd *BD) checkPlayerStates(ctx context.Context, validTeam model.Team) {
 	}
 }
 
-func (bd *BD) triggerMatch(ps *model.Player, match *rules.MatchResult) {
+// applyTransientMarks marks the player with the attributes of the transient_mark actions of the rules they matched
+func (bd *BD) applyTransientMarks(ps *model.Player, matches rules.MatchResults) {
+	for _, match := range matches {
+		if len(match.TransientMark) == 0 {
+			continue
+		}
+		errMark := bd.rules.Mark(rules.MarkOpts{
+			SteamID:    ps.GetSteamID(),
+			Attributes: match.TransientMark,
+			Name:       ps.GetName(),
+			Transient:  true,
+		})
+		if errMark != nil && !errors.Is(errMark, rules.ErrDuplicateSteamID) {
+			bd.logger.Error("Failed to add transient mark", zap.Error(errMark))
+		}
+	}
+}
+
+func (bd *BD) triggerMatch(ps *model.Player, matches rules.MatchResults) {
+	// Teams are guarded by playersMu, so they are checked before taking the player lock
+	teammate := bd.teammate(ps.SteamId)
 	ps.Lock()
 	defer ps.Unlock()
 	announceGeneralLast := ps.AnnouncedGeneralLast
-	announcePartyLast := ps.AnnouncedPartyLast
 	if time.Since(announceGeneralLast) >= model.DurationAnnounceMatchTimeout {
 		msg := "Matched player"
+		whitelist := ""
 		if ps.Whitelisted {
 			msg = "Matched whitelisted player"
+			if ps.WhitelistMatch != nil {
+				whitelist = ps.WhitelistMatch.Origin
+			}
+		}
+		for _, match := range matches {
+			bd.logger.Info(msg, zap.String("match_type", match.MatcherType), zap.String("whitelist", whitelist),
+				zap.Int64("steam_id", ps.SteamId.Int64()), zap.String("name", ps.Name), zap.String("origin", match.Origin), zap.String("rule", match.Rule),
+				zap.String("normalised", match.Normalised))
 		}
-		bd.logger.Info(msg, zap.String("match_type", match.MatcherType),
-			zap.Int64("steam_id", ps.SteamId.Int64()), zap.String("name", ps.Name), zap.String("origin", match.Origin))
 		ps.AnnouncedGeneralLast = time.Now()
 	}
 	if ps.Whitelisted {
 		return
 	}
-	if bd.settings.GetPartyWarningsEnabled() && time.Since(announcePartyLast) >= model.DurationAnnounceMatchTimeout {
-		// Don't spam friends, but eventually remind them if they manage to forget long enough
-		if errLog := bd.SendChat(model.ChatDestParty, "(%d) [%s] [%s] %s ", ps.UserId, match.Origin, strings.Join(match.Attributes, ","), ps.Name); errLog != nil {
-			bd.logger.Error("Failed to send party log message", zap.Error(errLog))
-			return
-		}
-		ps.AnnouncedPartyLast = time.Now()
-	}
-	if bd.settings.GetKickerEnabled() {
-		kickTag := false
-		for _, tag := range match.Attributes {
-			for _, allowedTag := range bd.settings.GetKickTags() {
-				if strings.EqualFold(tag, allowedTag) {
-					kickTag = true
-					break
-				}
-			}
-		}
-		if kickTag {
-			if errVote := bd.CallVote(ps.UserId, model.KickReasonCheating); errVote != nil {
-				bd.logger.Error("Error calling vote", zap.Error(errVote))
-			}
-		} else {
-			bd.logger.Info("Skipping kick, no acceptable tag found")
-		}
-	}
-	ps.KickAttemptCount++
+	bd.executePolicy(ps, matches, teammate)
 }
 
 func (bd *BD) ensureRcon() error {
@@ -923,10 +1259,12 @@ func (bd *BD) Shutdown() {
 }
 
 func (bd *BD) Start(ctx context.Context) {
+	bd.migrateWhitelist(ctx)
 	go bd.logReader.start(ctx)
 	defer bd.logReader.tail.Cleanup()
 	go bd.logParser.start(ctx)
-	go bd.refreshLists(ctx)
+	go bd.listUpdater(ctx)
+	go bd.listWatcher(ctx)
 	go bd.incomingLogEventHandler(ctx)
 	go bd.gameStateUpdater(ctx)
 	go bd.cleanupHandler(ctx)
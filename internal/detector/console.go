@@ -85,6 +85,7 @@ type logParser struct {
 const teamPrefix = "(TEAM) "
 const deadPrefix = "*DEAD* "
 const deadTeamPrefix = "*DEAD*(TEAM) "
+const partyPrefix = "(PARTY) "
 
 func (parser *logParser) parseEvent(msg string, outEvent *model.LogEvent) error {
 	// the index must match the index of the EventType const values
@@ -105,6 +106,11 @@ func (parser *logParser) parseEvent(msg string, outEvent *model.LogEvent) error
 				name := match[2]
 				dead := false
 				team := false
+				party := false
+				if strings.HasPrefix(name, partyPrefix) {
+					name = strings.TrimPrefix(name, partyPrefix)
+					party = true
+				}
 				if strings.HasPrefix(name, teamPrefix) {
 					name = strings.TrimPrefix(name, teamPrefix)
 					team = true
@@ -119,6 +125,7 @@ func (parser *logParser) parseEvent(msg string, outEvent *model.LogEvent) error
 				}
 				outEvent.TeamOnly = team
 				outEvent.Dead = dead
+				outEvent.Party = party
 				outEvent.Player = name
 				outEvent.Message = match[3]
 			case model.EvtStatusId:
@@ -145,6 +152,15 @@ func (parser *logParser) parseEvent(msg string, outEvent *model.LogEvent) error
 			case model.EvtKill:
 				outEvent.Player = match[2]
 				outEvent.Victim = match[3]
+				outEvent.Weapon = match[4]
+				outEvent.Crit = match[5] != "."
+			case model.EvtSuicide:
+				outEvent.Player = match[2]
+				outEvent.Victim = match[2]
+				outEvent.Suicide = true
+				if match[3] == "died" {
+					outEvent.Weapon = model.WeaponWorld
+				}
 			case model.EvtHostname:
 				outEvent.MetaData = match[2]
 			case model.EvtMap:
@@ -160,6 +176,24 @@ func (parser *logParser) parseEvent(msg string, outEvent *model.LogEvent) error
 				} else {
 					outEvent.Team = model.Red
 				}
+			case model.EvtVoteStart:
+				outEvent.Player = match[2]
+				outEvent.Victim = match[3]
+			case model.EvtVoteCast:
+				outEvent.Player = match[2]
+				outEvent.MetaData = strings.ToLower(match[3])
+			case model.EvtVoteResult:
+				outEvent.MetaData = match[2]
+				if match[3] != "" {
+					// Tallies are bounded by the player count, so these cannot fail to parse
+					outEvent.VoteYes, _ = strconv.Atoi(match[3])
+					outEvent.VoteNo, _ = strconv.Atoi(match[4])
+				}
+			case model.EvtVoteCooldown:
+				outEvent.MetaData = match[2]
+			case model.EvtPlayerDisconnect:
+				outEvent.Player = match[2]
+				outEvent.MetaData = match[3]
 			}
 			return nil
 		}
@@ -219,6 +253,12 @@ func newLogParser(logger *zap.Logger, readChannel chan string, evtChan chan mode
 			regexp.MustCompile(`^(?P<dt>[01]\d/[0123]\d/20\d{2}\s-\s\d{2}:\d{2}:\d{2}):\smap\s{5}:\s(.+?)\sat.+?$`),
 			regexp.MustCompile(`^(?P<dt>[01]\d/[0123]\d/20\d{2}\s-\s\d{2}:\d{2}:\d{2}):\stags\s{4}:\s(.+?)$`),
 			regexp.MustCompile(`^(?P<dt>[01]\d/[0123]\d/20\d{2}\s-\s\d{2}:\d{2}:\d{2}):\sudp/ip\s{2}:\s(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}:\d{1,5})$`),
-			regexp.MustCompile(`^\s{2}(Member|Pending)\[\d+]\s+(?P<sid>\[.+?]).+?TF_GC_TEAM_(?P<team>(DEFENDERS|INVADERS))\s{2}type\s=\sMATCH_PLAYER$`)},
+			regexp.MustCompile(`^\s{2}(Member|Pending)\[\d+]\s+(?P<sid>\[.+?]).+?TF_GC_TEAM_(?P<team>(DEFENDERS|INVADERS))\s{2}type\s=\sMATCH_PLAYER$`),
+			regexp.MustCompile(`^(?P<dt>[01]\d/[0123]\d/20\d{2}\s-\s\d{2}:\d{2}:\d{2}):\sVote\sstarted\sby\s"(?P<caller>.+?)":\skick\s"(?P<target>.+?)"$`),
+			regexp.MustCompile(`^(?P<dt>[01]\d/[0123]\d/20\d{2}\s-\s\d{2}:\d{2}:\d{2}):\sVote\scast\sby\s"(?P<voter>.+?)":\s(?P<option>Yes|No)$`),
+			regexp.MustCompile(`^(?P<dt>[01]\d/[0123]\d/20\d{2}\s-\s\d{2}:\d{2}:\d{2}):\sVote\s(?P<result>passed|failed)(?:\s\(Yes:\s(?P<yes>\d{1,3}),\sNo:\s(?P<no>\d{1,3})\))?$`),
+			regexp.MustCompile(`^(?P<dt>[01]\d/[0123]\d/20\d{2}\s-\s\d{2}:\d{2}:\d{2}):\s(?:(?:.+?\s)?(?:[Cc]annot|[Cc]an't)\scall\s(?:a\snew|another|a)\svote(?:\sfor\s(?P<seconds>\d{1,4})\sseconds?|\s.+?)?|#?(?:GameUI_)?vote_failed\w*)\.?$`),
+			regexp.MustCompile(`^(?P<dt>[01]\d/[0123]\d/20\d{2}\s-\s\d{2}:\d{2}:\d{2}):\s(?P<name>.+?)\sleft\sthe\sgame\s\((?P<reason>.+?)\)$`),
+			regexp.MustCompile(`^(?P<dt>[01]\d/[0123]\d/20\d{2}\s-\s\d{2}:\d{2}:\d{2}):\s(?P<name>.+?)\s(?P<kind>suicided|died)\.$`)},
 	}
 }
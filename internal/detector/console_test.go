@@ -29,14 +29,35 @@ func TestParseEvent(t *testing.T) {
 			text:     "02/24/2023 - 23:37:19: *DEAD*(TEAM) Hassium :  thats the problem vixian",
 			match:    true,
 			expected: model.LogEvent{Type: model.EvtMsg, Player: "Hassium", Message: "thats the problem vixian", Timestamp: ts, Dead: true, TeamOnly: true},
+		}, {
+			text:     "02/24/2023 - 23:37:19: (PARTY) Hassium :  bd1 status 76561197960265730 0",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtMsg, Player: "Hassium", Message: "bd1 status 76561197960265730 0", Timestamp: ts, Party: true},
 		}, {
 			text:     "02/24/2023 - 23:37:19: ❤ Ashley ❤ killed [TrC] Nosy with spy_cicle.",
 			match:    true,
-			expected: model.LogEvent{Type: model.EvtKill, Player: "❤ Ashley ❤", Victim: "[TrC] Nosy", Timestamp: ts},
+			expected: model.LogEvent{Type: model.EvtKill, Player: "❤ Ashley ❤", Victim: "[TrC] Nosy", Weapon: "spy_cicle", Timestamp: ts},
 		}, {
 			text:     "02/24/2023 - 23:37:19: ❤ Ashley ❤ killed [TrC] Nosy with spy_cicle. (crit)",
 			match:    true,
-			expected: model.LogEvent{Type: model.EvtKill, Player: "❤ Ashley ❤", Victim: "[TrC] Nosy", Timestamp: ts},
+			expected: model.LogEvent{Type: model.EvtKill, Player: "❤ Ashley ❤", Victim: "[TrC] Nosy", Weapon: "spy_cicle", Crit: true, Timestamp: ts},
+		}, {
+			// Assisted kills are split into the killer and assister once the player names are known
+			text:     "02/24/2023 - 23:37:19: ❤ Ashley ❤ + Hassium killed [TrC] Nosy with scattergun.",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtKill, Player: "❤ Ashley ❤ + Hassium", Victim: "[TrC] Nosy", Weapon: "scattergun", Timestamp: ts},
+		}, {
+			text:     "02/24/2023 - 23:37:19: [TrC] Nosy suicided.",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtSuicide, Player: "[TrC] Nosy", Victim: "[TrC] Nosy", Suicide: true, Timestamp: ts},
+		}, {
+			text:     "02/24/2023 - 23:37:19: [TrC] Nosy died.",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtSuicide, Player: "[TrC] Nosy", Victim: "[TrC] Nosy", Weapon: model.WeaponWorld, Suicide: true, Timestamp: ts},
+		}, {
+			text:     "02/24/2023 - 23:37:19: Hassium :  I died.",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtMsg, Player: "Hassium", Message: "I died.", Timestamp: ts},
 		}, {
 			text:     "02/24/2023 - 23:37:19: Hassium connected",
 			match:    true,
@@ -75,6 +96,46 @@ func TestParseEvent(t *testing.T) {
 			text:     "02/24/2023 - 23:37:19: Differing lobby received. Lobby: [A:1:1191368713:22805]/Match79636263/Lobby601530352177650 CurrentlyAssigned: [A:1:1191368713:22805]/Match79636024/Lobby601530352177650 ConnectedToMatchServer: 1 HasLobby: 1 AssignedMatchEnded: 0",
 			match:    true,
 			expected: model.LogEvent{Type: model.EvtDisconnect, Timestamp: ts, MetaData: "Differing lobby received."}},
+		{
+			text:     "02/24/2023 - 23:37:19: Vote started by \"Hassium\": kick \"[TrC] Nosy\"",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtVoteStart, Timestamp: ts, Player: "Hassium", Victim: "[TrC] Nosy"}},
+		{
+			text:     "02/24/2023 - 23:37:19: Vote cast by \"❤ Ashley ❤\": No",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtVoteCast, Timestamp: ts, Player: "❤ Ashley ❤", MetaData: "no"}},
+		{
+			text:     "02/24/2023 - 23:37:19: Vote failed (Yes: 5, No: 7)",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtVoteResult, Timestamp: ts, MetaData: "failed", VoteYes: 5, VoteNo: 7}},
+		{
+			text:     "02/24/2023 - 23:37:19: Vote passed",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtVoteResult, Timestamp: ts, MetaData: "passed"}},
+		{
+			text:     "02/24/2023 - 23:37:19: [TrC] Nosy left the game (Disconnect by user.)",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtPlayerDisconnect, Timestamp: ts, Player: "[TrC] Nosy", MetaData: "Disconnect by user."}},
+		{
+			text:     "02/24/2023 - 23:37:19: You cannot call a new vote for 87 seconds.",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtVoteCooldown, Timestamp: ts, MetaData: "87"}},
+		{
+			text:     "02/24/2023 - 23:37:19: Cannot call a vote for 5 seconds.",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtVoteCooldown, Timestamp: ts, MetaData: "5"}},
+		{
+			text:     "02/24/2023 - 23:37:19: Cannot call a vote during waiting for players.",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtVoteCooldown, Timestamp: ts}},
+		{
+			text:     "02/24/2023 - 23:37:19: #GameUI_vote_failed_recently",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtVoteCooldown, Timestamp: ts}},
+		{
+			text:     "02/24/2023 - 23:37:19: Hassium :  you cannot call a new vote for 87 seconds",
+			match:    true,
+			expected: model.LogEvent{Type: model.EvtMsg, Timestamp: ts, Player: "Hassium", Message: "you cannot call a new vote for 87 seconds"}},
 	}
 	logger, _ := zap.NewDevelopment()
 	reader := newLogParser(logger, nil, nil)
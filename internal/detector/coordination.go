@@ -0,0 +1,277 @@
+package detector
+
+import (
+	"fmt"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// partyProtocolPrefix starts every party chat message used to coordinate kicks between bd instances. The version
+// is included so that incompatible instances ignore each other.
+const partyProtocolPrefix = "bd1"
+
+type partyMessageKind string
+
+const (
+	// partyStatus announces that the sender has targets to kick, along with their remaining vote cooldown
+	partyStatus partyMessageKind = "status"
+	// partyIntent announces that the sender is calling a kick vote against the target
+	partyIntent partyMessageKind = "intent"
+)
+
+// partyMessage is a kick coordination message sent over party chat, eg:
+//
+//	bd1 status 76561197960265730 87
+//	bd1 intent 76561197960265730 76561197960265731
+type partyMessage struct {
+	kind     partyMessageKind
+	sender   steamid.SID64
+	cooldown time.Duration
+	target   steamid.SID64
+}
+
+func (m partyMessage) String() string {
+	switch m.kind {
+	case partyStatus:
+		return fmt.Sprintf("%s %s %d %d", partyProtocolPrefix, m.kind, m.sender.Int64(), int(m.cooldown.Seconds()))
+	default:
+		return fmt.Sprintf("%s %s %d %d", partyProtocolPrefix, m.kind, m.sender.Int64(), m.target.Int64())
+	}
+}
+
+// parsePartyMessage decodes a coordination message from the text of a chat message, see trustedPartyMessage for
+// checking who sent it
+func parsePartyMessage(message string) (partyMessage, bool) {
+	fields := strings.Fields(message)
+	if len(fields) != 4 || fields[0] != partyProtocolPrefix {
+		return partyMessage{}, false
+	}
+	sender, errSender := steamid.StringToSID64(fields[2])
+	if errSender != nil || !sender.Valid() {
+		return partyMessage{}, false
+	}
+	msg := partyMessage{kind: partyMessageKind(fields[1]), sender: sender}
+	switch msg.kind {
+	case partyStatus:
+		seconds, errSeconds := strconv.Atoi(fields[3])
+		if errSeconds != nil || seconds < 0 {
+			return partyMessage{}, false
+		}
+		msg.cooldown = time.Duration(seconds) * time.Second
+	case partyIntent:
+		target, errTarget := steamid.StringToSID64(fields[3])
+		if errTarget != nil || !target.Valid() {
+			return partyMessage{}, false
+		}
+		msg.target = target
+	default:
+		return partyMessage{}, false
+	}
+	return msg, true
+}
+
+// trustedPartyMessage decodes a coordination message from a chat message, returning false unless it was sent to
+// party chat by the player it claims to be from. The author must be a known player in the current game whose steam
+// id is the sender of the message.
+//
+// The party prefix is read from the chat line, so a player can name themselves "(PARTY) name" to produce the same
+// line in all chat. Messages are rejected when such a player exists, or when the line is also marked as team or
+// dead chat which party chat never is.
+func trustedPartyMessage(evt messageEvent, findPlayer func(name string) *model.Player) (partyMessage, bool) {
+	if !evt.party || evt.teamOnly || evt.dead {
+		return partyMessage{}, false
+	}
+	msg, ok := parsePartyMessage(evt.message)
+	if !ok || findPlayer(partyPrefix+evt.name) != nil {
+		return partyMessage{}, false
+	}
+	author := findPlayer(evt.name)
+	if author == nil || author.GetSteamID() != msg.sender {
+		return partyMessage{}, false
+	}
+	return msg, true
+}
+
+type kickPeer struct {
+	cooldownUntil time.Time
+	lastSeen      time.Time
+}
+
+type kickClaim struct {
+	by steamid.SID64
+	at time.Time
+}
+
+// kickCoordinator tracks the other bd instances in our party so that only one of them calls a vote at a time and
+// they spread their votes across different targets.
+//
+// Instances with targets to kick announce their vote cooldown, and wait for DurationCheckTimer after their first
+// announcement to hear from the others. Out of the instances on our team which are off cooldown, the one with the
+// lowest steam id is elected as the caller. Before calling a vote the caller announces its intent so that the
+// others skip that target for DurationKickClaim, then announces its new cooldown handing over to the next
+// instance. Peers which stop announcing are forgotten after DurationKickPeerExpiry.
+type kickCoordinator struct {
+	mu     *sync.RWMutex
+	peers  map[steamid.SID64]kickPeer
+	claims map[steamid.SID64]kickClaim
+	// joinedAt is when we first announced our status, lastStatus when we last did
+	joinedAt   time.Time
+	lastStatus time.Time
+}
+
+func newKickCoordinator() *kickCoordinator {
+	return &kickCoordinator{
+		mu:     &sync.RWMutex{},
+		peers:  map[steamid.SID64]kickPeer{},
+		claims: map[steamid.SID64]kickClaim{},
+	}
+}
+
+// handle records a message from another instance
+func (c *kickCoordinator) handle(msg partyMessage, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	peer := c.peers[msg.sender]
+	peer.lastSeen = now
+	switch msg.kind {
+	case partyStatus:
+		peer.cooldownUntil = now.Add(msg.cooldown)
+	case partyIntent:
+		c.claims[msg.target] = kickClaim{by: msg.sender, at: now}
+		// Calling a vote puts the peer on cooldown, the exact duration follows in their next status
+		peer.cooldownUntil = now.Add(model.DurationKickAttemptInterval)
+	}
+	c.peers[msg.sender] = peer
+}
+
+// elected returns true if we should call the next vote. Only peers on our team, as reported by teammate, can be
+// elected as the others cannot call votes against our targets. With no live peers we are always the caller once
+// we have announced ourselves.
+func (c *kickCoordinator) elected(self steamid.SID64, cooldownUntil time.Time, now time.Time, teammate func(steamid.SID64) bool) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if now.Before(cooldownUntil) || c.joinedAt.IsZero() || now.Sub(c.joinedAt) < model.DurationCheckTimer {
+		return false
+	}
+	for sid64, peer := range c.peers {
+		if sid64 == self || now.Sub(peer.lastSeen) > model.DurationKickPeerExpiry {
+			continue
+		}
+		if !now.Before(peer.cooldownUntil) && sid64 < self && teammate(sid64) {
+			return false
+		}
+	}
+	return true
+}
+
+// claimed returns true if another instance recently called a vote against the target
+func (c *kickCoordinator) claimed(self steamid.SID64, target steamid.SID64, now time.Time) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	claim, found := c.claims[target]
+	return found && claim.by != self && now.Sub(claim.at) < model.DurationKickClaim
+}
+
+// peerCount returns the number of live peers
+func (c *kickCoordinator) peerCount(self steamid.SID64, now time.Time) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	count := 0
+	for sid64, peer := range c.peers {
+		if sid64 != self && now.Sub(peer.lastSeen) <= model.DurationKickPeerExpiry {
+			count++
+		}
+	}
+	return count
+}
+
+// statusDue returns true when our status should be announced again, marking it as sent
+func (c *kickCoordinator) statusDue(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastStatus) < model.DurationKickStatusInterval {
+		return false
+	}
+	if c.joinedAt.IsZero() {
+		c.joinedAt = now
+	}
+	c.lastStatus = now
+	return true
+}
+
+// prune removes expired peers and claims
+func (c *kickCoordinator) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for sid64, peer := range c.peers {
+		if now.Sub(peer.lastSeen) > model.DurationKickPeerExpiry {
+			delete(c.peers, sid64)
+		}
+	}
+	for target, claim := range c.claims {
+		if now.Sub(claim.at) >= model.DurationKickClaim {
+			delete(c.claims, target)
+		}
+	}
+}
+
+// reset forgets all peers and claims when leaving a server
+func (c *kickCoordinator) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.peers = map[steamid.SID64]kickPeer{}
+	c.claims = map[steamid.SID64]kickClaim{}
+	c.joinedAt = time.Time{}
+	c.lastStatus = time.Time{}
+}
+
+// nextKick returns the target we should call a vote against now, or nil when we are on cooldown, another
+// instance is elected to call the next vote, or every target has been claimed by another instance
+func nextKick(self steamid.SID64, kicks *kickScheduler, coordinator *kickCoordinator, teammate func(steamid.SID64) bool,
+	now time.Time) *kickCandidate {
+	coordinator.prune(now)
+	if !coordinator.elected(self, kicks.cooldown(), now, teammate) {
+		return nil
+	}
+	return kicks.next(now, func(target steamid.SID64) bool {
+		return coordinator.claimed(self, target, now)
+	})
+}
+
+// statusMessage returns our status for the other instances
+func statusMessage(self steamid.SID64, kicks *kickScheduler, now time.Time) partyMessage {
+	cooldown := kicks.cooldown().Sub(now)
+	if cooldown < 0 {
+		cooldown = 0
+	}
+	return partyMessage{kind: partyStatus, sender: self, cooldown: cooldown}
+}
+
+// onPartyMessage handles a coordination message from another instance
+func (bd *BD) onPartyMessage(msg partyMessage) {
+	if msg.sender == bd.settings.GetSteamId() {
+		return
+	}
+	bd.logger.Debug("Received kick coordination message", zap.String("kind", string(msg.kind)),
+		zap.Int64("sender", msg.sender.Int64()))
+	bd.coordinator.handle(msg, time.Now())
+}
+
+func (bd *BD) sendPartyMessage(msg partyMessage) {
+	if errSend := bd.SendChat(model.ChatDestParty, "%s", msg.String()); errSend != nil {
+		bd.logger.Error("Failed to send kick coordination message", zap.Error(errSend))
+	}
+}
+
+// announceKickStatus periodically tells the other instances our vote cooldown while we have targets to kick
+func (bd *BD) announceKickStatus(now time.Time) {
+	if bd.kicks.len() == 0 || !bd.coordinator.statusDue(now) {
+		return
+	}
+	bd.sendPartyMessage(statusMessage(bd.settings.GetSteamId(), bd.kicks, now))
+}
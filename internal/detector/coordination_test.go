@@ -0,0 +1,266 @@
+package detector
+
+import (
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestPartyMessage(t *testing.T) {
+	const (
+		sender = steamid.SID64(76561197960265730)
+		target = steamid.SID64(76561197960265731)
+	)
+	status := partyMessage{kind: partyStatus, sender: sender, cooldown: time.Second * 87}
+	require.Equal(t, "bd1 status 76561197960265730 87", status.String())
+	intent := partyMessage{kind: partyIntent, sender: sender, target: target}
+	require.Equal(t, "bd1 intent 76561197960265730 76561197960265731", intent.String())
+	for _, msg := range []partyMessage{status, intent} {
+		parsed, ok := parsePartyMessage(msg.String())
+		require.True(t, ok)
+		require.Equal(t, msg, parsed)
+	}
+	for _, invalid := range []string{
+		"bd1 status 76561197960265730",
+		"bd1 status 76561197960265730 -1",
+		"bd1 status invalid 10",
+		"bd1 intent 76561197960265730 0",
+		"bd1 unknown 76561197960265730 10",
+		"bd2 status 76561197960265730 10",
+		"kick him",
+	} {
+		_, ok := parsePartyMessage(invalid)
+		require.False(t, ok, invalid)
+	}
+}
+
+func TestTrustedPartyMessage(t *testing.T) {
+	const (
+		peer    = steamid.SID64(76561197960265730)
+		spoofer = steamid.SID64(76561197960265731)
+	)
+	players := model.PlayerCollection{model.NewPlayer(peer, "Hassium")}
+	findPlayer := func(name string) *model.Player {
+		for _, player := range players {
+			if player.Name == name {
+				return player
+			}
+		}
+		return nil
+	}
+	parser := newLogParser(zap.NewNop(), nil, nil)
+	received := func(line string) (partyMessage, bool) {
+		var evt model.LogEvent
+		require.NoError(t, parser.parseEvent("02/24/2023 - 23:37:19: "+line, &evt))
+		return trustedPartyMessage(messageEvent{
+			name:     evt.Player,
+			message:  evt.Message,
+			teamOnly: evt.TeamOnly,
+			dead:     evt.Dead,
+			party:    evt.Party,
+		}, findPlayer)
+	}
+	msg, ok := received("(PARTY) Hassium :  bd1 status 76561197960265730 0")
+	require.True(t, ok)
+	require.Equal(t, peer, msg.sender)
+
+	// Only party chat is trusted, and the sender must be the author
+	_, ok = received("Hassium :  bd1 status 76561197960265730 0")
+	require.False(t, ok)
+	_, ok = received("(PARTY) Hassium :  bd1 status 76561197960265729 0")
+	require.False(t, ok)
+	_, ok = received("(PARTY) Unknown :  bd1 status 76561197960265730 0")
+	require.False(t, ok)
+
+	// A player named to look like party chat cannot pass as a peer when talking in all chat
+	players = append(players, model.NewPlayer(spoofer, "(PARTY) Hassium"))
+	_, ok = received("(PARTY) Hassium :  bd1 status 76561197960265730 0")
+	require.False(t, ok)
+	players[1].Name = "(PARTY) *DEAD* Hassium"
+	_, ok = received("(PARTY) *DEAD* Hassium :  bd1 status 76561197960265730 0")
+	require.False(t, ok)
+}
+
+func allTeammates(_ steamid.SID64) bool {
+	return true
+}
+
+// simInstance is a single bd instance of the simulated party
+type simInstance struct {
+	self        steamid.SID64
+	kicks       *kickScheduler
+	coordinator *kickCoordinator
+}
+
+// simParty simulates several bd instances on the same team of a server, exchanging coordination messages over
+// party chat. The server runs one vote at a time and enforces a cooldown between votes called by the same player.
+type simParty struct {
+	instances []*simInstance
+	pending   []string
+	voteEnds  time.Time
+	cooldowns map[steamid.SID64]time.Time
+	calls     []simCall
+}
+
+type simCall struct {
+	at     time.Time
+	caller steamid.SID64
+	target steamid.SID64
+}
+
+const (
+	simVoteDuration   = time.Second * 20
+	simServerCooldown = time.Second * 150
+)
+
+func newSimParty(count int, targets []steamid.SID64, now time.Time) *simParty {
+	party := &simParty{cooldowns: map[steamid.SID64]time.Time{}}
+	for i := 0; i < count; i++ {
+		instance := &simInstance{
+			// Reversed so that the election does not simply follow the order instances are ticked in
+			self:        steamid.SID64(76561197960265800 - int64(i)),
+			kicks:       newKickScheduler(),
+			coordinator: newKickCoordinator(),
+		}
+		for _, target := range targets {
+			instance.kicks.enqueue(kickCandidate{steamID: target, matches: 1, queuedAt: now})
+		}
+		party.instances = append(party.instances, instance)
+	}
+	return party
+}
+
+func (p *simParty) send(msg partyMessage) {
+	p.pending = append(p.pending, msg.String())
+}
+
+// deliver sends the chat of the last tick to every instance, including the sender as the real console does
+func (p *simParty) deliver(now time.Time) {
+	for _, text := range p.pending {
+		msg, ok := parsePartyMessage(text)
+		if !ok {
+			continue
+		}
+		for _, instance := range p.instances {
+			if msg.sender != instance.self {
+				instance.coordinator.handle(msg, now)
+			}
+		}
+	}
+	p.pending = nil
+}
+
+// tick mirrors processKickQueue for every instance. All instances decide before any chat of the tick is
+// delivered, as if they ran at the same moment.
+func (p *simParty) tick(now time.Time) {
+	for _, instance := range p.instances {
+		if instance.kicks.len() > 0 && instance.coordinator.statusDue(now) {
+			p.send(statusMessage(instance.self, instance.kicks, now))
+		}
+		if now.Before(p.voteEnds) {
+			continue
+		}
+		candidate := nextKick(instance.self, instance.kicks, instance.coordinator, allTeammates, now)
+		if candidate == nil {
+			continue
+		}
+		p.send(partyMessage{kind: partyIntent, sender: instance.self, target: candidate.steamID})
+		instance.kicks.attempted(candidate.steamID, now)
+		if now.Before(p.cooldowns[instance.self]) {
+			instance.kicks.setCooldown(p.cooldowns[instance.self])
+		} else {
+			p.calls = append(p.calls, simCall{at: now, caller: instance.self, target: candidate.steamID})
+			p.cooldowns[instance.self] = now.Add(simServerCooldown)
+			instance.kicks.setCooldown(now.Add(simServerCooldown))
+		}
+		p.send(statusMessage(instance.self, instance.kicks, now))
+		// The failed vote leaves the target queued again with one more attempt
+		instance.kicks.enqueue(kickCandidate{steamID: candidate.steamID, matches: 1, attempts: candidate.attempts + 1, queuedAt: now})
+	}
+	for _, call := range p.calls {
+		if call.at.Equal(now) {
+			p.voteEnds = now.Add(simVoteDuration)
+		}
+	}
+	p.deliver(now)
+}
+
+func TestKickCoordination(t *testing.T) {
+	targets := []steamid.SID64{76561197960265901, 76561197960265902, 76561197960265903}
+	t0 := time.Now()
+	party := newSimParty(3, targets, t0)
+	end := t0.Add(time.Minute * 5)
+	for now := t0; now.Before(end); now = now.Add(model.DurationCheckTimer) {
+		party.tick(now)
+	}
+	require.NotEmpty(t, party.calls)
+
+	callers := map[steamid.SID64]int{}
+	for i, call := range party.calls {
+		callers[call.caller]++
+		if i == 0 {
+			continue
+		}
+		previous := party.calls[i-1]
+		// Instances never call over each other, and the next vote targets someone else
+		require.True(t, call.at.Sub(previous.at) >= simVoteDuration, "votes collided")
+		require.NotEqual(t, previous.target, call.target)
+	}
+	// Every instance takes a turn while the others are on cooldown, with the lowest steam id first
+	require.Len(t, callers, len(party.instances))
+	require.Equal(t, party.instances[len(party.instances)-1].self, party.calls[0].caller)
+	// Without waiting for the cooldown of a single caller, all targets are tried within the first cooldown
+	seen := map[steamid.SID64]bool{}
+	for _, call := range party.calls {
+		if call.at.Sub(t0) < simServerCooldown {
+			seen[call.target] = true
+		}
+	}
+	require.Len(t, seen, len(targets))
+}
+
+func TestKickCoordinationSingleInstance(t *testing.T) {
+	t0 := time.Now()
+	party := newSimParty(1, []steamid.SID64{76561197960265901}, t0)
+	party.tick(t0)
+	require.Empty(t, party.calls, "must announce before calling")
+	party.tick(t0.Add(model.DurationCheckTimer))
+	require.Len(t, party.calls, 1)
+}
+
+func TestKickCoordinatorExpiry(t *testing.T) {
+	const (
+		self = steamid.SID64(76561197960265800)
+		peer = steamid.SID64(76561197960265700)
+	)
+	t0 := time.Now()
+	coordinator := newKickCoordinator()
+	require.True(t, coordinator.statusDue(t0))
+	require.False(t, coordinator.statusDue(t0.Add(time.Second)))
+	t1 := t0.Add(model.DurationCheckTimer)
+	require.True(t, coordinator.elected(self, time.Time{}, t1, allTeammates))
+
+	// A ready peer with a lower steam id is elected instead of us, until they stop announcing
+	coordinator.handle(partyMessage{kind: partyStatus, sender: peer}, t1)
+	require.False(t, coordinator.elected(self, time.Time{}, t1, allTeammates))
+	require.Equal(t, 1, coordinator.peerCount(self, t1))
+	// Peers on the other team cannot kick our targets, so they are never elected
+	otherTeam := func(sid64 steamid.SID64) bool { return sid64 != peer }
+	require.True(t, coordinator.elected(self, time.Time{}, t1, otherTeam))
+	t2 := t1.Add(model.DurationKickPeerExpiry + time.Second)
+	require.True(t, coordinator.elected(self, time.Time{}, t2, allTeammates))
+
+	// Targets are claimed by the intent of a peer for a limited time
+	target := steamid.SID64(76561197960265901)
+	coordinator.handle(partyMessage{kind: partyIntent, sender: peer, target: target}, t2)
+	require.True(t, coordinator.claimed(self, target, t2))
+	require.False(t, coordinator.claimed(peer, target, t2))
+	coordinator.prune(t2.Add(model.DurationKickClaim))
+	require.False(t, coordinator.claimed(self, target, t2))
+
+	coordinator.reset()
+	require.False(t, coordinator.elected(self, time.Time{}, t2, allTeammates))
+}
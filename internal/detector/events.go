@@ -26,12 +26,24 @@ const (
 	updateTags
 	updateAddress
 	updateWhitelist
+	updateVoteStart
+	updateVoteCast
+	updateVoteResult
+	updateVoteCooldown
+	updatePlayerDisconnect
 	changeMap
 )
 
 type killEvent struct {
 	sourceName string
-	victimName string
+	// assisterName is set once the source name of an assisted kill has been split, see splitAssist
+	assisterName string
+	victimName   string
+	weapon       string
+	crit         bool
+	// suicide is set for suicides and environmental deaths, where the source is the victim
+	suicide   bool
+	createdAt time.Time
 }
 
 type lobbyEvent struct {
@@ -53,9 +65,10 @@ type updateStateEvent struct {
 }
 
 type updateMarkEvent struct {
-	target steamid.SID64
-	attrs  []string
-	delete bool
+	target   steamid.SID64
+	attrs    []string
+	duration model.MarkDuration
+	delete   bool
 }
 
 type updateWhitelistEvent struct {
@@ -69,6 +82,7 @@ type messageEvent struct {
 	message   string
 	teamOnly  bool
 	dead      bool
+	party     bool
 }
 
 type hostnameEvent struct {
@@ -85,6 +99,36 @@ type tagsEvent struct {
 	tags []string
 }
 
+type voteStartEvent struct {
+	callerName string
+	targetName string
+	createdAt  time.Time
+}
+
+type voteCastEvent struct {
+	name      string
+	yes       bool
+	createdAt time.Time
+}
+
+type voteResultEvent struct {
+	passed bool
+	// yes and no are the final tallies, both 0 when the result did not include them
+	yes int
+	no  int
+}
+
+type voteCooldownEvent struct {
+	// duration is the remaining time until a vote can be called, 0 when the server did not report it
+	duration time.Duration
+}
+
+type playerDisconnectEvent struct {
+	name      string
+	reason    string
+	createdAt time.Time
+}
+
 type addressEvent struct {
 	ip   net.IP
 	port uint16
@@ -122,11 +166,18 @@ func (bd *BD) incomingLogEventHandler(ctx context.Context) {
 				update = updateStateEvent{kind: updateAddress, data: addressEvent{ip: ip, port: uint16(portValue)}}
 			case model.EvtDisconnect:
 				update = updateStateEvent{kind: changeMap, source: evt.PlayerSID, data: mapChangeEvent{}}
-			case model.EvtKill:
+			case model.EvtKill, model.EvtSuicide:
 				update = updateStateEvent{
 					kind:   updateKill,
 					source: evt.PlayerSID,
-					data:   killEvent{victimName: evt.Victim, sourceName: evt.Player},
+					data: killEvent{
+						victimName: evt.Victim,
+						sourceName: evt.Player,
+						weapon:     evt.Weapon,
+						crit:       evt.Crit,
+						suicide:    evt.Suicide,
+						createdAt:  evt.Timestamp,
+					},
 				}
 			case model.EvtMsg:
 				update = updateStateEvent{
@@ -138,6 +189,7 @@ func (bd *BD) incomingLogEventHandler(ctx context.Context) {
 						message:   evt.Message,
 						teamOnly:  evt.TeamOnly,
 						dead:      evt.Dead,
+						party:     evt.Party,
 					},
 				}
 			case model.EvtStatusId:
@@ -154,6 +206,38 @@ func (bd *BD) incomingLogEventHandler(ctx context.Context) {
 				}
 			case model.EvtLobby:
 				update = updateStateEvent{kind: updateLobby, source: evt.PlayerSID, data: lobbyEvent{team: evt.Team}}
+			case model.EvtVoteStart:
+				update = updateStateEvent{
+					kind: updateVoteStart,
+					data: voteStartEvent{callerName: evt.Player, targetName: evt.Victim, createdAt: evt.Timestamp},
+				}
+			case model.EvtVoteCast:
+				update = updateStateEvent{
+					kind: updateVoteCast,
+					data: voteCastEvent{name: evt.Player, yes: evt.MetaData == "yes", createdAt: evt.Timestamp},
+				}
+			case model.EvtVoteResult:
+				update = updateStateEvent{
+					kind: updateVoteResult,
+					data: voteResultEvent{passed: evt.MetaData == "passed", yes: evt.VoteYes, no: evt.VoteNo},
+				}
+			case model.EvtVoteCooldown:
+				// Some failures do not report how long until the next vote can be called, leaving the duration unset
+				var seconds int
+				if evt.MetaData != "" {
+					parsed, errSeconds := strconv.Atoi(evt.MetaData)
+					if errSeconds != nil {
+						bd.logger.Error("Failed to parse vote cooldown", zap.Error(errSeconds), zap.String("seconds", evt.MetaData))
+						continue
+					}
+					seconds = parsed
+				}
+				update = updateStateEvent{kind: updateVoteCooldown, data: voteCooldownEvent{duration: time.Duration(seconds) * time.Second}}
+			case model.EvtPlayerDisconnect:
+				update = updateStateEvent{
+					kind: updatePlayerDisconnect,
+					data: playerDisconnectEvent{name: evt.Player, reason: evt.MetaData, createdAt: evt.Timestamp},
+				}
 			}
 			bd.gameStateUpdate <- update
 		}
@@ -0,0 +1,242 @@
+package detector
+
+import (
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// kickCandidate is a pending request to kick a matched player
+type kickCandidate struct {
+	steamID steamid.SID64
+	userID  int64
+	name    string
+	// attempts is the number of kick votes already called against the player
+	attempts int
+	// matches and kills rank how valuable kicking the player is, the more lists and rules agreeing on a player
+	// the more confident the match is, and the more kills the more disruptive they are
+	matches  int
+	kills    int
+	queuedAt time.Time
+}
+
+// kickScheduler holds a prioritised queue of players waiting to be vote kicked. Players which have had the fewest
+// kick votes against them are chosen first so that the same target is not attempted over and over, then the most
+// valuable. Only one vote can be called at a time, and the server limits how often we can call votes, so no
+// target is returned while on cooldown.
+type kickScheduler struct {
+	mu            *sync.Mutex
+	queue         map[steamid.SID64]*kickCandidate
+	cooldownUntil time.Time
+	// lastCall is when the last vote we called started, used to learn the cooldown of the server
+	lastCall time.Time
+	// learnedCooldown is how long the server makes us wait between calling votes, 0 until it has reported it
+	learnedCooldown time.Duration
+}
+
+func newKickScheduler() *kickScheduler {
+	return &kickScheduler{mu: &sync.Mutex{}, queue: map[steamid.SID64]*kickCandidate{}}
+}
+
+// enqueue adds the player to the queue, or refreshes their details if they are already queued
+func (k *kickScheduler) enqueue(candidate kickCandidate) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if existing, found := k.queue[candidate.steamID]; found {
+		candidate.queuedAt = existing.queuedAt
+	}
+	k.queue[candidate.steamID] = &candidate
+}
+
+func (k *kickScheduler) remove(steamID steamid.SID64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.queue, steamID)
+}
+
+// setCooldown prevents calling votes until the time provided. An existing longer cooldown is kept.
+func (k *kickScheduler) setCooldown(until time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if until.After(k.cooldownUntil) {
+		k.cooldownUntil = until
+	}
+}
+
+// cooldown returns the time until which no votes can be called
+func (k *kickScheduler) cooldown() time.Time {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.cooldownUntil
+}
+
+func (k *kickScheduler) len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.queue)
+}
+
+// next returns the best candidate to kick, or nil when on cooldown or the queue is empty. Candidates for which
+// skip returns true are passed over without being removed, skip may be nil.
+func (k *kickScheduler) next(now time.Time, skip func(steamid.SID64) bool) *kickCandidate {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if now.Before(k.cooldownUntil) {
+		return nil
+	}
+	candidates := make([]kickCandidate, 0, len(k.queue))
+	for _, candidate := range k.queue {
+		if skip != nil && skip(candidate.steamID) {
+			continue
+		}
+		candidates = append(candidates, *candidate)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.attempts != b.attempts {
+			return a.attempts < b.attempts
+		}
+		if a.matches != b.matches {
+			return a.matches > b.matches
+		}
+		if a.kills != b.kills {
+			return a.kills > b.kills
+		}
+		if !a.queuedAt.Equal(b.queuedAt) {
+			return a.queuedAt.Before(b.queuedAt)
+		}
+		return a.steamID < b.steamID
+	})
+	return &candidates[0]
+}
+
+// attempted removes the candidate once a vote has been called against them and waits for the cooldown learned
+// from the server. Until the server has reported its cooldown, DurationKickAttemptInterval is used instead, giving
+// the server time to report the vote or a cooldown.
+func (k *kickScheduler) attempted(steamID steamid.SID64, now time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.queue, steamID)
+	wait := model.DurationKickAttemptInterval
+	if k.learnedCooldown > wait {
+		wait = k.learnedCooldown
+	}
+	if until := now.Add(wait); until.After(k.cooldownUntil) {
+		k.cooldownUntil = until
+	}
+}
+
+// called records that a vote we called has started, which starts the cooldown of the server
+func (k *kickScheduler) called(now time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.lastCall = now
+}
+
+// learnCooldown handles the server refusing to let us call a vote. The remaining time reported by the server is
+// waited out, and the time since our last vote started is added to it to learn the full cooldown used for later
+// calls. When the server does not report the remaining time, DurationKickAttemptInterval is waited instead.
+func (k *kickScheduler) learnCooldown(remaining time.Duration, now time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if remaining <= 0 {
+		remaining = model.DurationKickAttemptInterval
+	} else if !k.lastCall.IsZero() && !now.Before(k.lastCall) {
+		k.learnedCooldown = (now.Sub(k.lastCall) + remaining).Round(time.Second)
+	}
+	if until := now.Add(remaining); until.After(k.cooldownUntil) {
+		k.cooldownUntil = until
+	}
+}
+
+// reset clears the queue and cooldown when leaving a server, as the next server may use a different cooldown
+func (k *kickScheduler) reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.queue = map[steamid.SID64]*kickCandidate{}
+	k.cooldownUntil = time.Time{}
+	k.lastCall = time.Time{}
+	k.learnedCooldown = 0
+}
+
+// queueKick adds the player to the kick queue. The caller must hold the player lock and have checked that the
+// player is on our team.
+func (bd *BD) queueKick(ps *model.Player, matches int) {
+	if ps.SteamId == bd.settings.GetSteamId() {
+		return
+	}
+	bd.kicks.enqueue(kickCandidate{
+		steamID:  ps.SteamId,
+		userID:   ps.UserId,
+		name:     ps.Name,
+		attempts: ps.KickAttemptCount,
+		matches:  matches,
+		kills:    ps.Kills,
+		queuedAt: time.Now(),
+	})
+}
+
+// kickable checks that a queued player can still be kicked
+func (bd *BD) kickable(ps *model.Player) bool {
+	if ps == nil || ps.IsDisconnected() || !bd.teammate(ps.SteamId) {
+		return false
+	}
+	ps.RLock()
+	defer ps.RUnlock()
+	return !ps.Whitelisted && len(ps.Matches) > 0
+}
+
+// processKickQueue calls a vote against the best queued candidate, unless a vote is already running, we are
+// on cooldown or another bd instance in our party is due to call the next vote
+func (bd *BD) processKickQueue() {
+	now := time.Now()
+	if !bd.settings.GetKickerEnabled() {
+		return
+	}
+	bd.announceKickStatus(now)
+	if bd.votes.running(now) {
+		return
+	}
+	self := bd.settings.GetSteamId()
+	for {
+		candidate := nextKick(self, bd.kicks, bd.coordinator, bd.teammate, now)
+		if candidate == nil {
+			return
+		}
+		ps := bd.GetPlayer(candidate.steamID)
+		if !bd.kickable(ps) {
+			bd.kicks.remove(candidate.steamID)
+			continue
+		}
+		bd.logger.Info("Calling kick vote", zap.String("name", candidate.name),
+			zap.Int64("steam_id", candidate.steamID.Int64()), zap.Int("attempts", candidate.attempts),
+			zap.Int("peers", bd.coordinator.peerCount(self, now)))
+		bd.sendPartyMessage(partyMessage{kind: partyIntent, sender: self, target: candidate.steamID})
+		if errVote := bd.CallVote(candidate.userID, model.KickReasonCheating); errVote != nil {
+			bd.logger.Error("Error calling vote", zap.Error(errVote))
+		}
+		// Failed calls are still counted so that a target which cannot be kicked does not block the queue
+		ps.Lock()
+		ps.KickAttemptCount++
+		ps.Unlock()
+		bd.kicks.attempted(candidate.steamID, now)
+		bd.sendPartyMessage(statusMessage(self, bd.kicks, now))
+		return
+	}
+}
+
+// onVoteCooldown learns the vote cooldown of the server from the message shown when a vote call fails
+func (bd *BD) onVoteCooldown(evt voteCooldownEvent) {
+	bd.logger.Debug("Vote cooldown", zap.Duration("duration", evt.duration))
+	now := time.Now()
+	bd.kicks.learnCooldown(evt.duration, now)
+	if bd.settings.GetKickerEnabled() {
+		bd.sendPartyMessage(statusMessage(bd.settings.GetSteamId(), bd.kicks, now))
+	}
+}
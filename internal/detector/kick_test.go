@@ -0,0 +1,170 @@
+package detector
+
+import (
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/pkg/rules"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKickScheduler(t *testing.T) {
+	const (
+		player1 = steamid.SID64(76561197960265730)
+		player2 = steamid.SID64(76561197960265731)
+		player3 = steamid.SID64(76561197960265732)
+	)
+	t0 := time.Now()
+	scheduler := newKickScheduler()
+	require.Nil(t, scheduler.next(t0, nil))
+
+	scheduler.enqueue(kickCandidate{steamID: player1, matches: 1, kills: 10, queuedAt: t0})
+	scheduler.enqueue(kickCandidate{steamID: player2, matches: 2, queuedAt: t0})
+	scheduler.enqueue(kickCandidate{steamID: player3, matches: 2, kills: 5, attempts: 1, queuedAt: t0})
+	// More matches outrank kills, but players with fewer attempts are always tried first
+	require.Equal(t, player2, scheduler.next(t0, nil).steamID)
+
+	scheduler.attempted(player2, t0)
+	require.Nil(t, scheduler.next(t0, nil))
+	require.Nil(t, scheduler.next(t0.Add(model.DurationKickAttemptInterval-time.Second), nil))
+	t1 := t0.Add(model.DurationKickAttemptInterval)
+	require.Equal(t, player1, scheduler.next(t1, nil).steamID)
+
+	// The attempted player is queued again with their new attempt count and rotated behind the others
+	scheduler.enqueue(kickCandidate{steamID: player2, matches: 2, attempts: 1, queuedAt: t1})
+	scheduler.enqueue(kickCandidate{steamID: player1, matches: 1, kills: 10, attempts: 2, queuedAt: t1})
+	require.Equal(t, player3, scheduler.next(t1, nil).steamID)
+
+	// Learned cooldowns extend but never shorten the wait
+	scheduler.setCooldown(t1.Add(time.Minute))
+	scheduler.setCooldown(t1.Add(time.Second))
+	require.Nil(t, scheduler.next(t1.Add(time.Second*30), nil))
+	require.NotNil(t, scheduler.next(t1.Add(time.Minute), nil))
+
+	scheduler.remove(player3)
+	require.Equal(t, player2, scheduler.next(t1.Add(time.Minute), nil).steamID)
+	scheduler.reset()
+	require.Nil(t, scheduler.next(t1, nil))
+}
+
+func TestKickSchedulerLearnCooldown(t *testing.T) {
+	const player = steamid.SID64(76561197960265730)
+	t0 := time.Now()
+	scheduler := newKickScheduler()
+
+	// Without a reported duration the fixed interval is used
+	scheduler.learnCooldown(0, t0)
+	require.Equal(t, t0.Add(model.DurationKickAttemptInterval), scheduler.cooldown())
+
+	// Our vote starts the server cooldown, calling again too soon reports the remaining time which is waited out
+	t1 := t0.Add(model.DurationKickAttemptInterval)
+	scheduler.called(t1)
+	t2 := t1.Add(time.Second * 30)
+	scheduler.learnCooldown(time.Second*120, t2)
+	require.Equal(t, t2.Add(time.Second*120), scheduler.cooldown())
+
+	// Later calls wait for the full learned cooldown rather than the fixed interval
+	t3 := t2.Add(time.Second * 120)
+	scheduler.enqueue(kickCandidate{steamID: player, matches: 1, queuedAt: t3})
+	require.Equal(t, player, scheduler.next(t3, nil).steamID)
+	scheduler.attempted(player, t3)
+	require.Equal(t, t3.Add(time.Second*150), scheduler.cooldown())
+
+	// A new server may use a different cooldown
+	scheduler.reset()
+	scheduler.attempted(player, t3)
+	require.Equal(t, t3.Add(model.DurationKickAttemptInterval), scheduler.cooldown())
+}
+
+type recordingRcon struct {
+	mu       *sync.Mutex
+	commands []string
+}
+
+func (r *recordingRcon) Exec(command string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, command)
+	return "", nil
+}
+
+func (r *recordingRcon) Close() error {
+	return nil
+}
+
+func (r *recordingRcon) count(prefix string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := 0
+	for _, command := range r.commands {
+		if strings.HasPrefix(command, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// TestProcessKickQueueConcurrent exercises the kick queue from the check and game state goroutines at the same
+// time, run with -race to detect unguarded player state
+func TestProcessKickQueueConcurrent(t *testing.T) {
+	const (
+		self    = steamid.SID64(76561197960265730)
+		cheater = steamid.SID64(76561197960265731)
+	)
+	settings, errSettings := model.NewSettings()
+	require.NoError(t, errSettings)
+	settings.SetSteamID(self.String())
+	settings.SetKickerEnabled(true)
+	settings.SetPartyWarningsEnabled(false)
+	conn := &recordingRcon{mu: &sync.Mutex{}}
+	bd := &BD{
+		logger:            zap.NewNop(),
+		settings:          settings,
+		players:           model.PlayerCollection{model.NewPlayer(self, "us"), model.NewPlayer(cheater, "cheater")},
+		playersMu:         &sync.RWMutex{},
+		rconConnection:    conn,
+		gameProcessActive: &atomic.Bool{},
+		voiceMutes:        newVoiceMuteList(),
+		votes:             newVoteTracker(),
+		kicks:             newKickScheduler(),
+		coordinator:       newKickCoordinator(),
+	}
+	bd.gameProcessActive.Store(true)
+	ps := bd.GetPlayer(cheater)
+	matches := rules.MatchResults{{Origin: "test", Attributes: []string{"cheater"}, MatcherType: "steam"}}
+	ps.Matches = matches
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			bd.triggerMatch(ps, matches)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			bd.onUpdateLobby(self, lobbyEvent{team: model.Red})
+			bd.onUpdateLobby(cheater, lobbyEvent{team: model.Red})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			bd.processKickQueue()
+		}
+	}()
+	wg.Wait()
+
+	// Ensure a vote is called even if the queue was processed before the match was queued
+	bd.triggerMatch(ps, matches)
+	bd.coordinator.joinedAt = time.Now().Add(-model.DurationCheckTimer)
+	bd.processKickQueue()
+	require.Equal(t, 1, conn.count("callvote kick"))
+}
@@ -0,0 +1,105 @@
+package detector
+
+import (
+	"context"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+// weaponAnomalyReports remembers which suspicious weapon stats have been reported so that each one is only
+// logged once per session
+type weaponAnomalyReports struct {
+	mu       *sync.Mutex
+	reported map[steamid.SID64]map[string]bool
+}
+
+func newWeaponAnomalyReports() *weaponAnomalyReports {
+	return &weaponAnomalyReports{mu: &sync.Mutex{}, reported: map[steamid.SID64]map[string]bool{}}
+}
+
+// add returns the anomalies which have not been reported yet, marking them as reported
+func (r *weaponAnomalyReports) add(steamID steamid.SID64, anomalies model.WeaponStatsCollection) model.WeaponStatsCollection {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unreported model.WeaponStatsCollection
+	for _, anomaly := range anomalies {
+		if r.reported[steamID][anomaly.Weapon] {
+			continue
+		}
+		if r.reported[steamID] == nil {
+			r.reported[steamID] = map[string]bool{}
+		}
+		r.reported[steamID][anomaly.Weapon] = true
+		unreported = append(unreported, anomaly)
+	}
+	return unreported
+}
+
+// assistSeparator joins the killer and assister names of assisted kills in the kill feed
+const assistSeparator = " + "
+
+// splitAssist returns the killer and assister of a kill feed source name. The kill feed names assisted kills
+// "Killer + Assister", and as player names may contain the separator themselves, the name is only split when it
+// does not belong to a player and both halves do. The assister is empty when the kill was not assisted.
+func splitAssist(name string, known func(name string) bool) (string, string) {
+	if known(name) {
+		return name, ""
+	}
+	for offset := 0; offset < len(name); {
+		idx := strings.Index(name[offset:], assistSeparator)
+		if idx < 0 {
+			break
+		}
+		idx += offset
+		killer, assister := name[:idx], name[idx+len(assistSeparator):]
+		if known(killer) && known(assister) {
+			return killer, assister
+		}
+		offset = idx + 1
+	}
+	return name, ""
+}
+
+// saveKill persists the kill feed entry, then checks the weapon stats of the killer when it was a headshot
+func (bd *BD) saveKill(ctx context.Context, kill killEvent, source steamid.SID64, assister steamid.SID64, target steamid.SID64) {
+	entry := model.Kill{
+		KillerSID:    source,
+		KillerName:   kill.sourceName,
+		AssisterSID:  assister,
+		AssisterName: kill.assisterName,
+		VictimSID:    target,
+		VictimName:   kill.victimName,
+		Weapon:       kill.weapon,
+		Crit:         kill.crit,
+		Suicide:      kill.suicide,
+		CreatedOn:    kill.createdAt,
+	}
+	if entry.CreatedOn.IsZero() {
+		entry.CreatedOn = time.Now()
+	}
+	bd.serverMu.RLock()
+	entry.ServerName = bd.server.ServerName
+	entry.MapName = bd.server.CurrentMap
+	bd.serverMu.RUnlock()
+	if errSave := bd.store.SaveKill(ctx, &entry); errSave != nil {
+		bd.logger.Error("Failed to save kill", zap.Error(errSave))
+		return
+	}
+	if !entry.Crit || entry.Suicide || !model.IsHeadshotWeapon(entry.Weapon) {
+		return
+	}
+	stats, errStats := bd.store.FetchWeaponStats(ctx, source)
+	if errStats != nil {
+		bd.logger.Error("Failed to fetch weapon stats", zap.Error(errStats))
+		return
+	}
+	for _, anomaly := range bd.weaponAnomalies.add(source, stats.Anomalies()) {
+		bd.logger.Warn("Suspicious headshot rate", zap.String("name", entry.KillerName),
+			zap.Int64("steam_id", source.Int64()), zap.String("weapon", anomaly.Weapon),
+			zap.Int("kills", anomaly.Kills), zap.Float64("headshot_rate", anomaly.CritRate()))
+	}
+}
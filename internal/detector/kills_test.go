@@ -0,0 +1,48 @@
+package detector
+
+import (
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSplitAssist(t *testing.T) {
+	players := map[string]bool{"Hassium": true, "❤ Ashley ❤": true, "A + B": true, "C": true}
+	known := func(name string) bool { return players[name] }
+	for _, testCase := range []struct {
+		name     string
+		killer   string
+		assister string
+	}{
+		{name: "Hassium", killer: "Hassium"},
+		{name: "Hassium + ❤ Ashley ❤", killer: "Hassium", assister: "❤ Ashley ❤"},
+		// Names containing the separator are only split where both halves are players
+		{name: "A + B", killer: "A + B"},
+		{name: "A + B + C", killer: "A + B", assister: "C"},
+		{name: "C + A + B", killer: "C", assister: "A + B"},
+		{name: "unknown + Hassium", killer: "unknown + Hassium"},
+	} {
+		killer, assister := splitAssist(testCase.name, known)
+		require.Equal(t, testCase.killer, killer, testCase.name)
+		require.Equal(t, testCase.assister, assister, testCase.name)
+	}
+}
+
+func TestWeaponAnomalies(t *testing.T) {
+	const player = steamid.SID64(76561197960265730)
+	stats := model.WeaponStatsCollection{
+		{Weapon: "sniperrifle", Kills: model.AnomalyMinKills, Crits: model.AnomalyMinKills},
+		{Weapon: "machina", Kills: model.AnomalyMinKills - 1, Crits: model.AnomalyMinKills - 1},
+		{Weapon: "awper_hand", Kills: 40, Crits: 30},
+		// Backstabs are always crits
+		{Weapon: "knife", Kills: 50, Crits: 50},
+	}
+	anomalies := stats.Anomalies()
+	require.Equal(t, stats[:1], anomalies)
+
+	reports := newWeaponAnomalyReports()
+	require.Equal(t, anomalies, reports.add(player, anomalies))
+	require.Empty(t, reports.add(player, anomalies))
+	require.Equal(t, anomalies, reports.add(player+1, anomalies))
+}
@@ -1,8 +1,13 @@
 package detector
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"github.com/fsnotify/fsnotify"
+	"github.com/leighmacdonald/bd/internal/cache"
 	"github.com/leighmacdonald/bd/internal/model"
 	"github.com/leighmacdonald/bd/pkg/rules"
 	"github.com/leighmacdonald/bd/pkg/util"
@@ -10,7 +15,11 @@ import (
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"sync"
 	"time"
 )
@@ -22,58 +31,299 @@ func fixSteamIdFormat(body []byte) []byte {
 	return r.ReplaceAll(body, []byte("\"steamid\": \"$2\""))
 }
 
-func downloadLists(ctx context.Context, logger *zap.Logger, lists model.ListConfigCollection) ([]rules.PlayerListSchema, []rules.RuleSchema) {
-	fetchURL := func(ctx context.Context, client http.Client, url string) ([]byte, error) {
+// maxListRetries is the number of times a failed list download is retried
+const maxListRetries = 3
+
+// listRetryBackoff is the delay before the first retry of a failed list download, doubling for each attempt
+var listRetryBackoff = model.DurationListRetryBackoff
+
+// listValidators holds the http cache validators of a previously downloaded list
+type listValidators struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+}
+
+func listCacheKey(url string) string {
+	hash := sha1.New()
+	hash.Write([]byte(url))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// readCachedList returns the cached body and validators of the list if they exist
+func readCachedList(listCache cache.Cache, url string) ([]byte, listValidators, bool) {
+	var (
+		key        = listCacheKey(url)
+		validators listValidators
+		body       bytes.Buffer
+		meta       bytes.Buffer
+	)
+	if errBody := listCache.Get(cache.TypeLists, key+".json", &body); errBody != nil {
+		return nil, validators, false
+	}
+	if errMeta := listCache.Get(cache.TypeLists, key+".meta.json", &meta); errMeta == nil {
+		if errDecode := json.Unmarshal(meta.Bytes(), &validators); errDecode != nil || validators.URL != url {
+			validators = listValidators{}
+		}
+	}
+	return body.Bytes(), validators, true
+}
+
+func writeCachedList(listCache cache.Cache, body []byte, validators listValidators) error {
+	key := listCacheKey(validators.URL)
+	if errSet := listCache.Set(cache.TypeLists, key+".json", bytes.NewReader(body)); errSet != nil {
+		return errors.Wrap(errSet, "Failed to cache list body")
+	}
+	meta, errEncode := json.Marshal(validators)
+	if errEncode != nil {
+		return errors.Wrap(errEncode, "Failed to encode list validators")
+	}
+	if errSet := listCache.Set(cache.TypeLists, key+".meta.json", bytes.NewReader(meta)); errSet != nil {
+		return errors.Wrap(errSet, "Failed to cache list validators")
+	}
+	return nil
+}
+
+var (
+	// listSchemeRx matches the scheme of a list url, see RFC 3986
+	listSchemeRx = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+	// listDrivePathRx matches windows paths starting with a drive letter, which would otherwise parse as a scheme
+	listDrivePathRx = regexp.MustCompile(`^[a-zA-Z]:([\\/]|$)`)
+)
+
+// localListPath returns the filesystem path for lists configured with a file:// url or a plain path. Lists
+// using any scheme other than file, http and https return an error.
+func localListPath(listURL string) (string, bool, error) {
+	if listURL == "" {
+		return "", false, errors.New("Empty list url")
+	}
+	if listDrivePathRx.MatchString(listURL) || !listSchemeRx.MatchString(listURL) {
+		return listURL, true, nil
+	}
+	parsed, errParse := url.Parse(listURL)
+	if errParse != nil {
+		return "", false, errors.Wrapf(errParse, "Invalid list url: %s", listURL)
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		return "", false, nil
+	case "file":
+		filePath := parsed.Path
+		if parsed.Host != "" && parsed.Host != "localhost" {
+			// UNC path, file://server/share/list.json
+			filePath = "//" + parsed.Host + filePath
+		} else if runtime.GOOS == "windows" && len(filePath) > 2 && filePath[0] == '/' && filePath[2] == ':' {
+			// file:///C:/lists/list.json
+			filePath = filePath[1:]
+		}
+		return filepath.FromSlash(filePath), true, nil
+	default:
+		return "", false, errors.Errorf("Unsupported list url scheme: %s", parsed.Scheme)
+	}
+}
+
+// listResult describes the outcome of updating a single list
+type listResult struct {
+	url string
+	// parsed is true when the list body was parsed, count is only valid when set
+	parsed    bool
+	count     int
+	dropped   int
+	unchanged bool
+	duration  time.Duration
+	err       error
+}
+
+// errListStatus is returned for responses which do not contain a list
+type errListStatus struct {
+	statusCode int
+	status     string
+}
+
+func (e errListStatus) Error() string {
+	return "Invalid response status: " + e.status
+}
+
+// retryable returns true for server side errors which may succeed if repeated
+func (e errListStatus) retryable() bool {
+	return e.statusCode == http.StatusTooManyRequests || e.statusCode >= http.StatusInternalServerError
+}
+
+// downloadLists fetches the enabled lists using conditional requests against the copies stored in the cache.
+// Lists configured with a file:// url or a plain filesystem path are read from disk instead.
+// Lists which are unchanged and where isLoaded returns true for the list url are omitted from the results.
+// Failed requests are retried up to maxListRetries times with an exponential backoff, after which the
+// cached copy is used instead when available.
+//
+// Whitelists are returned separately from the player lists as they share the same format.
+//
+// A listResult is returned for every list that was attempted.
+func downloadLists(ctx context.Context, logger *zap.Logger, listCache cache.Cache, lists model.ListConfigCollection,
+	isLoaded func(url string) bool) ([]rules.PlayerListSchema, []rules.RuleSchema, []rules.PlayerListSchema, []listResult) {
+	// fetchOnce downloads the list, returning the body along with the validators to cache it with once parsed
+	fetchOnce := func(ctx context.Context, client http.Client, url string, cachedBody []byte, validators listValidators) ([]byte, listValidators, bool, error) {
 		timeout, cancel := context.WithTimeout(ctx, model.DurationWebRequestTimeout)
 		defer cancel()
 		req, reqErr := http.NewRequestWithContext(timeout, "GET", url, nil)
 		if reqErr != nil {
-			return nil, errors.Wrap(reqErr, "Failed to create request\n")
+			return nil, validators, false, errors.Wrap(reqErr, "Failed to create request\n")
+		}
+		if cachedBody != nil {
+			if validators.ETag != "" {
+				req.Header.Set("If-None-Match", validators.ETag)
+			}
+			if validators.LastModified != "" {
+				req.Header.Set("If-Modified-Since", validators.LastModified)
+			}
 		}
 		resp, errResp := client.Do(req)
 		if errResp != nil {
-			return nil, errors.Wrapf(errResp, "Failed to download urlLocation: %s\n", url)
+			return nil, validators, false, errors.Wrapf(errResp, "Failed to download urlLocation: %s\n", url)
+		}
+		defer util.LogClose(logger, resp.Body)
+		if resp.StatusCode == http.StatusNotModified && cachedBody != nil {
+			return cachedBody, validators, false, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, validators, false, errListStatus{statusCode: resp.StatusCode, status: resp.Status}
 		}
 		body, errBody := io.ReadAll(resp.Body)
 		if errBody != nil {
-			return nil, errors.Wrapf(errBody, "Failed to read body: %s\n", url)
+			return nil, validators, false, errors.Wrapf(errBody, "Failed to read body: %s\n", url)
+		}
+		newValidators := listValidators{
+			URL:          url,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		return body, newValidators, true, nil
+	}
+	// fetchFile reads a list from the local filesystem. The file modification time is used as the
+	// validator so unchanged files are not parsed again.
+	fetchFile := func(filePath string, url string, cachedBody []byte, validators listValidators) ([]byte, listValidators, bool, error) {
+		info, errStat := os.Stat(filePath)
+		if errStat != nil {
+			return nil, validators, false, errors.Wrapf(errStat, "Failed to read list file: %s", filePath)
+		}
+		modTime := info.ModTime().UTC().Format(time.RFC3339Nano)
+		if cachedBody != nil && validators.LastModified == modTime {
+			return cachedBody, validators, false, nil
+		}
+		body, errRead := os.ReadFile(filePath)
+		if errRead != nil {
+			return nil, validators, false, errors.Wrapf(errRead, "Failed to read list file: %s", filePath)
+		}
+		return body, listValidators{URL: url, LastModified: modTime}, true, nil
+	}
+	// fetchURL returns the current list body, the validators of the body and if it differs from the cached copy.
+	// Modified bodies are not cached here, they are only cached once they have been parsed successfully so that
+	// a broken update is downloaded again rather than reported as unchanged.
+	fetchURL := func(ctx context.Context, client http.Client, url string) ([]byte, listValidators, bool, error) {
+		cachedBody, validators, cached := readCachedList(listCache, url)
+		filePath, isLocal, errLocation := localListPath(url)
+		if errLocation != nil {
+			return nil, validators, false, errLocation
+		}
+		backoff := listRetryBackoff
+		for attempt := 0; ; attempt++ {
+			var (
+				body          []byte
+				newValidators listValidators
+				modified      bool
+				errFetch      error
+			)
+			if isLocal {
+				body, newValidators, modified, errFetch = fetchFile(filePath, url, cachedBody, validators)
+			} else {
+				body, newValidators, modified, errFetch = fetchOnce(ctx, client, url, cachedBody, validators)
+			}
+			if errFetch == nil {
+				return body, newValidators, modified, nil
+			}
+			var errStatus errListStatus
+			if isLocal || attempt == maxListRetries || (errors.As(errFetch, &errStatus) && !errStatus.retryable()) {
+				if cached {
+					logger.Warn("Failed to download list, using cached copy", zap.String("url", url), zap.Error(errFetch))
+					return cachedBody, validators, false, errFetch
+				}
+				return nil, validators, false, errFetch
+			}
+			logger.Debug("Retrying list download", zap.String("url", url),
+				zap.Int("attempt", attempt+1), zap.Duration("backoff", backoff), zap.Error(errFetch))
+			select {
+			case <-ctx.Done():
+				return nil, validators, false, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
 		}
-		defer util.LogClose(logger, resp.Body)
-		return body, nil
 	}
 	var playerLists []rules.PlayerListSchema
 	var rulesLists []rules.RuleSchema
+	var whitelists []rules.PlayerListSchema
+	var results []listResult
 	mu := &sync.RWMutex{}
 	client := http.Client{}
-	downloadFn := func(u *model.ListConfig) error {
+	downloadFn := func(u *model.ListConfig) listResult {
 		start := time.Now()
-		body, errFetch := fetchURL(ctx, client, u.URL)
+		result := listResult{url: u.URL}
+		body, validators, modified, errFetch := fetchURL(ctx, client, u.URL)
 		if errFetch != nil {
-			return errors.Wrapf(errFetch, "Failed to fetch player list: %s", u.URL)
+			result.err = errors.Wrapf(errFetch, "Failed to fetch list: %s", u.URL)
+			if body == nil {
+				result.duration = time.Since(start)
+				return result
+			}
 		}
+		if !modified && isLoaded(u.URL) {
+			logger.Debug("List unchanged", zap.String("url", u.URL))
+			result.unchanged = true
+			result.duration = time.Since(start)
+			return result
+		}
+		rawBody := body
 		body = fixSteamIdFormat(body)
-		dur := time.Since(start)
 		switch u.ListType {
-		case model.ListTypeTF2BDPlayerList:
-			var result rules.PlayerListSchema
-			if errParse := json.Unmarshal(body, &result); errParse != nil {
-				return errors.Wrap(errParse, "Failed to parse request")
+		case model.ListTypeTF2BDPlayerList, model.ListTypeTF2BDWhitelist:
+			list, dropped, errParse := rules.ParsePlayerList(body, !u.Strict)
+			if errParse != nil {
+				result.err = errors.Wrap(errParse, "Failed to parse player list")
+				break
 			}
+			list.SourceURL = u.URL
+			result.parsed = true
+			result.count = len(list.Players)
+			result.dropped = dropped
 			mu.Lock()
-			playerLists = append(playerLists, result)
+			if u.ListType == model.ListTypeTF2BDWhitelist {
+				whitelists = append(whitelists, list)
+			} else {
+				playerLists = append(playerLists, list)
+			}
 			mu.Unlock()
-			logger.Info("Downloaded players successfully", zap.Duration("duration", dur), zap.String("name", result.FileInfo.Title))
+			logger.Info("Downloaded players successfully", zap.Duration("duration", time.Since(start)), zap.String("name", list.FileInfo.Title))
 		case model.ListTypeTF2BDRules:
-			var result rules.RuleSchema
-			if errParse := json.Unmarshal(body, &result); errParse != nil {
-				return errors.Wrap(errParse, "Failed to parse request")
+			list, dropped, errParse := rules.ParseRuleList(body, !u.Strict)
+			if errParse != nil {
+				result.err = errors.Wrap(errParse, "Failed to parse rules list")
+				break
 			}
+			list.SourceURL = u.URL
+			result.parsed = true
+			result.count = len(list.Rules)
+			result.dropped = dropped
 			mu.Lock()
-			rulesLists = append(rulesLists, result)
+			rulesLists = append(rulesLists, list)
 			mu.Unlock()
-			logger.Info("Downloaded rules successfully", zap.Duration("duration", dur), zap.String("name", result.FileInfo.Title))
+			logger.Info("Downloaded rules successfully", zap.Duration("duration", time.Since(start)), zap.String("name", list.FileInfo.Title))
 		}
-		return nil
+		if result.parsed && modified {
+			if errCache := writeCachedList(listCache, rawBody, validators); errCache != nil {
+				logger.Error("Failed to cache list", zap.String("url", u.URL), zap.Error(errCache))
+			}
+		}
+		result.duration = time.Since(start)
+		return result
 	}
 	wg := &sync.WaitGroup{}
 	for _, listConfig := range lists {
@@ -83,11 +333,121 @@ func downloadLists(ctx context.Context, logger *zap.Logger, lists model.ListConf
 		wg.Add(1)
 		go func(lc *model.ListConfig) {
 			defer wg.Done()
-			if errDL := downloadFn(lc); errDL != nil {
-				logger.Error("Failed to download list: %v", zap.Error(errDL))
+			result := downloadFn(lc)
+			if result.err != nil {
+				logger.Error("Failed to download list", zap.String("name", lc.Name), zap.Error(result.err))
+			}
+			if result.dropped > 0 {
+				logger.Warn("Dropped invalid list entries", zap.String("name", lc.Name), zap.Int("count", result.dropped))
 			}
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
 		}(listConfig)
 	}
 	wg.Wait()
-	return playerLists, rulesLists
+	return playerLists, rulesLists, whitelists, results
+}
+
+// listWatcher watches the enabled lists which are loaded from the local filesystem and reloads them
+// when their files change.
+func (bd *BD) listWatcher(ctx context.Context) {
+	defer bd.logger.Debug("listWatcher exited")
+	watcher, errWatcher := fsnotify.NewWatcher()
+	if errWatcher != nil {
+		bd.logger.Error("Failed to create list watcher", zap.Error(errWatcher))
+		return
+	}
+	defer util.LogClose(bd.logger, watcher)
+	watchedDirs := map[string]bool{}
+	// syncWatches updates the watched paths to match the current settings, returning the list configs
+	// keyed by their absolute file path. Parent directories are watched instead of the files themselves
+	// so that editors which replace the file when saving are handled.
+	syncWatches := func() map[string]*model.ListConfig {
+		files := map[string]*model.ListConfig{}
+		dirs := map[string]bool{}
+		for _, listConfig := range bd.settings.GetLists() {
+			if !listConfig.Enabled {
+				continue
+			}
+			filePath, isLocal, errLocation := localListPath(listConfig.URL)
+			if errLocation != nil || !isLocal {
+				continue
+			}
+			absPath, errAbs := filepath.Abs(filePath)
+			if errAbs != nil {
+				continue
+			}
+			files[absPath] = listConfig
+			dirs[filepath.Dir(absPath)] = true
+		}
+		for dir := range dirs {
+			if watchedDirs[dir] {
+				continue
+			}
+			if errAdd := watcher.Add(dir); errAdd != nil {
+				bd.logger.Error("Failed to watch list directory", zap.String("dir", dir), zap.Error(errAdd))
+				continue
+			}
+			watchedDirs[dir] = true
+		}
+		for dir := range watchedDirs {
+			if dirs[dir] {
+				continue
+			}
+			if errRemove := watcher.Remove(dir); errRemove != nil {
+				bd.logger.Warn("Failed to remove list directory watch", zap.String("dir", dir), zap.Error(errRemove))
+			}
+			delete(watchedDirs, dir)
+		}
+		return files
+	}
+	files := syncWatches()
+	syncTimer := time.NewTicker(model.DurationCheckTimer)
+	defer syncTimer.Stop()
+	// Saving a file often generates several events, so wait for them to settle before reloading
+	reloadTimer := time.NewTimer(model.DurationListWatchDebounce)
+	reloadTimer.Stop()
+	var pending model.ListConfigCollection
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-syncTimer.C:
+			files = syncWatches()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+			listConfig, found := files[filepath.Clean(event.Name)]
+			if !found {
+				continue
+			}
+			isPending := false
+			for _, existing := range pending {
+				if existing == listConfig {
+					isPending = true
+					break
+				}
+			}
+			if !isPending {
+				pending = append(pending, listConfig)
+			}
+			reloadTimer.Reset(model.DurationListWatchDebounce)
+		case errWatch, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			bd.logger.Error("List watcher error", zap.Error(errWatch))
+		case <-reloadTimer.C:
+			for _, listConfig := range pending {
+				bd.logger.Info("Reloading changed list", zap.String("name", listConfig.Name), zap.String("url", listConfig.URL))
+			}
+			bd.loadLists(ctx, pending)
+			pending = nil
+		}
+	}
 }
@@ -1,8 +1,22 @@
 package detector
 
 import (
+	"context"
+	"fmt"
+	"github.com/leighmacdonald/bd/internal/cache"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/pkg/rules"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestListParse(t *testing.T) {
@@ -97,3 +111,308 @@ func TestListParse(t *testing.T) {
 	good := fixSteamIdFormat(badSchema)
 	require.Equal(t, goodSchema, good)
 }
+
+// fastListRetries shortens the backoff between list download attempts for the duration of the test
+func fastListRetries(t *testing.T) {
+	backoff := listRetryBackoff
+	listRetryBackoff = time.Millisecond
+	t.Cleanup(func() {
+		listRetryBackoff = backoff
+	})
+}
+
+func TestDownloadListsConditional(t *testing.T) {
+	fastListRetries(t)
+	const etag = `"v1"`
+	body := []byte(`{"file_info": {"title": "remote"}, "players": [{"attributes": ["cheater"], "steamid": "76561197961279983"}]}`)
+	var requests, notModified atomic.Int32
+	online := &atomic.Bool{}
+	online.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online.Load() {
+			panic(http.ErrAbortHandler)
+		}
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	logger := zap.NewNop()
+	listCache := cache.New(logger, t.TempDir(), time.Hour)
+	lists := model.ListConfigCollection{{ListType: model.ListTypeTF2BDPlayerList, Enabled: true, URL: server.URL}}
+	loaded := false
+	isLoaded := func(url string) bool { return loaded }
+
+	players, _, _, _ := downloadLists(context.Background(), logger, listCache, lists, isLoaded)
+	require.Equal(t, 1, len(players))
+	require.Equal(t, server.URL, players[0].SourceURL)
+
+	// Unchanged lists which are already loaded are skipped
+	loaded = true
+	players, _, _, _ = downloadLists(context.Background(), logger, listCache, lists, isLoaded)
+	require.Equal(t, 0, len(players))
+	require.Equal(t, int32(1), notModified.Load())
+
+	// Unchanged lists are loaded from the cache when not already loaded, such as after a restart
+	loaded = false
+	players, _, _, _ = downloadLists(context.Background(), logger, listCache, lists, isLoaded)
+	require.Equal(t, 1, len(players))
+	require.Equal(t, int32(2), notModified.Load())
+
+	// Offline launches fall back to the cached copy
+	online.Store(false)
+	players, _, _, _ = downloadLists(context.Background(), logger, listCache, lists, isLoaded)
+	require.Equal(t, 1, len(players))
+	require.Equal(t, "remote", players[0].FileInfo.Title)
+	require.Equal(t, int32(3), requests.Load())
+}
+
+func TestDownloadListsRetry(t *testing.T) {
+	fastListRetries(t)
+	body := []byte(`{"file_info": {"title": "remote"}, "rules": [{"description": "a", "triggers": {}}, {"description": "b", "triggers": {}}]}`)
+	failures := map[string]int{"/flaky": 2, "/broken": 100}
+	requests := map[string]int{}
+	mu := &sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("<html>Not Found</html>"))
+		case requests[r.URL.Path] <= failures[r.URL.Path]:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	logger := zap.NewNop()
+	lists := model.ListConfigCollection{
+		{ListType: model.ListTypeTF2BDRules, Enabled: true, URL: server.URL + "/flaky"},
+		{ListType: model.ListTypeTF2BDRules, Enabled: true, URL: server.URL + "/broken"},
+		{ListType: model.ListTypeTF2BDRules, Enabled: true, URL: server.URL + "/missing"},
+	}
+	_, ruleLists, _, results := downloadLists(context.Background(), logger, cache.New(logger, t.TempDir(), time.Hour), lists,
+		func(url string) bool { return false })
+	require.Equal(t, 1, len(ruleLists))
+	require.Equal(t, server.URL+"/flaky", ruleLists[0].SourceURL)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 3, requests["/flaky"])
+	require.Equal(t, maxListRetries+1, requests["/broken"])
+	require.Equal(t, 1, requests["/missing"], "client errors should not be retried")
+	require.Equal(t, 3, len(results))
+	for _, result := range results {
+		switch result.url {
+		case server.URL + "/flaky":
+			require.NoError(t, result.err)
+			require.Equal(t, 2, result.count)
+		default:
+			var errStatus errListStatus
+			require.ErrorAs(t, result.err, &errStatus)
+			require.False(t, result.parsed)
+		}
+	}
+}
+
+func TestLocalLists(t *testing.T) {
+	fastListRetries(t)
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "players.json")
+	require.NoError(t, os.WriteFile(listPath, []byte(`{"file_info": {"title": "shared"}, "players": [{"attributes": ["cheater"], "steamid":76561197961279983}]}`), 0600))
+
+	localPath, isLocal, errLocation := localListPath(listPath)
+	require.NoError(t, errLocation)
+	require.True(t, isLocal)
+	require.Equal(t, listPath, localPath)
+	fileURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(listPath)}).String()
+	localPath, isLocal, errLocation = localListPath(fileURL)
+	require.NoError(t, errLocation)
+	require.True(t, isLocal)
+	require.Equal(t, listPath, localPath)
+	_, isLocal, errLocation = localListPath("https://localhost/players.json")
+	require.NoError(t, errLocation)
+	require.False(t, isLocal)
+	for _, drivePath := range []string{`C:\lists\players.json`, "c:/lists/players.json"} {
+		localPath, isLocal, errLocation = localListPath(drivePath)
+		require.NoError(t, errLocation)
+		require.True(t, isLocal)
+		require.Equal(t, drivePath, localPath)
+	}
+	// Other schemes are neither downloaded nor read from a path named after the url
+	for _, unsupported := range []string{"ftp://localhost/players.json", "htps://localhost/players.json", "mailto:list@localhost"} {
+		_, _, errLocation = localListPath(unsupported)
+		require.Error(t, errLocation, unsupported)
+	}
+
+	logger := zap.NewNop()
+	listCache := cache.New(logger, t.TempDir(), time.Hour)
+	lists := model.ListConfigCollection{
+		{ListType: model.ListTypeTF2BDPlayerList, Enabled: true, URL: listPath},
+		{ListType: model.ListTypeTF2BDPlayerList, Enabled: true, URL: fileURL},
+	}
+	loaded := false
+	isLoaded := func(url string) bool { return loaded }
+	players, _, _, results := downloadLists(context.Background(), logger, listCache, lists, isLoaded)
+	require.Equal(t, 2, len(players))
+	for _, result := range results {
+		require.NoError(t, result.err)
+		require.Equal(t, 1, result.count)
+	}
+
+	// Unchanged files are skipped once loaded
+	loaded = true
+	players, _, _, _ = downloadLists(context.Background(), logger, listCache, lists, isLoaded)
+	require.Equal(t, 0, len(players))
+
+	updated := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(listPath, updated, updated))
+	players, _, _, _ = downloadLists(context.Background(), logger, listCache, lists, isLoaded)
+	require.Equal(t, 2, len(players))
+}
+
+func TestDownloadWhitelist(t *testing.T) {
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "whitelist.json")
+	require.NoError(t, os.WriteFile(listPath, []byte(`{"file_info": {"title": "friends"}, "players": [{"attributes": ["whitelist"], "steamid":76561197961279983}]}`), 0600))
+
+	logger := zap.NewNop()
+	lists := model.ListConfigCollection{{ListType: model.ListTypeTF2BDWhitelist, Enabled: true, URL: listPath}}
+	players, _, whitelists, results := downloadLists(context.Background(), logger, cache.New(logger, t.TempDir(), time.Hour), lists,
+		func(url string) bool { return false })
+	require.Empty(t, players)
+	require.Equal(t, 1, len(whitelists))
+	require.Equal(t, listPath, whitelists[0].SourceURL)
+	require.Equal(t, 1, results[0].count)
+}
+
+func TestDownloadListsBrokenUpdate(t *testing.T) {
+	fastListRetries(t)
+	var (
+		mu      sync.Mutex
+		etag    = `"v1"`
+		body    = []byte(`{"file_info": {"title": "remote"}, "players": [{"attributes": ["cheater"], "steamid": "76561197961279983"}]}`)
+		online  = true
+		matched []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !online {
+			panic(http.ErrAbortHandler)
+		}
+		matched = append(matched, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	logger := zap.NewNop()
+	listCache := cache.New(logger, t.TempDir(), time.Hour)
+	lists := model.ListConfigCollection{{ListType: model.ListTypeTF2BDPlayerList, Enabled: true, URL: server.URL}}
+	isLoaded := func(url string) bool { return true }
+
+	players, _, _, _ := downloadLists(context.Background(), logger, listCache, lists, isLoaded)
+	require.Equal(t, 1, len(players))
+
+	mu.Lock()
+	etag = `"v2"`
+	body = []byte(`{"file_info": {"title": "remote"}, "players": [`)
+	mu.Unlock()
+
+	// The broken update is not cached, so it is downloaded and reported as failing again rather than unchanged
+	for i := 0; i < 2; i++ {
+		_, _, _, results := downloadLists(context.Background(), logger, listCache, lists, isLoaded)
+		require.Error(t, results[0].err)
+		require.False(t, results[0].unchanged)
+	}
+	mu.Lock()
+	require.Equal(t, []string{"", `"v1"`, `"v1"`}, matched)
+	online = false
+	mu.Unlock()
+
+	// Offline launches load the last list which parsed
+	players, _, _, _ = downloadLists(context.Background(), logger, listCache, lists, func(url string) bool { return false })
+	require.Equal(t, 1, len(players))
+	require.Equal(t, 1, len(players[0].Players))
+}
+
+func TestDownloadListsInvalidEntries(t *testing.T) {
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "players.json")
+	require.NoError(t, os.WriteFile(listPath, []byte(`{"file_info": {"title": "shared"}, "players": [
+		{"attributes": ["cheater"], "steamid": 76561197961279983},
+		{"attributes": [], "steamid": 76561197961279984}
+	]}`), 0600))
+
+	// Invalid entries are skipped and counted unless the list is strict
+	logger := zap.NewNop()
+	lists := model.ListConfigCollection{{ListType: model.ListTypeTF2BDPlayerList, Enabled: true, URL: listPath}}
+	players, _, _, results := downloadLists(context.Background(), logger, cache.New(logger, t.TempDir(), time.Hour), lists,
+		func(url string) bool { return false })
+	require.Equal(t, 1, len(players))
+	require.NoError(t, results[0].err)
+	require.Equal(t, 1, results[0].count)
+	require.Equal(t, 1, results[0].dropped)
+
+	lists[0].Strict = true
+	players, _, _, results = downloadLists(context.Background(), logger, cache.New(logger, t.TempDir(), time.Hour), lists,
+		func(url string) bool { return false })
+	require.Empty(t, players)
+	require.Error(t, results[0].err)
+}
+
+func TestRefreshListsRemovesStale(t *testing.T) {
+	dir := t.TempDir()
+	var lists model.ListConfigCollection
+	for i, title := range []string{"first", "second", "third"} {
+		listPath := filepath.Join(dir, title+".json")
+		body := fmt.Sprintf(`{"file_info": {"title": "%s"}, "players": [{"attributes": ["cheater"], "steamid": "%d"}]}`,
+			title, 76561197961279983+i)
+		require.NoError(t, os.WriteFile(listPath, []byte(body), 0600))
+		lists = append(lists, &model.ListConfig{ListType: model.ListTypeTF2BDPlayerList, Name: title, Enabled: true, URL: listPath})
+	}
+	settings, errSettings := model.NewSettings()
+	require.NoError(t, errSettings)
+	settings.SetLists(lists)
+	engine, errEngine := rules.New(nil, nil, nil)
+	require.NoError(t, errEngine)
+	logger := zap.NewNop()
+	bd := &BD{
+		logger:       logger,
+		settings:     settings,
+		rules:        engine,
+		cache:        cache.New(logger, t.TempDir(), time.Hour),
+		listStatus:   map[string]model.ListStatus{},
+		listStatusMu: &sync.RWMutex{},
+		listSources:  map[string]bool{},
+	}
+	bd.refreshLists(context.Background())
+	for _, listConfig := range lists {
+		require.True(t, engine.IsLoaded(listConfig.URL))
+	}
+
+	// Deleted lists, lists with a changed url and disabled lists are all unloaded
+	deleted, moved, disabled := lists[0], lists[1].URL, lists[2]
+	lists[1].URL = filepath.Join(dir, "missing.json")
+	disabled.Enabled = false
+	settings.SetLists(lists[1:])
+	bd.refreshLists(context.Background())
+	require.False(t, engine.IsLoaded(deleted.URL))
+	require.False(t, engine.IsLoaded(moved))
+	require.False(t, engine.IsLoaded(disabled.URL))
+	require.Empty(t, engine.MatchSteamAll(76561197961279983))
+}
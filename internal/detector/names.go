@@ -0,0 +1,70 @@
+package detector
+
+import (
+	"fmt"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/pkg/rules"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"strings"
+)
+
+// originDetector is the origin of matches which are generated by bd itself rather than a list
+const originDetector = "bd"
+
+const (
+	matcherTypeNameStealer = "name_stealer"
+	attrNameStealer        = "name_stealer"
+)
+
+// isNewerAccount returns true if the account of a was created after the account of b. The account creation
+// time is used when it is known for both players, otherwise the steam id is used as they are assigned
+// sequentially.
+func isNewerAccount(a *model.Player, b *model.Player) bool {
+	if a.AccountCreatedOn.Unix() > 0 && b.AccountCreatedOn.Unix() > 0 && !a.AccountCreatedOn.Equal(b.AccountCreatedOn) {
+		return a.AccountCreatedOn.After(b.AccountCreatedOn)
+	}
+	return a.SteamId > b.SteamId
+}
+
+// findNameStealers compares the names of all connected players against each other after normalisation.
+// For each group of colliding names, every account other than the oldest is returned as a name stealer.
+// The local player is never flagged.
+func findNameStealers(players model.PlayerCollection, self steamid.SID64) map[steamid.SID64]*rules.MatchResult {
+	groups := map[string]model.PlayerCollection{}
+	for _, player := range players {
+		if player.IsDisconnected() {
+			continue
+		}
+		name := strings.ToLower(strings.Join(strings.Fields(rules.NormaliseText(player.Name)), " "))
+		if name == "" {
+			continue
+		}
+		groups[name] = append(groups[name], player)
+	}
+	found := map[steamid.SID64]*rules.MatchResult{}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		original := group[0]
+		for _, player := range group[1:] {
+			if isNewerAccount(original, player) {
+				original = player
+			}
+		}
+		for _, player := range group {
+			if player == original || player.SteamId == self {
+				continue
+			}
+			found[player.SteamId] = &rules.MatchResult{
+				Origin:      originDetector,
+				MatcherType: matcherTypeNameStealer,
+				Attributes:  []string{attrNameStealer},
+				Rule:        fmt.Sprintf("Name %q copies %q (%d)", player.Name, original.Name, original.SteamId.Int64()),
+				Pattern:     original.Name,
+				Matched:     player.Name,
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,42 @@
+package detector
+
+import (
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestFindNameStealers(t *testing.T) {
+	newPlayer := func(sid64 steamid.SID64, name string) *model.Player {
+		player := model.NewPlayer(sid64, name)
+		player.UpdatedOn = time.Now()
+		return player
+	}
+	original := newPlayer(76561197960265730, "Player One")
+	// Cyrillic e, a zero width space and a trailing hangul filler
+	stealer := newPlayer(76561198000000000, "Playеr​ Oneㅤ")
+	fullwidth := newPlayer(76561198000000001, "Ｐｌａｙｅｒ　Ｏｎｅ")
+	self := newPlayer(76561198000000002, "player one")
+	unrelated := newPlayer(76561198000000003, "Player Two")
+	disconnected := newPlayer(76561198000000004, "Player Two")
+	disconnected.UpdatedOn = time.Now().Add(-time.Hour)
+	// Created after the stealer even though the steam id is older
+	byCreation := newPlayer(76561197960265731, "bot")
+	byCreation.AccountCreatedOn = time.Now()
+	byCreationOriginal := newPlayer(76561198000000005, "bоt")
+	byCreationOriginal.AccountCreatedOn = time.Now().AddDate(-5, 0, 0)
+
+	found := findNameStealers(model.PlayerCollection{
+		stealer, original, fullwidth, self, unrelated, disconnected, byCreation, byCreationOriginal,
+	}, self.SteamId)
+	require.Equal(t, 3, len(found))
+	for _, sid64 := range []steamid.SID64{stealer.SteamId, fullwidth.SteamId, byCreation.SteamId} {
+		match, ok := found[sid64]
+		require.True(t, ok, "%d not flagged", sid64)
+		require.Equal(t, []string{attrNameStealer}, match.Attributes)
+	}
+	require.Contains(t, found[stealer.SteamId].Rule, "76561197960265730")
+	require.Contains(t, found[byCreation.SteamId].Rule, "76561198000000005")
+}
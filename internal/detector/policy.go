@@ -0,0 +1,157 @@
+package detector
+
+import (
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/pkg/rules"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+// policyActions returns the actions of every policy matching one of the attributes, in the order the policies
+// are defined. The default policy is only used when no other policy matches. When multiple policies include the
+// same action type, the first one is used.
+func policyActions(policies model.ActionPolicyCollection, attributes []string) []model.PolicyAction {
+	var (
+		actions     []model.PolicyAction
+		seen        = map[model.ActionType]bool{}
+		defaultOnly = true
+	)
+	add := func(policy *model.ActionPolicy) {
+		for _, action := range policy.Actions {
+			if seen[action.Type] {
+				continue
+			}
+			seen[action.Type] = true
+			actions = append(actions, action)
+		}
+	}
+	for _, policy := range policies {
+		for _, attribute := range attributes {
+			if strings.EqualFold(policy.Attribute, attribute) {
+				defaultOnly = false
+				add(policy)
+				break
+			}
+		}
+	}
+	if defaultOnly {
+		for _, policy := range policies {
+			if policy.Attribute == model.PolicyAttributeDefault {
+				add(policy)
+			}
+		}
+	}
+	return actions
+}
+
+// policyAttributes returns the attributes of the policies which include the action type
+func policyAttributes(policies model.ActionPolicyCollection, actionType model.ActionType) []string {
+	var attributes []string
+	for _, policy := range policies {
+		for _, action := range policy.Actions {
+			if action.Type == actionType {
+				attributes = append(attributes, policy.Attribute)
+				break
+			}
+		}
+	}
+	return attributes
+}
+
+// actionDue returns true when the cooldown of the action has passed since it was last taken
+func actionDue(action model.PolicyAction, last map[model.ActionType]time.Time, now time.Time) bool {
+	lastTaken, found := last[action.Type]
+	if !found {
+		return true
+	}
+	return now.Sub(lastTaken) >= action.GetCooldown()
+}
+
+// voiceMuteList holds the players muted by policy during this session. The game only reads voice_ban.dt when it
+// launches, so they are muted from the next launch onwards.
+type voiceMuteList struct {
+	mu      *sync.RWMutex
+	players map[steamid.SID64]bool
+}
+
+func newVoiceMuteList() *voiceMuteList {
+	return &voiceMuteList{mu: &sync.RWMutex{}, players: map[steamid.SID64]bool{}}
+}
+
+func (v *voiceMuteList) add(steamID steamid.SID64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.players[steamID] = true
+}
+
+func (v *voiceMuteList) all() steamid.Collection {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	players := map[steamid.SID64]bool{}
+	for steamID := range v.players {
+		players[steamID] = true
+	}
+	return sortedPlayers(players)
+}
+
+// executePolicy takes each of the actions of the policies matching the attributes of the player which are enabled
+// and not on cooldown. Execution stops at the first action which fails. The caller must hold the player lock, and
+// check whether the player is on our team beforehand as only teammates can be kicked.
+func (bd *BD) executePolicy(ps *model.Player, matches rules.MatchResults, teammate bool) {
+	now := time.Now()
+	for _, action := range policyActions(bd.settings.GetPolicies(), matches.Attributes()) {
+		if !bd.actionEnabled(action.Type) || !actionDue(action, ps.ActionsLast, now) {
+			continue
+		}
+		if errAction := bd.executeAction(ps, action.Type, matches, teammate); errAction != nil {
+			bd.logger.Error("Failed to execute policy action", zap.String("action", string(action.Type)), zap.Error(errAction))
+			return
+		}
+		if ps.ActionsLast == nil {
+			ps.ActionsLast = map[model.ActionType]time.Time{}
+		}
+		ps.ActionsLast[action.Type] = now
+	}
+}
+
+// actionEnabled checks the global setting which enables the action type
+func (bd *BD) actionEnabled(actionType model.ActionType) bool {
+	switch actionType {
+	case model.ActionPartyWarning:
+		return bd.settings.GetPartyWarningsEnabled()
+	case model.ActionChatWarning:
+		return bd.settings.GetChatWarningsEnabled()
+	case model.ActionKick:
+		return bd.settings.GetKickerEnabled()
+	case model.ActionVoiceMute:
+		return bd.settings.GetVoiceBansEnabled()
+	default:
+		return false
+	}
+}
+
+func (bd *BD) executeAction(ps *model.Player, actionType model.ActionType, matches rules.MatchResults, teammate bool) error {
+	switch actionType {
+	case model.ActionPartyWarning:
+		// Don't spam friends, but eventually remind them if they manage to forget long enough
+		return bd.SendChat(model.ChatDestParty, "(%d) [%s] [%s] %s ", ps.UserId,
+			strings.Join(matches.Origins(), ","), strings.Join(matches.Attributes(), ","), ps.Name)
+	case model.ActionChatWarning:
+		return bd.SendChat(model.ChatDestAll, "Bot Detector: %s has been matched as: %s", ps.Name,
+			strings.Join(matches.Attributes(), ", "))
+	case model.ActionKick:
+		if teammate {
+			bd.queueKick(ps, len(matches))
+		}
+		return nil
+	case model.ActionVoiceMute:
+		bd.voiceMutes.add(ps.SteamId)
+		return nil
+	default:
+		return errors.Errorf("Unknown action type: %s", actionType)
+	}
+}
@@ -0,0 +1,55 @@
+package detector
+
+import (
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPolicyActions(t *testing.T) {
+	party := model.PolicyAction{Type: model.ActionPartyWarning, Cooldown: "5m"}
+	kick := model.PolicyAction{Type: model.ActionKick, Cooldown: "30s"}
+	mute := model.PolicyAction{Type: model.ActionVoiceMute}
+	chat := model.PolicyAction{Type: model.ActionChatWarning, Cooldown: "1m"}
+	policies := model.ActionPolicyCollection{
+		{Attribute: model.PolicyAttributeDefault, Actions: []model.PolicyAction{party}},
+		{Attribute: "cheater", Actions: []model.PolicyAction{kick}},
+		{Attribute: "suspicious", Actions: []model.PolicyAction{party}},
+		{Attribute: "racist", Actions: []model.PolicyAction{mute, chat}},
+	}
+	require.Equal(t, []model.PolicyAction{kick}, policyActions(policies, []string{"Cheater"}))
+	require.Equal(t, []model.PolicyAction{party}, policyActions(policies, []string{"suspicious"}))
+	require.Equal(t, []model.PolicyAction{mute, chat}, policyActions(policies, []string{"racist"}))
+	require.Equal(t, []model.PolicyAction{kick, mute, chat}, policyActions(policies, []string{"racist", "cheater"}))
+	require.Equal(t, []model.PolicyAction{party}, policyActions(policies, []string{"unknown"}))
+	require.Empty(t, policyActions(policies[1:], []string{"unknown"}))
+
+	require.Equal(t, []string{"racist"}, policyAttributes(policies, model.ActionVoiceMute))
+	require.Equal(t, []string{model.PolicyAttributeDefault, "suspicious"}, policyAttributes(policies, model.ActionPartyWarning))
+}
+
+func TestDefaultPolicies(t *testing.T) {
+	policies := model.NewDefaultPolicies([]string{"cheater"})
+	var types []model.ActionType
+	for _, action := range policyActions(policies, []string{"cheater"}) {
+		types = append(types, action.Type)
+	}
+	require.Equal(t, []model.ActionType{model.ActionPartyWarning, model.ActionKick, model.ActionVoiceMute}, types)
+	types = nil
+	for _, action := range policyActions(policies, []string{"suspicious"}) {
+		types = append(types, action.Type)
+	}
+	require.Equal(t, []model.ActionType{model.ActionPartyWarning}, types)
+}
+
+func TestActionDue(t *testing.T) {
+	t0 := time.Now()
+	action := model.PolicyAction{Type: model.ActionPartyWarning, Cooldown: "5m"}
+	require.True(t, actionDue(action, nil, t0))
+	last := map[model.ActionType]time.Time{model.ActionPartyWarning: t0}
+	require.False(t, actionDue(action, last, t0.Add(time.Minute)))
+	require.True(t, actionDue(action, last, t0.Add(5*time.Minute)))
+	require.True(t, actionDue(model.PolicyAction{Type: model.ActionPartyWarning, Cooldown: "invalid"}, last, t0))
+	require.True(t, actionDue(model.PolicyAction{Type: model.ActionKick, Cooldown: "1h"}, last, t0))
+}
@@ -0,0 +1,68 @@
+package detector
+
+import (
+	"context"
+	"github.com/leighmacdonald/bd/internal/model"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// voluntaryDisconnect returns true when the player chose to leave, rather than being kicked, banned or timing out
+func voluntaryDisconnect(reason string) bool {
+	return strings.Contains(strings.ToLower(reason), "disconnect by user")
+}
+
+// rageQuitCause correlates a player leaving with a recent kick vote against them or death to us. Kick votes are
+// preferred as leaving during a vote is the more telling of the two.
+func rageQuitCause(reason string, killedByUsLast time.Time, kickVotedLast time.Time, now time.Time) (model.RageQuitCause, bool) {
+	if !voluntaryDisconnect(reason) {
+		return "", false
+	}
+	recent := func(last time.Time) bool {
+		return !last.IsZero() && now.Sub(last) <= model.DurationRageQuitWindow
+	}
+	switch {
+	case recent(kickVotedLast):
+		return model.RageQuitCauseKickVote, true
+	case recent(killedByUsLast):
+		return model.RageQuitCauseDeath, true
+	default:
+		return "", false
+	}
+}
+
+func (bd *BD) onPlayerDisconnect(ctx context.Context, evt playerDisconnectEvent) {
+	ps := bd.getPlayerByName(evt.name)
+	if ps == nil {
+		return
+	}
+	ps.Lock()
+	cause, isRageQuit := rageQuitCause(evt.reason, ps.KilledByUsLast, ps.KickVotedLast, time.Now())
+	if !isRageQuit {
+		ps.Unlock()
+		return
+	}
+	ps.RageQuits++
+	ps.Touch()
+	rageQuit := model.RageQuit{
+		SteamId:   ps.SteamId,
+		Name:      ps.Name,
+		Reason:    evt.reason,
+		Cause:     cause,
+		CreatedOn: evt.createdAt,
+	}
+	ps.Unlock()
+	if rageQuit.CreatedOn.IsZero() {
+		rageQuit.CreatedOn = time.Now()
+	}
+	bd.serverMu.RLock()
+	rageQuit.ServerName = bd.server.ServerName
+	rageQuit.MapName = bd.server.CurrentMap
+	bd.serverMu.RUnlock()
+	bd.logger.Info("Player rage quit", zap.String("name", rageQuit.Name),
+		zap.Int64("steam_id", rageQuit.SteamId.Int64()), zap.String("cause", string(cause)))
+	if errSave := bd.store.SaveRageQuit(ctx, &rageQuit); errSave != nil {
+		bd.logger.Error("Failed to save rage quit", zap.Error(errSave))
+	}
+}
@@ -0,0 +1,35 @@
+package detector
+
+import (
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRageQuitCause(t *testing.T) {
+	const userReason = "Disconnect by user."
+	t0 := time.Now()
+	recent := t0.Add(-time.Second * 10)
+	old := t0.Add(-model.DurationRageQuitWindow - time.Second)
+	for _, tc := range []struct {
+		reason     string
+		killedLast time.Time
+		votedLast  time.Time
+		cause      model.RageQuitCause
+		rageQuit   bool
+	}{
+		{reason: userReason, killedLast: recent, cause: model.RageQuitCauseDeath, rageQuit: true},
+		{reason: userReason, votedLast: recent, cause: model.RageQuitCauseKickVote, rageQuit: true},
+		{reason: userReason, killedLast: recent, votedLast: recent, cause: model.RageQuitCauseKickVote, rageQuit: true},
+		{reason: userReason, killedLast: old, votedLast: recent, cause: model.RageQuitCauseKickVote, rageQuit: true},
+		{reason: userReason, killedLast: old, votedLast: old},
+		{reason: userReason},
+		{reason: "Kicked from server", votedLast: recent},
+		{reason: "timed out", killedLast: recent},
+	} {
+		cause, rageQuit := rageQuitCause(tc.reason, tc.killedLast, tc.votedLast, t0)
+		require.Equal(t, tc.rageQuit, rageQuit, tc)
+		require.Equal(t, tc.cause, cause, tc)
+	}
+}
@@ -0,0 +1,272 @@
+package detector
+
+import (
+	"fmt"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/pkg/rules"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	matcherTypeChatSpam = "chat_spam"
+	attrChatSpam        = "chat_spam"
+
+	// chatFloodCount is the number of messages within DurationChatFloodWindow considered a flood
+	chatFloodCount = 5
+	// chatRepeatCount is the number of similar messages from a player within DurationChatRepeatWindow
+	// considered spam
+	chatRepeatCount = 3
+	// chatWaveCount is the number of distinct players sending a similar message within DurationChatWaveWindow
+	// considered a bot chat wave
+	chatWaveCount = 5
+	// chatSpamMinLength is the minimum length of a message to be checked for repeats so that common short
+	// messages such as "gg" are not flagged
+	chatSpamMinLength = 10
+	// chatWaveMinLength is the minimum length of a message to be checked for waves. It is longer than
+	// chatSpamMinLength as several players saying "gg everyone" at the end of a round is normal.
+	chatWaveMinLength = 20
+	// chatSimilarity is the minimum similarity ratio between two messages for them to be considered the same
+	chatSimilarity = 0.85
+)
+
+type chatSpamKind string
+
+const (
+	chatSpamFlood  chatSpamKind = "flood"
+	chatSpamRepeat chatSpamKind = "repeat"
+	chatSpamWave   chatSpamKind = "wave"
+)
+
+type chatMessage struct {
+	steamID steamid.SID64
+	text    []rune
+	at      time.Time
+}
+
+type chatSpamHit struct {
+	result *rules.MatchResult
+	at     time.Time
+}
+
+// chatSpamDetector analyses the recent chat history across all players to find players who flood the chat, repeat
+// the same message or take part in a chat wave where several players send the same message. Hits stay active for
+// DurationChatSpamExpiry after the last offending message.
+type chatSpamDetector struct {
+	mu      *sync.Mutex
+	history map[steamid.SID64][]chatMessage
+	recent  []chatMessage
+	hits    map[steamid.SID64]map[chatSpamKind]chatSpamHit
+}
+
+func newChatSpamDetector() *chatSpamDetector {
+	return &chatSpamDetector{
+		mu:      &sync.Mutex{},
+		history: map[steamid.SID64][]chatMessage{},
+		hits:    map[steamid.SID64]map[chatSpamKind]chatSpamHit{},
+	}
+}
+
+// normaliseChatMessage reduces a message to the form used when comparing messages
+func normaliseChatMessage(message string) []rune {
+	return []rune(strings.ToLower(strings.Join(strings.Fields(rules.NormaliseText(message)), " ")))
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	lowest := values[0]
+	for _, value := range values[1:] {
+		if value < lowest {
+			lowest = value
+		}
+	}
+	return lowest
+}
+
+// similarMessages returns true if the messages are identical or near-identical
+func similarMessages(a []rune, b []rune) bool {
+	longest, shortest := len(a), len(b)
+	if shortest > longest {
+		longest, shortest = shortest, longest
+	}
+	if longest == 0 {
+		return true
+	}
+	// Skip the edit distance when the length difference alone rules out a match
+	if float64(shortest)/float64(longest) < chatSimilarity {
+		return false
+	}
+	return 1-float64(levenshtein(a, b))/float64(longest) >= chatSimilarity
+}
+
+// add records the message and returns the players which were flagged by it. A single message can flag multiple
+// players when it completes a chat wave.
+func (d *chatSpamDetector) add(steamID steamid.SID64, message string, at time.Time) steamid.Collection {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune(at)
+	msg := chatMessage{steamID: steamID, text: normaliseChatMessage(message), at: at}
+	if len(msg.text) == 0 {
+		return nil
+	}
+	d.history[steamID] = append(d.history[steamID], msg)
+	d.recent = append(d.recent, msg)
+
+	flagged := map[steamid.SID64]bool{}
+	floodCount, repeatCount := 0, 0
+	for _, previous := range d.history[steamID] {
+		if at.Sub(previous.at) <= model.DurationChatFloodWindow {
+			floodCount++
+		}
+		if at.Sub(previous.at) <= model.DurationChatRepeatWindow && similarMessages(previous.text, msg.text) {
+			repeatCount++
+		}
+	}
+	if floodCount >= chatFloodCount {
+		d.hit(steamID, chatSpamFlood, at, fmt.Sprintf("Sent %d messages within %s", floodCount, model.DurationChatFloodWindow), message)
+		flagged[steamID] = true
+	}
+	if len(msg.text) < chatSpamMinLength {
+		return sortedPlayers(flagged)
+	}
+	if repeatCount >= chatRepeatCount {
+		d.hit(steamID, chatSpamRepeat, at, fmt.Sprintf("Repeated message %d times", repeatCount), message)
+		flagged[steamID] = true
+	}
+	if len(msg.text) < chatWaveMinLength {
+		return sortedPlayers(flagged)
+	}
+	// Repeats of a player are already covered above, so each player counts once towards a wave
+	participants := map[steamid.SID64]bool{}
+	for _, previous := range d.recent {
+		if at.Sub(previous.at) <= model.DurationChatWaveWindow && similarMessages(previous.text, msg.text) {
+			participants[previous.steamID] = true
+		}
+	}
+	if len(participants) >= chatWaveCount {
+		for participant := range participants {
+			d.hit(participant, chatSpamWave, at, fmt.Sprintf("Sent the same message as %d other players", len(participants)-1), message)
+			flagged[participant] = true
+		}
+	}
+	return sortedPlayers(flagged)
+}
+
+func sortedPlayers(players map[steamid.SID64]bool) steamid.Collection {
+	var sorted steamid.Collection
+	for sid64 := range players {
+		sorted = append(sorted, sid64)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
+
+func (d *chatSpamDetector) hit(steamID steamid.SID64, kind chatSpamKind, at time.Time, explanation string, message string) {
+	if _, found := d.hits[steamID]; !found {
+		d.hits[steamID] = map[chatSpamKind]chatSpamHit{}
+	}
+	d.hits[steamID][kind] = chatSpamHit{
+		result: &rules.MatchResult{
+			Origin:      originDetector,
+			MatcherType: matcherTypeChatSpam,
+			Attributes:  []string{attrChatSpam},
+			Rule:        fmt.Sprintf("Chat %s: %s", kind, explanation),
+			Pattern:     string(kind),
+			Matched:     message,
+		},
+		at: at,
+	}
+}
+
+// prune removes messages which are too old to be part of any window and hits which have expired
+func (d *chatSpamDetector) prune(now time.Time) {
+	maxWindow := model.DurationChatFloodWindow
+	for _, window := range []time.Duration{model.DurationChatRepeatWindow, model.DurationChatWaveWindow} {
+		if window > maxWindow {
+			maxWindow = window
+		}
+	}
+	keep := func(messages []chatMessage) []chatMessage {
+		for i, msg := range messages {
+			if now.Sub(msg.at) <= maxWindow {
+				return messages[i:]
+			}
+		}
+		return nil
+	}
+	for steamID, messages := range d.history {
+		if remaining := keep(messages); len(remaining) > 0 {
+			d.history[steamID] = remaining
+		} else {
+			delete(d.history, steamID)
+		}
+	}
+	d.recent = keep(d.recent)
+	for steamID, hits := range d.hits {
+		for kind, hit := range hits {
+			if now.Sub(hit.at) > model.DurationChatSpamExpiry {
+				delete(hits, kind)
+			}
+		}
+		if len(hits) == 0 {
+			delete(d.hits, steamID)
+		}
+	}
+}
+
+// checkChatSpam records the message and triggers the chat spam matches of every player on our team it flagged.
+// Our own messages are not recorded, so we are never flagged nor counted towards a wave.
+func (bd *BD) checkChatSpam(steamID steamid.SID64, message string, sentAt time.Time) {
+	if steamID == bd.settings.GetSteamId() {
+		return
+	}
+	for _, spammerSID := range bd.chatSpam.add(steamID, message, sentAt) {
+		if !bd.teammate(spammerSID) {
+			continue
+		}
+		if spammer := bd.GetPlayer(spammerSID); spammer != nil {
+			bd.triggerMatch(spammer, bd.chatSpam.matches(spammerSID, sentAt))
+		}
+	}
+}
+
+// matches returns the active chat spam matches of the player
+func (d *chatSpamDetector) matches(steamID steamid.SID64, now time.Time) rules.MatchResults {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var results rules.MatchResults
+	for _, hit := range d.hits[steamID] {
+		if now.Sub(hit.at) <= model.DurationChatSpamExpiry {
+			results = append(results, hit.result)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Rule < results[j].Rule
+	})
+	return results
+}
@@ -0,0 +1,127 @@
+package detector
+
+import (
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChatSpamDetector(t *testing.T) {
+	const (
+		player1 = steamid.SID64(76561197960265730)
+		player2 = steamid.SID64(76561197960265731)
+		player3 = steamid.SID64(76561197960265732)
+		player4 = steamid.SID64(76561197960265733)
+		player5 = steamid.SID64(76561197960265734)
+		player6 = steamid.SID64(76561197960265735)
+	)
+	t0 := time.Now()
+	detector := newChatSpamDetector()
+
+	// Flood
+	for i, message := range []string{"a", "b", "c", "d"} {
+		require.Empty(t, detector.add(player1, message, t0.Add(time.Duration(i)*time.Second)))
+	}
+	require.Equal(t, steamid.Collection{player1}, detector.add(player1, "e", t0.Add(4*time.Second)))
+	matches := detector.matches(player1, t0.Add(5*time.Second))
+	require.Equal(t, 1, len(matches))
+	require.Equal(t, matcherTypeChatSpam, matches[0].MatcherType)
+	require.Contains(t, matches[0].Rule, "flood")
+	require.Empty(t, detector.matches(player1, t0.Add(time.Hour)))
+
+	// Repeated near-identical messages, short messages are ignored
+	require.Empty(t, detector.add(player2, "gg", t0))
+	require.Empty(t, detector.add(player2, "gg", t0.Add(time.Second*20)))
+	require.Empty(t, detector.add(player2, "gg", t0.Add(time.Second*40)))
+	require.Empty(t, detector.add(player2, "visit my website now", t0.Add(time.Second*41)))
+	require.Empty(t, detector.add(player2, "visit my website now!!", t0.Add(time.Second*50)))
+	require.Equal(t, steamid.Collection{player2}, detector.add(player2, "VISIT my wеbsite now", t0.Add(time.Second*60)))
+	require.Contains(t, detector.matches(player2, t0.Add(time.Second*60))[0].Rule, "repeat")
+
+	// Chat wave across players, repeats by the same player only count once
+	t1 := t0.Add(time.Hour)
+	require.Empty(t, detector.add(player3, "this server is now owned by bots", t1))
+	require.Empty(t, detector.add(player3, "this server is now owned by bots", t1))
+	require.Empty(t, detector.add(player4, "this server is now owned by bots", t1.Add(time.Second)))
+	require.Empty(t, detector.add(player4, "something else entirely", t1.Add(2*time.Second)))
+	require.Empty(t, detector.add(player5, "this server is now owned by bots", t1.Add(2*time.Second)))
+	require.Empty(t, detector.add(player6, "this server is now owned by bots", t1.Add(2*time.Second)))
+	require.Equal(t, steamid.Collection{player1, player3, player4, player5, player6},
+		detector.add(player1, "this server is now owned by b0ts", t1.Add(3*time.Second)))
+	for _, sid64 := range []steamid.SID64{player1, player3, player4, player5, player6} {
+		matches := detector.matches(sid64, t1.Add(3*time.Second))
+		require.Equal(t, 1, len(matches))
+		require.Contains(t, matches[0].Rule, "wave")
+	}
+	require.Empty(t, detector.matches(player2, t1))
+}
+
+func TestChatSpamCommonPhrases(t *testing.T) {
+	t0 := time.Now()
+	detector := newChatSpamDetector()
+	// The whole lobby saying the same thing at the end of a round is not a wave
+	for i := 0; i < 12; i++ {
+		require.Empty(t, detector.add(steamid.SID64(76561197960265730+int64(i)), "gg everyone!", t0.Add(time.Duration(i)*time.Second)))
+	}
+}
+
+func TestCheckChatSpam(t *testing.T) {
+	const (
+		self  = steamid.SID64(76561197960265730)
+		enemy = steamid.SID64(76561197960265739)
+	)
+	settings, errSettings := model.NewSettings()
+	require.NoError(t, errSettings)
+	settings.SetSteamID(self.String())
+	conn := &recordingRcon{mu: &sync.Mutex{}}
+	bd := &BD{
+		logger:            zap.NewNop(),
+		settings:          settings,
+		playersMu:         &sync.RWMutex{},
+		rconConnection:    conn,
+		gameProcessActive: &atomic.Bool{},
+		chatSpam:          newChatSpamDetector(),
+	}
+	bd.gameProcessActive.Store(true)
+	var wave steamid.Collection
+	for i := 0; i < chatWaveCount; i++ {
+		wave = append(wave, self+steamid.SID64(i+1))
+	}
+	bd.players = append(bd.players, model.NewPlayer(self, "us"))
+	for _, sid64 := range append(wave, enemy) {
+		bd.players = append(bd.players, model.NewPlayer(sid64, sid64.String()))
+	}
+	bd.GetPlayer(enemy).Team = model.Blu
+
+	t0 := time.Now()
+	message := "this server is now owned by bots"
+	bd.checkChatSpam(self, message, t0)
+	bd.checkChatSpam(enemy, message, t0)
+	for _, sid64 := range wave {
+		bd.checkChatSpam(sid64, message, t0)
+	}
+	// We never count towards or get flagged by a wave, and only teammates are acted on
+	require.Empty(t, bd.chatSpam.matches(self, t0))
+	require.NotEmpty(t, bd.chatSpam.matches(enemy, t0))
+	for _, sid64 := range wave {
+		require.NotEmpty(t, bd.chatSpam.matches(sid64, t0))
+	}
+	require.Equal(t, len(wave), conn.count("say_party"))
+	for _, command := range conn.commands {
+		require.NotContains(t, command, "] us")
+		require.NotContains(t, command, enemy.String())
+	}
+}
+
+func TestSimilarMessages(t *testing.T) {
+	require.True(t, similarMessages([]rune("join my discord"), []rune("join my discord")))
+	require.True(t, similarMessages([]rune("join my discord server"), []rune("join my discord server!")))
+	require.False(t, similarMessages([]rune("join my discord"), []rune("nice shot")))
+	require.False(t, similarMessages([]rune("gg"), []rune("gg wp all")))
+	require.Equal(t, 3, levenshtein([]rune("kitten"), []rune("sitting")))
+}
@@ -0,0 +1,188 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"github.com/leighmacdonald/bd/internal/model"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// voteTracker tracks the kick votes called during the current server session
+type voteTracker struct {
+	mu      *sync.RWMutex
+	current *model.Vote
+	// startedAt is when the current vote was seen, the log timestamps are not used as they are in local time
+	startedAt time.Time
+}
+
+func newVoteTracker() *voteTracker {
+	return &voteTracker{mu: &sync.RWMutex{}}
+}
+
+// start begins tracking a new vote. If the previous vote never received a result it is returned so that it
+// can still be recorded.
+func (v *voteTracker) start(vote *model.Vote, now time.Time) *model.Vote {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	unfinished := v.current
+	v.current = vote
+	v.startedAt = now
+	return unfinished
+}
+
+// running returns true while a vote is in progress. Votes which have not received a result within
+// DurationVoteTimeout are assumed to be over as the result line may have been missed.
+func (v *voteTracker) running(now time.Time) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.current != nil && now.Sub(v.startedAt) < model.DurationVoteTimeout
+}
+
+// cast records a vote in the current vote, replacing any earlier vote by the same player. A copy of the current
+// vote is returned, or nil if no vote is running.
+func (v *voteTracker) cast(cast model.VoteCast) *model.Vote {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.current == nil {
+		return nil
+	}
+	replaced := false
+	for i, known := range v.current.Casts {
+		if known.Name == cast.Name {
+			v.current.Casts[i] = cast
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		v.current.Casts = append(v.current.Casts, cast)
+	}
+	v.current.Yes, v.current.No = 0, 0
+	for _, known := range v.current.Casts {
+		if known.Yes {
+			v.current.Yes++
+		} else {
+			v.current.No++
+		}
+	}
+	vote := *v.current
+	return &vote
+}
+
+// finish records the result of the current vote and returns it, or nil if no vote is running. The tallies of the
+// result are preferred over the counted casts as votes can be cast before the log is being read.
+func (v *voteTracker) finish(result model.VoteResult, yes int, no int) *model.Vote {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	vote := v.current
+	if vote == nil {
+		return nil
+	}
+	vote.Result = result
+	if yes > 0 || no > 0 {
+		vote.Yes, vote.No = yes, no
+	}
+	v.current = nil
+	finished := *vote
+	return &finished
+}
+
+// reset ends the session, returning the current vote if it never received a result
+func (v *voteTracker) reset() *model.Vote {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	unfinished := v.current
+	v.current = nil
+	return unfinished
+}
+
+func (bd *BD) onVoteStart(ctx context.Context, evt voteStartEvent) {
+	vote := &model.Vote{
+		CallerName: evt.callerName,
+		TargetName: evt.targetName,
+		CreatedOn:  evt.createdAt,
+	}
+	if vote.CreatedOn.IsZero() {
+		vote.CreatedOn = time.Now()
+	}
+	if caller := bd.getPlayerByName(evt.callerName); caller != nil {
+		vote.CallerSID = caller.GetSteamID()
+	}
+	if target := bd.getPlayerByName(evt.targetName); target != nil {
+		target.Lock()
+		vote.TargetSID = target.SteamId
+		if !target.Whitelisted {
+			vote.TargetAttributes = target.Matches.Attributes()
+		}
+		// Our own votes are counted when they are called
+		if vote.CallerSID != bd.settings.GetSteamId() {
+			target.KickAttemptCount++
+		}
+		target.KickVotedLast = time.Now()
+		target.Unlock()
+	}
+	bd.serverMu.RLock()
+	vote.ServerName = bd.server.ServerName
+	if bd.server.Addr != nil {
+		vote.ServerAddress = fmt.Sprintf("%s:%d", bd.server.Addr, bd.server.Port)
+	}
+	bd.serverMu.RUnlock()
+	if vote.CallerSID.Valid() && vote.CallerSID == bd.settings.GetSteamId() {
+		bd.kicks.called(time.Now())
+	}
+	bd.logger.Info("Vote started", zap.String("caller", vote.CallerName), zap.String("target", vote.TargetName),
+		zap.Strings("target_attributes", vote.TargetAttributes))
+	if unfinished := bd.votes.start(vote, time.Now()); unfinished != nil {
+		bd.saveVote(ctx, *unfinished)
+	}
+}
+
+func (bd *BD) onVoteCast(evt voteCastEvent) {
+	cast := model.VoteCast{Name: evt.name, Yes: evt.yes, CreatedOn: evt.createdAt}
+	if cast.CreatedOn.IsZero() {
+		cast.CreatedOn = time.Now()
+	}
+	if voter := bd.getPlayerByName(evt.name); voter != nil {
+		cast.SteamId = voter.GetSteamID()
+	}
+	vote := bd.votes.cast(cast)
+	if vote == nil {
+		bd.logger.Debug("Vote cast without a known vote running", zap.String("name", evt.name))
+		return
+	}
+	if vote.TargetMarked() && !cast.Yes {
+		bd.logger.Info("Player voted against kicking a marked player", zap.String("name", cast.Name),
+			zap.Int64("steam_id", cast.SteamId.Int64()), zap.String("target", vote.TargetName),
+			zap.Strings("target_attributes", vote.TargetAttributes))
+	}
+}
+
+func (bd *BD) onVoteResult(ctx context.Context, evt voteResultEvent) {
+	result := model.VoteResultFailed
+	if evt.passed {
+		result = model.VoteResultPassed
+	}
+	vote := bd.votes.finish(result, evt.yes, evt.no)
+	if vote == nil {
+		return
+	}
+	bd.logger.Info("Vote finished", zap.String("target", vote.TargetName), zap.Bool("passed", evt.passed),
+		zap.Int("yes", vote.Yes), zap.Int("no", vote.No))
+	bd.saveVote(ctx, *vote)
+}
+
+// endVoteSession clears the votes of the session when leaving a server, recording the running vote if there is one
+func (bd *BD) endVoteSession(ctx context.Context) {
+	if unfinished := bd.votes.reset(); unfinished != nil {
+		bd.saveVote(ctx, *unfinished)
+	}
+}
+
+// saveVote persists a copy of the vote so that the tracked vote is not modified outside the tracker lock
+func (bd *BD) saveVote(ctx context.Context, vote model.Vote) {
+	if errSave := bd.store.SaveVote(ctx, &vote); errSave != nil {
+		bd.logger.Error("Failed to save vote", zap.Error(errSave))
+	}
+}
@@ -0,0 +1,131 @@
+package detector
+
+import (
+	"bufio"
+	"context"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/internal/store"
+	"github.com/leighmacdonald/bd/pkg/rules"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVoteTracker(t *testing.T) {
+	tracker := newVoteTracker()
+	t0 := time.Now()
+	require.Nil(t, tracker.cast(model.VoteCast{Name: "nobody", Yes: true}))
+	require.Nil(t, tracker.finish(model.VoteResultPassed, 0, 0))
+
+	require.Nil(t, tracker.start(&model.Vote{CallerName: "caller", TargetName: "cheater", TargetAttributes: []string{"cheater"}}, t0))
+	require.True(t, tracker.running(t0))
+	require.False(t, tracker.running(t0.Add(model.DurationVoteTimeout)))
+	require.Equal(t, 1, tracker.cast(model.VoteCast{Name: "caller", Yes: true}).Yes)
+	vote := tracker.cast(model.VoteCast{Name: "defender", Yes: true})
+	require.Equal(t, 2, vote.Yes)
+	// Changing a vote replaces the earlier one
+	vote = tracker.cast(model.VoteCast{Name: "defender", Yes: false})
+	require.Equal(t, 1, vote.Yes)
+	require.Equal(t, 1, vote.No)
+	require.Equal(t, []model.VoteCast{{Name: "defender", Yes: false}}, vote.Defenders())
+
+	finished := tracker.finish(model.VoteResultFailed, 0, 0)
+	require.False(t, tracker.running(t0))
+	require.Equal(t, model.VoteResultFailed, finished.Result)
+	require.Equal(t, 1, finished.No)
+	require.Nil(t, tracker.cast(model.VoteCast{Name: "late", Yes: true}))
+
+	// Results with tallies override the counted casts
+	require.Nil(t, tracker.start(&model.Vote{CallerName: "caller", TargetName: "innocent"}, t0))
+	require.Empty(t, tracker.cast(model.VoteCast{Name: "other", Yes: false}).Defenders())
+	finished = tracker.finish(model.VoteResultPassed, 8, 2)
+	require.Equal(t, 8, finished.Yes)
+	require.Equal(t, 2, finished.No)
+
+	// Unfinished votes are returned so they can still be saved
+	tracker.start(&model.Vote{TargetName: "first"}, t0)
+	unfinished := tracker.start(&model.Vote{TargetName: "second"}, t0)
+	require.Equal(t, "first", unfinished.TargetName)
+	require.Equal(t, model.VoteResultUnknown, unfinished.Result)
+
+	require.Equal(t, "second", tracker.reset().TargetName)
+	require.Nil(t, tracker.reset())
+}
+
+// TestVoteLog replays the vote output of a console log, where a player changes their vote to defend a marked
+// player, and checks that they are recorded as a defender
+func TestVoteLog(t *testing.T) {
+	const (
+		caller   = steamid.SID64(76561197960265730)
+		defender = steamid.SID64(76561197960265731)
+		cheater  = steamid.SID64(76561197960265732)
+	)
+	ctx := context.Background()
+	dataStore := store.New(filepath.Join(t.TempDir(), "votes.sqlite"), zap.NewNop())
+	require.NoError(t, dataStore.Init())
+	defer func() { _ = dataStore.Close() }()
+	settings, errSettings := model.NewSettings()
+	require.NoError(t, errSettings)
+	bd := &BD{
+		logger:   zap.NewNop(),
+		settings: settings,
+		store:    dataStore,
+		players: model.PlayerCollection{
+			model.NewPlayer(caller, "Hassium"),
+			model.NewPlayer(defender, "❤ Ashley ❤"),
+			model.NewPlayer(cheater, "[TrC] Nosy"),
+		},
+		playersMu: &sync.RWMutex{},
+		serverMu:  &sync.RWMutex{},
+		votes:     newVoteTracker(),
+		kicks:     newKickScheduler(),
+	}
+	bd.GetPlayer(cheater).Matches = rules.MatchResults{{Origin: "test", Attributes: []string{"cheater"}, MatcherType: "steam"}}
+
+	fixture, errOpen := os.Open("testdata/votes.log")
+	require.NoError(t, errOpen)
+	defer func() { _ = fixture.Close() }()
+	parser := newLogParser(zap.NewNop(), nil, nil)
+	scanner := bufio.NewScanner(fixture)
+	for scanner.Scan() {
+		var evt model.LogEvent
+		require.NoError(t, parser.parseEvent(scanner.Text(), &evt))
+		switch evt.Type {
+		case model.EvtVoteStart:
+			bd.onVoteStart(ctx, voteStartEvent{callerName: evt.Player, targetName: evt.Victim, createdAt: evt.Timestamp})
+		case model.EvtVoteCast:
+			bd.onVoteCast(voteCastEvent{name: evt.Player, yes: evt.MetaData == "yes", createdAt: evt.Timestamp})
+		case model.EvtVoteResult:
+			bd.onVoteResult(ctx, voteResultEvent{passed: evt.MetaData == "passed", yes: evt.VoteYes, no: evt.VoteNo})
+		case model.EvtVoteCooldown:
+			seconds, errSeconds := strconv.Atoi(evt.MetaData)
+			require.NoError(t, errSeconds)
+			bd.onVoteCooldown(voteCooldownEvent{duration: time.Duration(seconds) * time.Second})
+		}
+	}
+	require.NoError(t, scanner.Err())
+	require.False(t, bd.votes.running(time.Now()))
+	require.True(t, bd.kicks.cooldown().After(time.Now().Add(time.Second*80)))
+
+	votes, errVotes := dataStore.FetchVotes(ctx, 10)
+	require.NoError(t, errVotes)
+	require.Equal(t, 1, len(votes))
+	require.Equal(t, caller, votes[0].CallerSID)
+	require.Equal(t, cheater, votes[0].TargetSID)
+	require.Equal(t, model.VoteResultFailed, votes[0].Result)
+	require.Equal(t, 1, votes[0].Yes)
+	require.Equal(t, 1, votes[0].No)
+	require.Equal(t, 2, len(votes[0].Casts))
+
+	defenders, errDefenders := dataStore.FetchVoteDefenders(ctx, 10)
+	require.NoError(t, errDefenders)
+	require.Equal(t, 1, len(defenders))
+	require.Equal(t, defender, defenders[0].SteamId)
+	require.Equal(t, 1, defenders[0].Count)
+}
@@ -15,6 +15,21 @@ const (
 	DurationAnnounceMatchTimeout = time.Minute * 5
 	DurationCacheTimeout         = time.Hour * 12
 	DurationWebRequestTimeout    = time.Second * 5
+	DurationListRefresh          = time.Hour
+	DurationListRefreshMin       = time.Minute * 5
+	DurationListRetryBackoff     = time.Second
+	DurationListWatchDebounce    = time.Millisecond * 500
+	DurationChatFloodWindow      = time.Second * 10
+	DurationChatRepeatWindow     = time.Minute
+	DurationChatWaveWindow       = time.Second * 30
+	DurationChatSpamExpiry       = time.Minute * 5
+	DurationMarkSweepTimer       = time.Minute
+	DurationKickAttemptInterval  = time.Second * 15
+	DurationVoteTimeout          = time.Second * 30
+	DurationKickStatusInterval   = time.Second * 30
+	DurationKickPeerExpiry       = time.Second * 75
+	DurationKickClaim            = time.Minute
+	DurationRageQuitWindow       = time.Second * 30
 	DurationRCONRequestTimeout   = time.Second
 	DurationProcessTimeout       = time.Second * 3
 )
@@ -39,6 +54,12 @@ const (
 	EvtTags
 	EvtAddress
 	EvtLobby
+	EvtVoteStart
+	EvtVoteCast
+	EvtVoteResult
+	EvtVoteCooldown
+	EvtPlayerDisconnect
+	EvtSuicide
 )
 
 type SteamIDFunc func(sid64 steamid.SID64)
@@ -57,7 +78,17 @@ type SavePlayer func(ctx context.Context, state *Player) error
 
 type SearchPlayers func(ctx context.Context, opts SearchOpts) (PlayerCollection, error)
 
-type MarkFunc func(sid64 steamid.SID64, attrs []string) error
+// MarkDuration is how long a mark lasts before it is removed
+type MarkDuration time.Duration
+
+const (
+	// MarkPermanent marks never expire
+	MarkPermanent MarkDuration = 0
+	// MarkTransient marks only last until leaving the current server or closing the application
+	MarkTransient MarkDuration = -1
+)
+
+type MarkFunc func(sid64 steamid.SID64, attrs []string, duration MarkDuration) error
 
 type NoteFunc func(sid64 steamid.SID64, note string) error
 
@@ -86,8 +117,18 @@ type LaunchFunc func()
 
 type QueryNamesFunc func(ctx context.Context, sid64 steamid.SID64) (UserNameHistoryCollection, error)
 
+type QueryRageQuitsFunc func(ctx context.Context, sid64 steamid.SID64) (RageQuitCollection, error)
+
 type QueryUserMessagesFunc func(ctx context.Context, sid64 steamid.SID64) (UserMessageCollection, error)
 
+type QueryKillsFunc func(ctx context.Context, sid64 steamid.SID64, limit uint64) ([]Kill, error)
+
+type QueryWeaponStatsFunc func(ctx context.Context, sid64 steamid.SID64) (WeaponStatsCollection, error)
+
+type QueryVotesFunc func(ctx context.Context, limit uint64) ([]*Vote, error)
+
+type QueryVoteDefendersFunc func(ctx context.Context, limit int) ([]VoteDefender, error)
+
 type Version struct {
 	Version string
 	Commit  string
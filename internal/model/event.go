@@ -28,6 +28,16 @@ type LogEvent struct {
 	MetaData        string
 	Dead            bool
 	TeamOnly        bool
+	// Weapon and Crit are parsed from the kill feed. Suicide is set for suicides and environmental deaths, which
+	// have the victim as the player.
+	Weapon  string
+	Crit    bool
+	Suicide bool
+	// Party is set for party chat messages, which are only seen by members of our party
+	Party bool
+	// VoteYes and VoteNo are the final tallies of a vote result, when shown
+	VoteYes int
+	VoteNo  int
 }
 
 func (e *LogEvent) ApplyTimestamp(tsString string) error {
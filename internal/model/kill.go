@@ -0,0 +1,88 @@
+package model
+
+import (
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"time"
+)
+
+// WeaponWorld is used as the weapon of environmental deaths, such as falling or being crushed
+const WeaponWorld = "world"
+
+const (
+	// AnomalyMinKills is the number of kills with a weapon required before its crit rate is considered meaningful
+	AnomalyMinKills = 20
+	// AnomalyHeadshotRate is the share of kills with a headshot weapon being crits which is considered suspicious
+	AnomalyHeadshotRate = 0.95
+)
+
+// headshotWeapons are the weapons, as named in the kill feed, whose crits are headshots
+var headshotWeapons = map[string]bool{
+	"sniperrifle":         true,
+	"festive_sniperrifle": true,
+	"machina":             true,
+	"awper_hand":          true,
+	"pro_rifle":           true,
+	"bazaar_bargain":      true,
+	"shooting_star":       true,
+	"the_classic":         true,
+	"ambassador":          true,
+	"festive_ambassador":  true,
+	"tf_projectile_arrow": true,
+}
+
+// IsHeadshotWeapon returns true when the crits of the weapon are headshots
+func IsHeadshotWeapon(weapon string) bool {
+	return headshotWeapons[weapon]
+}
+
+// Kill is a single kill feed entry. Suicides and environmental deaths have the victim as the killer.
+type Kill struct {
+	KillId     int64
+	ServerName string
+	MapName    string
+	KillerSID  steamid.SID64
+	KillerName string
+	// AssisterSID and AssisterName are unset when nobody assisted the kill
+	AssisterSID  steamid.SID64
+	AssisterName string
+	VictimSID    steamid.SID64
+	VictimName   string
+	Weapon       string
+	Crit         bool
+	Suicide      bool
+	CreatedOn    time.Time
+}
+
+// WeaponStats is the number of kills a player has made with a weapon
+type WeaponStats struct {
+	Weapon string
+	Kills  int
+	Crits  int
+}
+
+// CritRate is the share of kills which were crits
+func (s WeaponStats) CritRate() float64 {
+	if s.Kills == 0 {
+		return 0
+	}
+	return float64(s.Crits) / float64(s.Kills)
+}
+
+// Anomalous returns true when a headshot weapon has an unrealistically high headshot rate over enough kills to
+// rule out a lucky streak
+func (s WeaponStats) Anomalous() bool {
+	return IsHeadshotWeapon(s.Weapon) && s.Kills >= AnomalyMinKills && s.CritRate() >= AnomalyHeadshotRate
+}
+
+type WeaponStatsCollection []WeaponStats
+
+// Anomalies returns the weapons with suspicious stats
+func (stats WeaponStatsCollection) Anomalies() WeaponStatsCollection {
+	var anomalies WeaponStatsCollection
+	for _, s := range stats {
+		if s.Anomalous() {
+			anomalies = append(anomalies, s)
+		}
+	}
+	return anomalies
+}
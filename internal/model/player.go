@@ -48,7 +48,8 @@ type Player struct {
 	RageQuits int
 	DeathsBy  int
 
-	Notes       string
+	Notes string
+	// Whitelisted is true when the player is on any whitelist loaded in the rules engine, see WhitelistMatch
 	Whitelisted bool
 
 	// PlayerSummary
@@ -58,12 +59,16 @@ type Player struct {
 
 	Visibility ProfileVisibility
 	AvatarHash string
+	// AvatarPerceptualHash is the rules.PerceptualHash of the avatar identified by AvatarHash. It is
+	// stored so that avatar rules can be matched without downloading the avatar again.
+	AvatarPerceptualHash string
 
 	// PlayerBanState
 	CommunityBanned  bool
 	NumberOfVACBans  int
 	LastVACBanOn     *time.Time
 	NumberOfGameBans int
+	LastGameBanOn    *time.Time
 	EconomyBan       bool
 
 	// - Parsed Ephemeral data
@@ -84,11 +89,16 @@ type Player struct {
 
 	// - Misc
 
-	// Incremented on each kick attempt. Used to cycle through and not attempt the same bot
+	// Incremented on each kick vote against the player, by anyone. Used to cycle through and not attempt the same bot
 	KickAttemptCount int
 
-	// Tracks the duration between announces to chat
-	AnnouncedPartyLast time.Time
+	// KilledByUsLast and KickVotedLast are the last time the player was killed by us and had a kick vote called
+	// against them, used to detect rage quits
+	KilledByUsLast time.Time
+	KickVotedLast  time.Time
+
+	// ActionsLast tracks when each policy action was last taken against the player so that cooldowns can be applied
+	ActionsLast map[ActionType]time.Time
 
 	AnnouncedGeneralLast time.Time
 
@@ -100,11 +110,15 @@ type Player struct {
 	// Dirty indicates that state which has database backed fields has been changed and need to be saved
 	Dirty bool
 
-	Match *rules.MatchResult
+	// Matches contains every list and rule match found for the player
+	Matches rules.MatchResults
+
+	// WhitelistMatch records the whitelist which the player is on, nil when they are not whitelisted
+	WhitelistMatch *rules.MatchResult
 }
 
 func (ps *Player) IsMatched() bool {
-	return ps.Match != nil
+	return len(ps.Matches) > 0
 }
 
 func (ps *Player) GetSteamID() steamid.SID64 {
@@ -119,6 +133,38 @@ func (ps *Player) GetAvatarHash() string {
 	return ps.AvatarHash
 }
 
+// MatchInput returns the known state of the player which rules are evaluated against
+func (ps *Player) MatchInput() rules.MatchInput {
+	input := rules.MatchInput{
+		Name:                 ps.Name,
+		AvatarHash:           ps.AvatarHash,
+		AvatarPerceptualHash: ps.AvatarPerceptualHash,
+	}
+	if !ps.ProfileUpdatedOn.IsZero() {
+		profile := rules.ProfileInput{
+			Private:         ps.Visibility != ProfileVisibilityPublic,
+			CommunityBanned: ps.CommunityBanned,
+			VACBans:         ps.NumberOfVACBans,
+			GameBans:        ps.NumberOfGameBans,
+			LastBanOn:       ps.LastBanOn(),
+		}
+		// Private profiles report a creation time of 0
+		if ps.AccountCreatedOn.Unix() > 0 {
+			profile.AccountCreatedOn = ps.AccountCreatedOn
+		}
+		input.Profile = &profile
+	}
+	return input
+}
+
+// LastBanOn returns the most recent of the last VAC and game ban, or nil when the player has neither
+func (ps *Player) LastBanOn() *time.Time {
+	if ps.LastVACBanOn == nil || ps.LastGameBanOn != nil && ps.LastGameBanOn.After(*ps.LastVACBanOn) {
+		return ps.LastGameBanOn
+	}
+	return ps.LastVACBanOn
+}
+
 func (ps *Player) IsDisconnected() bool {
 	return time.Since(ps.UpdatedOn) > DurationDisconnected
 }
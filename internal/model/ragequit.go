@@ -0,0 +1,39 @@
+package model
+
+import (
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"time"
+)
+
+// RageQuitCause is what a player was reacting to when they left the server
+type RageQuitCause string
+
+const (
+	// RageQuitCauseDeath is used when the player left shortly after being killed by us
+	RageQuitCauseDeath RageQuitCause = "death"
+	// RageQuitCauseKickVote is used when the player left shortly after a kick vote was called against them
+	RageQuitCauseKickVote RageQuitCause = "kick_vote"
+)
+
+// RageQuit is a single incident of a player leaving the server in response to a death or kick vote
+type RageQuit struct {
+	RageQuitId int64
+	SteamId    steamid.SID64
+	Name       string
+	ServerName string
+	MapName    string
+	// Reason is the disconnect reason shown in the console, eg: Disconnect by user.
+	Reason    string
+	Cause     RageQuitCause
+	CreatedOn time.Time
+}
+
+type RageQuitCollection []RageQuit
+
+func (rageQuits RageQuitCollection) AsAny() []any {
+	bl := make([]any, len(rageQuits))
+	for i, r := range rageQuits {
+		bl[i] = r
+	}
+	return bl
+}
@@ -17,6 +17,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 const configRoot = "bd"
@@ -33,6 +34,8 @@ const (
 	//ListTypeBD              ListType = "bd"
 	ListTypeTF2BDPlayerList ListType = "tf2bd_playerlist"
 	ListTypeTF2BDRules      ListType = "tf2bd_rules"
+	// ListTypeTF2BDWhitelist lists use the player list format, every player on the list is whitelisted
+	ListTypeTF2BDWhitelist ListType = "tf2bd_whitelist"
 	//ListTypeUnknown         ListType = "unknown"
 )
 
@@ -41,6 +44,23 @@ type ListConfig struct {
 	Name     string   `yaml:"name"`
 	Enabled  bool     `yaml:"enabled"`
 	URL      string   `yaml:"url"`
+	// Strict lists are rejected entirely when any entry fails schema validation. Otherwise the invalid entries are
+	// skipped and counted in the list status, so that a single bad entry does not discard a remote list.
+	Strict bool `yaml:"strict"`
+}
+
+// ListStatus records the results of the most recent attempts to update a list
+type ListStatus struct {
+	// LastSuccess is when the list was last successfully fetched
+	LastSuccess time.Time
+	// LastError is the error from the last attempt, nil if it succeeded
+	LastError error
+	// Count is the number of entries in the list when it was last parsed
+	Count int
+	// Dropped is the number of invalid entries skipped when the list was last parsed, always 0 for strict lists
+	Dropped int
+	// Duration is how long the last attempt took, including any retries
+	Duration time.Duration
 }
 
 // TODO add to steamid pkg
@@ -72,6 +92,75 @@ func (list LinkConfigCollection) AsAny() []any {
 
 type ListConfigCollection []*ListConfig
 
+// ActionType is an action which can be taken against a matched player
+type ActionType string
+
+const (
+	// ActionPartyWarning announces the match to the party chat
+	ActionPartyWarning ActionType = "party_warning"
+	// ActionChatWarning announces the match to all chat
+	ActionChatWarning ActionType = "chat_warning"
+	// ActionKick calls a vote to kick the player
+	ActionKick ActionType = "kick"
+	// ActionVoiceMute adds the player to the generated voice_ban.dt
+	ActionVoiceMute ActionType = "voice_mute"
+)
+
+// ActionTypes returns all the known action types
+func ActionTypes() []ActionType {
+	return []ActionType{ActionPartyWarning, ActionChatWarning, ActionKick, ActionVoiceMute}
+}
+
+// PolicyAttributeDefault is the attribute of the policy applied to matches which have no policy for any of
+// their attributes
+const PolicyAttributeDefault = "*"
+
+// PolicyAction is a single step of a policy
+type PolicyAction struct {
+	Type ActionType `yaml:"type"`
+	// Cooldown is the minimum time between taking the action against the same player, eg: 30s, 5m
+	Cooldown string `yaml:"cooldown"`
+}
+
+// GetCooldown returns the parsed cooldown of the action. Invalid or empty cooldowns are treated as no cooldown.
+func (a PolicyAction) GetCooldown() time.Duration {
+	cooldown, errParse := time.ParseDuration(a.Cooldown)
+	if errParse != nil || cooldown < 0 {
+		return 0
+	}
+	return cooldown
+}
+
+// ActionPolicy defines the actions, in order, taken against players matching the attribute
+type ActionPolicy struct {
+	Attribute string         `yaml:"attribute"`
+	Actions   []PolicyAction `yaml:"actions"`
+}
+
+type ActionPolicyCollection []*ActionPolicy
+
+func (list ActionPolicyCollection) AsAny() []any {
+	bl := make([]any, len(list))
+	for i, r := range list {
+		bl[i] = r
+	}
+	return bl
+}
+
+// NewDefaultPolicies creates the policies equivalent to the fixed behaviour used before policies existed: every
+// match is announced to the party and players with one of the kickTags are also kicked and voice muted.
+func NewDefaultPolicies(kickTags []string) ActionPolicyCollection {
+	announce := PolicyAction{Type: ActionPartyWarning, Cooldown: DurationAnnounceMatchTimeout.String()}
+	policies := ActionPolicyCollection{{Attribute: PolicyAttributeDefault, Actions: []PolicyAction{announce}}}
+	for _, tag := range kickTags {
+		policies = append(policies, &ActionPolicy{
+			Attribute: tag,
+			Actions:   []PolicyAction{announce, {Type: ActionKick, Cooldown: "0s"}, {Type: ActionVoiceMute, Cooldown: "0s"}},
+		})
+	}
+	return policies
+}
+
 func (list ListConfigCollection) AsAny() []any {
 	bl := make([]any, len(list))
 	for i, r := range list {
@@ -89,22 +178,24 @@ type Settings struct {
 	// eg: -> ~/.local/share/Steam/userdata/123456789/config/localconfig.vdf
 	SteamDir string `yaml:"steam_dir"`
 	// Path to tf2 mod (C:\Program Files (x86)\Steam\steamapps\common\Team Fortress 2\tf)
-	TF2Dir                 string               `yaml:"tf2_dir"`
-	AutoLaunchGame         bool                 `yaml:"auto_launch_game_auto"`
-	AutoCloseOnGameExit    bool                 `yaml:"auto_close_on_game_exit"`
-	APIKey                 string               `yaml:"api_key"`
-	DisconnectedTimeout    string               `yaml:"disconnected_timeout"`
-	DiscordPresenceEnabled bool                 `yaml:"discord_presence_enabled"`
-	KickerEnabled          bool                 `yaml:"kicker_enabled"`
-	ChatWarningsEnabled    bool                 `yaml:"chat_warnings_enabled"`
-	PartyWarningsEnabled   bool                 `yaml:"party_warnings_enabled"`
-	KickTags               []string             `yaml:"kick_tags"`
-	VoiceBansEnabled       bool                 `yaml:"voice_bans_enabled"`
-	DebugLogEnabled        bool                 `yaml:"debug_log_enabled"`
-	Lists                  ListConfigCollection `yaml:"lists"`
-	Links                  []*LinkConfig        `yaml:"links"`
-	RCONStatic             bool                 `yaml:"rcon_static"`
-	rcon                   RCONConfigProvider   `yaml:"-"`
+	TF2Dir                 string                 `yaml:"tf2_dir"`
+	AutoLaunchGame         bool                   `yaml:"auto_launch_game_auto"`
+	AutoCloseOnGameExit    bool                   `yaml:"auto_close_on_game_exit"`
+	APIKey                 string                 `yaml:"api_key"`
+	DisconnectedTimeout    string                 `yaml:"disconnected_timeout"`
+	ListRefreshInterval    string                 `yaml:"list_refresh_interval"`
+	DiscordPresenceEnabled bool                   `yaml:"discord_presence_enabled"`
+	KickerEnabled          bool                   `yaml:"kicker_enabled"`
+	ChatWarningsEnabled    bool                   `yaml:"chat_warnings_enabled"`
+	PartyWarningsEnabled   bool                   `yaml:"party_warnings_enabled"`
+	KickTags               []string               `yaml:"kick_tags"`
+	Policies               ActionPolicyCollection `yaml:"policies"`
+	VoiceBansEnabled       bool                   `yaml:"voice_bans_enabled"`
+	DebugLogEnabled        bool                   `yaml:"debug_log_enabled"`
+	Lists                  ListConfigCollection   `yaml:"lists"`
+	Links                  []*LinkConfig          `yaml:"links"`
+	RCONStatic             bool                   `yaml:"rcon_static"`
+	rcon                   RCONConfigProvider     `yaml:"-"`
 }
 
 func (s *Settings) GetVoiceBansEnabled() bool {
@@ -119,6 +210,24 @@ func (s *Settings) SetVoiceBansEnabled(enabled bool) {
 	s.VoiceBansEnabled = enabled
 }
 
+// GetListRefreshInterval returns how often remote lists are checked for updates. Invalid values fall back
+// to the default interval.
+func (s *Settings) GetListRefreshInterval() time.Duration {
+	s.RLock()
+	defer s.RUnlock()
+	interval, errParse := time.ParseDuration(s.ListRefreshInterval)
+	if errParse != nil || interval < DurationListRefreshMin {
+		return DurationListRefresh
+	}
+	return interval
+}
+
+func (s *Settings) SetListRefreshInterval(interval string) {
+	s.Lock()
+	defer s.Unlock()
+	s.ListRefreshInterval = interval
+}
+
 func (s *Settings) GetDebugLogEnabled() bool {
 	s.RLock()
 	defer s.RUnlock()
@@ -249,6 +358,12 @@ func (s *Settings) GetDiscordPresenceEnabled() bool {
 	return s.DiscordPresenceEnabled
 }
 
+func (s *Settings) GetChatWarningsEnabled() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.ChatWarningsEnabled
+}
+
 func (s *Settings) GetPartyWarningsEnabled() bool {
 	s.RLock()
 	defer s.RUnlock()
@@ -283,12 +398,26 @@ func (s *Settings) GetLists() ListConfigCollection {
 	return s.Lists
 }
 
+// GetKickTags returns the legacy kick tags. They are only used to create the default policies when reading
+// configs written before policies existed.
 func (s *Settings) GetKickTags() []string {
 	s.RLock()
 	defer s.RUnlock()
 	return s.KickTags
 }
 
+func (s *Settings) GetPolicies() ActionPolicyCollection {
+	s.RLock()
+	defer s.RUnlock()
+	return s.Policies
+}
+
+func (s *Settings) SetPolicies(policies ActionPolicyCollection) {
+	s.Lock()
+	defer s.Unlock()
+	s.Policies = policies
+}
+
 func (s *Settings) GetSteamId() steamid.SID64 {
 	value, err := steamid.StringToSID64(s.SteamID)
 	if err != nil {
@@ -324,6 +453,7 @@ func NewSettings() (*Settings, error) {
 		TF2Dir:                 platform.DefaultTF2Root,
 		APIKey:                 "",
 		DisconnectedTimeout:    "60s",
+		ListRefreshInterval:    DurationListRefresh.String(),
 		DiscordPresenceEnabled: true,
 		KickerEnabled:          false,
 		AutoCloseOnGameExit:    false,
@@ -331,6 +461,7 @@ func NewSettings() (*Settings, error) {
 		KickTags:               []string{"cheater", "bot", "trigger_name", "trigger_msg"},
 		ChatWarningsEnabled:    false,
 		PartyWarningsEnabled:   true,
+		Policies:               NewDefaultPolicies([]string{"cheater", "bot", "trigger_name", "trigger_msg"}),
 		Lists: []*ListConfig{
 			{
 				Name:     "Uncletopia",
@@ -462,6 +593,10 @@ func (s *Settings) LocalPlayerListPath() string {
 	return filepath.Join(s.ListRoot(), fmt.Sprintf("playerlist.%s.json", rules.LocalRuleName))
 }
 
+func (s *Settings) LocalWhitelistPath() string {
+	return filepath.Join(s.ListRoot(), fmt.Sprintf("whitelist.%s.json", rules.LocalRuleName))
+}
+
 func (s *Settings) LocalRulesListPath() string {
 	return filepath.Join(s.ListRoot(), fmt.Sprintf("rules.%s.json", rules.LocalRuleName))
 }
@@ -486,10 +621,15 @@ func (s *Settings) ReadFilePath(filePath string) error {
 
 func (s *Settings) Read(inputFile io.Reader) error {
 	s.Lock()
+	// Configs written before policies existed are upgraded using their kick tags
+	s.Policies = nil
 	if errDecode := yaml.NewDecoder(inputFile).Decode(&s); errDecode != nil {
 		s.Unlock()
 		return errDecode
 	}
+	if s.Policies == nil {
+		s.Policies = NewDefaultPolicies(s.KickTags)
+	}
 	s.Unlock()
 	s.reload()
 	return nil
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"time"
+)
+
+// VoteResult is the outcome of a vote
+type VoteResult int
+
+const (
+	// VoteResultUnknown is used for votes which are still running or whose result was never seen, such as
+	// when leaving the server during a vote
+	VoteResultUnknown VoteResult = iota
+	VoteResultPassed
+	VoteResultFailed
+)
+
+// VoteCast is a single player's vote
+type VoteCast struct {
+	SteamId   steamid.SID64
+	Name      string
+	Yes       bool
+	CreatedOn time.Time
+}
+
+// Vote is a kick vote called on a server
+type Vote struct {
+	VoteId        int64
+	ServerName    string
+	ServerAddress string
+	CallerSID     steamid.SID64
+	CallerName    string
+	TargetSID     steamid.SID64
+	TargetName    string
+	// TargetAttributes are the attributes of the target when the vote was called, empty when the target
+	// was not marked
+	TargetAttributes []string
+	Yes              int
+	No               int
+	Result           VoteResult
+	Casts            []VoteCast
+	CreatedOn        time.Time
+}
+
+// TargetMarked returns true if the target of the vote was marked when the vote was called
+func (v Vote) TargetMarked() bool {
+	return len(v.TargetAttributes) > 0
+}
+
+// Defenders returns the votes cast against kicking a marked player
+func (v Vote) Defenders() []VoteCast {
+	if !v.TargetMarked() {
+		return nil
+	}
+	var defenders []VoteCast
+	for _, cast := range v.Casts {
+		if !cast.Yes {
+			defenders = append(defenders, cast)
+		}
+	}
+	return defenders
+}
+
+// VoteDefender summarises how often a player has voted against kicking marked players
+type VoteDefender struct {
+	SteamId        steamid.SID64
+	Name           string
+	Count          int
+	LastDefendedOn time.Time
+}
@@ -0,0 +1 @@
+alter table player drop column avatar_perceptual_hash;
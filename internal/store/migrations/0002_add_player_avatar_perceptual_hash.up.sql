@@ -0,0 +1 @@
+alter table player add column avatar_perceptual_hash text not null default '';
@@ -0,0 +1,2 @@
+drop table if exists vote_cast;
+drop table if exists vote;
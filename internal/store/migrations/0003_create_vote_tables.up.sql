@@ -0,0 +1,30 @@
+create table if not exists vote
+(
+    vote_id integer primary key,
+    server_name text not null default '',
+    server_address text not null default '',
+    caller_steam_id integer not null default 0,
+    caller_name text not null default '',
+    target_steam_id integer not null default 0,
+    target_name text not null default '',
+    target_attributes text not null default '',
+    yes_count integer not null default 0,
+    no_count integer not null default 0,
+    result integer not null default 0 check ( result >= 0 AND result <= 2 ),
+    created_on date not null default (DATETIME('now'))
+);
+
+create index if not exists idx_vote_target on vote (target_steam_id);
+
+create table if not exists vote_cast
+(
+    vote_cast_id integer primary key,
+    vote_id integer not null,
+    steam_id integer not null default 0,
+    name text not null default '',
+    vote_yes boolean not null,
+    created_on date not null default (DATETIME('now')),
+    foreign key (vote_id) references vote (vote_id) on delete cascade
+);
+
+create index if not exists idx_vote_cast_steam_id on vote_cast (steam_id);
@@ -0,0 +1 @@
+drop table if exists rage_quit;
@@ -0,0 +1,13 @@
+create table if not exists rage_quit
+(
+    rage_quit_id integer primary key,
+    steam_id integer not null,
+    name text not null default '',
+    server_name text not null default '',
+    map_name text not null default '',
+    reason text not null default '',
+    cause text not null default '',
+    created_on date not null default (DATETIME('now'))
+);
+
+create index if not exists idx_rage_quit_steam_id on rage_quit (steam_id);
@@ -0,0 +1 @@
+drop table if exists player_kill;
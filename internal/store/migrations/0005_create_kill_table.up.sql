@@ -0,0 +1,19 @@
+create table if not exists player_kill
+(
+    kill_id integer primary key,
+    server_name text not null default '',
+    map_name text not null default '',
+    killer_steam_id integer not null default 0,
+    killer_name text not null default '',
+    assister_steam_id integer not null default 0,
+    assister_name text not null default '',
+    victim_steam_id integer not null default 0,
+    victim_name text not null default '',
+    weapon text not null default '',
+    crit boolean not null default false,
+    suicide boolean not null default false,
+    created_on date not null default (DATETIME('now'))
+);
+
+create index if not exists idx_player_kill_killer on player_kill (killer_steam_id, weapon);
+create index if not exists idx_player_kill_victim on player_kill (victim_steam_id);
@@ -0,0 +1 @@
+alter table player drop column last_game_ban_on;
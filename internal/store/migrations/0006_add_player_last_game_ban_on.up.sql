@@ -0,0 +1 @@
+alter table player add column last_game_ban_on date;
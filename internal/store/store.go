@@ -14,6 +14,8 @@ import (
 	"github.com/leighmacdonald/steamid/v2/steamid"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -32,6 +34,16 @@ type DataStore interface {
 	FetchMessages(ctx context.Context, sid steamid.SID64) (model.UserMessageCollection, error)
 	LoadOrCreatePlayer(ctx context.Context, steamID steamid.SID64, player *model.Player) error
 	GetPlayer(ctx context.Context, steamID steamid.SID64, player *model.Player) error
+	FetchWhitelisted(ctx context.Context) (steamid.Collection, error)
+	ClearWhitelisted(ctx context.Context) error
+	SaveVote(ctx context.Context, vote *model.Vote) error
+	FetchVotes(ctx context.Context, limit uint64) ([]*model.Vote, error)
+	FetchVoteDefenders(ctx context.Context, limit int) ([]model.VoteDefender, error)
+	SaveRageQuit(ctx context.Context, rageQuit *model.RageQuit) error
+	FetchRageQuits(ctx context.Context, sid64 steamid.SID64) (model.RageQuitCollection, error)
+	SaveKill(ctx context.Context, kill *model.Kill) error
+	FetchKills(ctx context.Context, sid64 steamid.SID64, limit uint64) ([]model.Kill, error)
+	FetchWeaponStats(ctx context.Context, sid64 steamid.SID64) (model.WeaponStatsCollection, error)
 }
 
 type SqliteStore struct {
@@ -133,11 +145,11 @@ func (store *SqliteStore) insertPlayer(ctx context.Context, state *model.Player)
 	query, args, errSql := sq.
 		Insert("player").
 		Columns("steam_id", "visibility", "real_name", "account_created_on", "avatar_hash",
-			"community_banned", "game_bans", "vac_bans", "last_vac_ban_on", "kills_on", "deaths_by",
-			"rage_quits", "notes", "whitelist", "created_on", "updated_on", "profile_updated_on").
+			"avatar_perceptual_hash", "community_banned", "game_bans", "vac_bans", "last_vac_ban_on", "last_game_ban_on", "kills_on", "deaths_by",
+			"rage_quits", "notes", "created_on", "updated_on", "profile_updated_on").
 		Values(state.SteamId.Int64(), state.Visibility, state.RealName, state.AccountCreatedOn, state.AvatarHash,
-			state.CommunityBanned, state.NumberOfGameBans, state.NumberOfVACBans, state.LastVACBanOn, state.KillsOn,
-			state.DeathsBy, state.RageQuits, state.Notes, state.Whitelisted, state.CreatedOn,
+			state.AvatarPerceptualHash, state.CommunityBanned, state.NumberOfGameBans, state.NumberOfVACBans, state.LastVACBanOn, state.LastGameBanOn, state.KillsOn,
+			state.DeathsBy, state.RageQuits, state.Notes, state.CreatedOn,
 			state.UpdatedOn, state.ProfileUpdatedOn).
 		ToSql()
 	if errSql != nil {
@@ -158,15 +170,16 @@ func (store *SqliteStore) updatePlayer(ctx context.Context, state *model.Player)
 		Set("real_name", state.RealName).
 		Set("account_created_on", state.AccountCreatedOn).
 		Set("avatar_hash", state.AvatarHash).
+		Set("avatar_perceptual_hash", state.AvatarPerceptualHash).
 		Set("community_banned", state.CommunityBanned).
 		Set("game_bans", state.NumberOfGameBans).
 		Set("vac_bans", state.NumberOfVACBans).
 		Set("last_vac_ban_on", state.LastVACBanOn).
+		Set("last_game_ban_on", state.LastGameBanOn).
 		Set("kills_on", state.KillsOn).
 		Set("deaths_by", state.DeathsBy).
 		Set("rage_quits", state.RageQuits).
 		Set("notes", state.Notes).
-		Set("whitelist", state.Whitelisted).
 		Set("updated_on", state.UpdatedOn).
 		Set("profile_updated_on", state.ProfileUpdatedOn).
 		Where(sq.Eq{"steam_id": state.SteamId}).ToSql()
@@ -193,8 +206,8 @@ func (store *SqliteStore) SavePlayer(ctx context.Context, state *model.Player) e
 func (store *SqliteStore) SearchPlayers(ctx context.Context, opts model.SearchOpts) (model.PlayerCollection, error) {
 	qb := sq.
 		Select("p.steam_id", "p.visibility", "p.real_name", "p.account_created_on", "p.avatar_hash",
-			"p.community_banned", "p.game_bans", "p.vac_bans", "p.last_vac_ban_on", "p.kills_on", "p.deaths_by",
-			"p.rage_quits", "p.notes", "p.whitelist", "p.created_on", "p.updated_on", "p.profile_updated_on", "pn.name").
+			"p.avatar_perceptual_hash", "p.community_banned", "p.game_bans", "p.vac_bans", "p.last_vac_ban_on", "p.last_game_ban_on", "p.kills_on", "p.deaths_by",
+			"p.rage_quits", "p.notes", "p.created_on", "p.updated_on", "p.profile_updated_on", "pn.name").
 		From("player p").
 		LeftJoin("player_names pn ON p.steam_id = pn.steam_id ").
 		OrderBy("p.updated_on DESC").
@@ -220,9 +233,9 @@ func (store *SqliteStore) SearchPlayers(ctx context.Context, opts model.SearchOp
 		var prevName *string
 		var player model.Player
 		if errScan := rows.Scan(&player.SteamId, &player.Visibility, &player.RealName, &player.AccountCreatedOn, &player.AvatarHash,
-			&player.CommunityBanned, &player.NumberOfGameBans, &player.NumberOfVACBans,
-			&player.LastVACBanOn, &player.KillsOn, &player.DeathsBy, &player.RageQuits, &player.Notes,
-			&player.Whitelisted, &player.CreatedOn, &player.UpdatedOn, &player.ProfileUpdatedOn, &prevName,
+			&player.AvatarPerceptualHash, &player.CommunityBanned, &player.NumberOfGameBans, &player.NumberOfVACBans,
+			&player.LastVACBanOn, &player.LastGameBanOn, &player.KillsOn, &player.DeathsBy, &player.RageQuits, &player.Notes,
+			&player.CreatedOn, &player.UpdatedOn, &player.ProfileUpdatedOn, &prevName,
 		); errScan != nil {
 			return nil, errScan
 		}
@@ -239,8 +252,8 @@ func (store *SqliteStore) SearchPlayers(ctx context.Context, opts model.SearchOp
 func (store *SqliteStore) GetPlayer(ctx context.Context, steamID steamid.SID64, player *model.Player) error {
 	query, args, errSql := sq.
 		Select("p.visibility", "p.real_name", "p.account_created_on", "p.avatar_hash",
-			"p.community_banned", "p.game_bans", "p.vac_bans", "p.last_vac_ban_on", "p.kills_on", "p.deaths_by",
-			"p.rage_quits", "p.notes", "p.whitelist", "p.created_on", "p.updated_on", "p.profile_updated_on", "pn.name").
+			"p.avatar_perceptual_hash", "p.community_banned", "p.game_bans", "p.vac_bans", "p.last_vac_ban_on", "p.last_game_ban_on", "p.kills_on", "p.deaths_by",
+			"p.rage_quits", "p.notes", "p.created_on", "p.updated_on", "p.profile_updated_on", "pn.name").
 		From("player p").
 		LeftJoin("player_names pn ON p.steam_id = pn.steam_id ").
 		Where(sq.Eq{"p.steam_id": steamID}).
@@ -254,9 +267,9 @@ func (store *SqliteStore) GetPlayer(ctx context.Context, steamID steamid.SID64,
 	rowErr := store.db.
 		QueryRowContext(ctx, query, args...).
 		Scan(&player.Visibility, &player.RealName, &player.AccountCreatedOn, &player.AvatarHash,
-			&player.CommunityBanned, &player.NumberOfGameBans, &player.NumberOfVACBans,
-			&player.LastVACBanOn, &player.KillsOn, &player.DeathsBy, &player.RageQuits, &player.Notes,
-			&player.Whitelisted, &player.CreatedOn, &player.UpdatedOn, &player.ProfileUpdatedOn, &prevName,
+			&player.AvatarPerceptualHash, &player.CommunityBanned, &player.NumberOfGameBans, &player.NumberOfVACBans,
+			&player.LastVACBanOn, &player.LastGameBanOn, &player.KillsOn, &player.DeathsBy, &player.RageQuits, &player.Notes,
+			&player.CreatedOn, &player.UpdatedOn, &player.ProfileUpdatedOn, &prevName,
 		)
 	if rowErr != nil {
 		if rowErr != sql.ErrNoRows {
@@ -275,8 +288,8 @@ func (store *SqliteStore) GetPlayer(ctx context.Context, steamID steamid.SID64,
 func (store *SqliteStore) LoadOrCreatePlayer(ctx context.Context, steamID steamid.SID64, player *model.Player) error {
 	query, args, errSql := sq.
 		Select("p.visibility", "p.real_name", "p.account_created_on", "p.avatar_hash",
-			"p.community_banned", "p.game_bans", "p.vac_bans", "p.last_vac_ban_on", "p.kills_on", "p.deaths_by",
-			"p.rage_quits", "p.notes", "p.whitelist", "p.created_on", "p.updated_on", "p.profile_updated_on", "pn.name").
+			"p.avatar_perceptual_hash", "p.community_banned", "p.game_bans", "p.vac_bans", "p.last_vac_ban_on", "p.last_game_ban_on", "p.kills_on", "p.deaths_by",
+			"p.rage_quits", "p.notes", "p.created_on", "p.updated_on", "p.profile_updated_on", "pn.name").
 		From("player p").
 		LeftJoin("player_names pn ON p.steam_id = pn.steam_id").
 		Where(sq.Eq{"p.steam_id": steamID}).
@@ -290,9 +303,9 @@ func (store *SqliteStore) LoadOrCreatePlayer(ctx context.Context, steamID steami
 	rowErr := store.db.
 		QueryRowContext(ctx, query, args...).
 		Scan(&player.Visibility, &player.RealName, &player.AccountCreatedOn, &player.AvatarHash,
-			&player.CommunityBanned, &player.NumberOfGameBans, &player.NumberOfVACBans,
-			&player.LastVACBanOn, &player.KillsOn, &player.DeathsBy, &player.RageQuits, &player.Notes,
-			&player.Whitelisted, &player.CreatedOn, &player.UpdatedOn, &player.ProfileUpdatedOn, &prevName,
+			&player.AvatarPerceptualHash, &player.CommunityBanned, &player.NumberOfGameBans, &player.NumberOfVACBans,
+			&player.LastVACBanOn, &player.LastGameBanOn, &player.KillsOn, &player.DeathsBy, &player.RageQuits, &player.Notes,
+			&player.CreatedOn, &player.UpdatedOn, &player.ProfileUpdatedOn, &prevName,
 		)
 	player.SteamId = steamID
 	if rowErr != nil {
@@ -364,3 +377,322 @@ func (store *SqliteStore) FetchMessages(ctx context.Context, steamID steamid.SID
 	}
 	return messages, nil
 }
+
+// FetchWhitelisted returns the players whitelisted using the legacy whitelist column. The whitelist is now
+// stored as a list in the rules engine, so this is only used to migrate the existing entries.
+func (store *SqliteStore) FetchWhitelisted(ctx context.Context) (steamid.Collection, error) {
+	query, args, errSql := sq.
+		Select("steam_id").
+		From("player").
+		Where(sq.Eq{"whitelist": true}).
+		ToSql()
+	if errSql != nil {
+		return nil, errSql
+	}
+	rows, errQuery := store.db.QueryContext(ctx, query, args...)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	defer util.LogClose(store.logger, rows)
+	var whitelisted steamid.Collection
+	for rows.Next() {
+		var sid64 steamid.SID64
+		if errScan := rows.Scan(&sid64); errScan != nil {
+			return nil, errScan
+		}
+		whitelisted = append(whitelisted, sid64)
+	}
+	return whitelisted, nil
+}
+
+// ClearWhitelisted resets the legacy whitelist column once the entries have been migrated
+func (store *SqliteStore) ClearWhitelisted(ctx context.Context) error {
+	query, args, errSql := sq.
+		Update("player").
+		Set("whitelist", false).
+		Where(sq.Eq{"whitelist": true}).
+		ToSql()
+	if errSql != nil {
+		return errSql
+	}
+	if _, errExec := store.db.ExecContext(ctx, query, args...); errExec != nil {
+		return errors.Wrap(errExec, "Failed to clear whitelist")
+	}
+	return nil
+}
+
+// SaveVote inserts the vote along with all the votes cast in it
+func (store *SqliteStore) SaveVote(ctx context.Context, vote *model.Vote) error {
+	if vote.CreatedOn.IsZero() {
+		vote.CreatedOn = time.Now()
+	}
+	tx, errTx := store.db.BeginTx(ctx, nil)
+	if errTx != nil {
+		return errors.Wrap(errTx, "Failed to start transaction")
+	}
+	query := sq.
+		Insert("vote").
+		Columns("server_name", "server_address", "caller_steam_id", "caller_name", "target_steam_id", "target_name",
+			"target_attributes", "yes_count", "no_count", "result", "created_on").
+		Values(vote.ServerName, vote.ServerAddress, vote.CallerSID.Int64(), vote.CallerName, vote.TargetSID.Int64(),
+			vote.TargetName, strings.Join(vote.TargetAttributes, ","), vote.Yes, vote.No, vote.Result, vote.CreatedOn).
+		Suffix("RETURNING \"vote_id\"").
+		RunWith(tx)
+	if errExec := query.QueryRowContext(ctx).Scan(&vote.VoteId); errExec != nil {
+		_ = tx.Rollback()
+		return errors.Wrap(errExec, "Failed to save vote")
+	}
+	for _, cast := range vote.Casts {
+		castQuery, args, errSql := sq.
+			Insert("vote_cast").
+			Columns("vote_id", "steam_id", "name", "vote_yes", "created_on").
+			Values(vote.VoteId, cast.SteamId.Int64(), cast.Name, cast.Yes, cast.CreatedOn).
+			ToSql()
+		if errSql != nil {
+			_ = tx.Rollback()
+			return errSql
+		}
+		if _, errExec := tx.ExecContext(ctx, castQuery, args...); errExec != nil {
+			_ = tx.Rollback()
+			return errors.Wrap(errExec, "Failed to save vote cast")
+		}
+	}
+	if errCommit := tx.Commit(); errCommit != nil {
+		return errors.Wrap(errCommit, "Failed to commit vote")
+	}
+	return nil
+}
+
+// FetchVotes returns the most recent votes, newest first, along with the votes cast in them
+func (store *SqliteStore) FetchVotes(ctx context.Context, limit uint64) ([]*model.Vote, error) {
+	query, args, errSql := sq.
+		Select("vote_id", "server_name", "server_address", "caller_steam_id", "caller_name", "target_steam_id",
+			"target_name", "target_attributes", "yes_count", "no_count", "result", "created_on").
+		From("vote").
+		OrderBy("created_on DESC", "vote_id DESC").
+		Limit(limit).
+		ToSql()
+	if errSql != nil {
+		return nil, errSql
+	}
+	rows, errQuery := store.db.QueryContext(ctx, query, args...)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	defer util.LogClose(store.logger, rows)
+	var (
+		votes   []*model.Vote
+		voteIds []int64
+		byId    = map[int64]*model.Vote{}
+	)
+	for rows.Next() {
+		var vote model.Vote
+		var attributes string
+		if errScan := rows.Scan(&vote.VoteId, &vote.ServerName, &vote.ServerAddress, &vote.CallerSID, &vote.CallerName,
+			&vote.TargetSID, &vote.TargetName, &attributes, &vote.Yes, &vote.No, &vote.Result, &vote.CreatedOn); errScan != nil {
+			return nil, errScan
+		}
+		if attributes != "" {
+			vote.TargetAttributes = strings.Split(attributes, ",")
+		}
+		votes = append(votes, &vote)
+		voteIds = append(voteIds, vote.VoteId)
+		byId[vote.VoteId] = &vote
+	}
+	if len(votes) == 0 {
+		return votes, nil
+	}
+	castQuery, castArgs, errCastSql := sq.
+		Select("vote_id", "steam_id", "name", "vote_yes", "created_on").
+		From("vote_cast").
+		Where(sq.Eq{"vote_id": voteIds}).
+		OrderBy("vote_cast_id").
+		ToSql()
+	if errCastSql != nil {
+		return nil, errCastSql
+	}
+	castRows, errCastQuery := store.db.QueryContext(ctx, castQuery, castArgs...)
+	if errCastQuery != nil {
+		return nil, errCastQuery
+	}
+	defer util.LogClose(store.logger, castRows)
+	for castRows.Next() {
+		var voteId int64
+		var cast model.VoteCast
+		if errScan := castRows.Scan(&voteId, &cast.SteamId, &cast.Name, &cast.Yes, &cast.CreatedOn); errScan != nil {
+			return nil, errScan
+		}
+		byId[voteId].Casts = append(byId[voteId].Casts, cast)
+	}
+	return votes, nil
+}
+
+// FetchVoteDefenders returns the players who have most often voted against kicking marked players
+func (store *SqliteStore) FetchVoteDefenders(ctx context.Context, limit int) ([]model.VoteDefender, error) {
+	query, args, errSql := sq.
+		Select("c.steam_id", "c.name", "c.created_on").
+		From("vote_cast c").
+		Join("vote v ON v.vote_id = c.vote_id").
+		Where(sq.And{sq.Eq{"c.vote_yes": false}, sq.NotEq{"v.target_attributes": ""}, sq.NotEq{"c.steam_id": 0}}).
+		OrderBy("c.vote_cast_id").
+		ToSql()
+	if errSql != nil {
+		return nil, errSql
+	}
+	rows, errQuery := store.db.QueryContext(ctx, query, args...)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	defer util.LogClose(store.logger, rows)
+	var defenders []model.VoteDefender
+	known := map[steamid.SID64]int{}
+	for rows.Next() {
+		var cast model.VoteCast
+		if errScan := rows.Scan(&cast.SteamId, &cast.Name, &cast.CreatedOn); errScan != nil {
+			return nil, errScan
+		}
+		idx, found := known[cast.SteamId]
+		if !found {
+			idx = len(defenders)
+			known[cast.SteamId] = idx
+			defenders = append(defenders, model.VoteDefender{SteamId: cast.SteamId})
+		}
+		// Casts are ordered oldest first, so the latest name and time are kept
+		defenders[idx].Name = cast.Name
+		defenders[idx].LastDefendedOn = cast.CreatedOn
+		defenders[idx].Count++
+	}
+	sort.SliceStable(defenders, func(i, j int) bool {
+		if defenders[i].Count != defenders[j].Count {
+			return defenders[i].Count > defenders[j].Count
+		}
+		return defenders[i].LastDefendedOn.After(defenders[j].LastDefendedOn)
+	})
+	if limit > 0 && len(defenders) > limit {
+		defenders = defenders[:limit]
+	}
+	return defenders, nil
+}
+
+func (store *SqliteStore) SaveRageQuit(ctx context.Context, rageQuit *model.RageQuit) error {
+	if rageQuit.CreatedOn.IsZero() {
+		rageQuit.CreatedOn = time.Now()
+	}
+	query := sq.
+		Insert("rage_quit").
+		Columns("steam_id", "name", "server_name", "map_name", "reason", "cause", "created_on").
+		Values(rageQuit.SteamId.Int64(), rageQuit.Name, rageQuit.ServerName, rageQuit.MapName, rageQuit.Reason,
+			rageQuit.Cause, rageQuit.CreatedOn).
+		Suffix("RETURNING \"rage_quit_id\"").
+		RunWith(store.db)
+	if errExec := query.QueryRowContext(ctx).Scan(&rageQuit.RageQuitId); errExec != nil {
+		return errors.Wrap(errExec, "Failed to save rage quit")
+	}
+	return nil
+}
+
+// FetchRageQuits returns the rage quits of the player, oldest first
+func (store *SqliteStore) FetchRageQuits(ctx context.Context, steamID steamid.SID64) (model.RageQuitCollection, error) {
+	query, args, errSql := sq.
+		Select("rage_quit_id", "steam_id", "name", "server_name", "map_name", "reason", "cause", "created_on").
+		From("rage_quit").
+		Where(sq.Eq{"steam_id": steamID.Int64()}).
+		OrderBy("created_on", "rage_quit_id").
+		ToSql()
+	if errSql != nil {
+		return nil, errSql
+	}
+	rows, errQuery := store.db.QueryContext(ctx, query, args...)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	defer util.LogClose(store.logger, rows)
+	var rageQuits model.RageQuitCollection
+	for rows.Next() {
+		var rageQuit model.RageQuit
+		if errScan := rows.Scan(&rageQuit.RageQuitId, &rageQuit.SteamId, &rageQuit.Name, &rageQuit.ServerName,
+			&rageQuit.MapName, &rageQuit.Reason, &rageQuit.Cause, &rageQuit.CreatedOn); errScan != nil {
+			return nil, errScan
+		}
+		rageQuits = append(rageQuits, rageQuit)
+	}
+	return rageQuits, nil
+}
+
+func (store *SqliteStore) SaveKill(ctx context.Context, kill *model.Kill) error {
+	if kill.CreatedOn.IsZero() {
+		kill.CreatedOn = time.Now()
+	}
+	query := sq.
+		Insert("player_kill").
+		Columns("server_name", "map_name", "killer_steam_id", "killer_name", "assister_steam_id", "assister_name",
+			"victim_steam_id", "victim_name", "weapon", "crit", "suicide", "created_on").
+		Values(kill.ServerName, kill.MapName, kill.KillerSID.Int64(), kill.KillerName, kill.AssisterSID.Int64(),
+			kill.AssisterName, kill.VictimSID.Int64(), kill.VictimName, kill.Weapon, kill.Crit, kill.Suicide, kill.CreatedOn).
+		Suffix("RETURNING \"kill_id\"").
+		RunWith(store.db)
+	if errExec := query.QueryRowContext(ctx).Scan(&kill.KillId); errExec != nil {
+		return errors.Wrap(errExec, "Failed to save kill")
+	}
+	return nil
+}
+
+// FetchKills returns the most recent kills, assists and deaths of the player, newest first
+func (store *SqliteStore) FetchKills(ctx context.Context, steamID steamid.SID64, limit uint64) ([]model.Kill, error) {
+	query, args, errSql := sq.
+		Select("kill_id", "server_name", "map_name", "killer_steam_id", "killer_name", "assister_steam_id",
+			"assister_name", "victim_steam_id", "victim_name", "weapon", "crit", "suicide", "created_on").
+		From("player_kill").
+		Where(sq.Or{sq.Eq{"killer_steam_id": steamID.Int64()}, sq.Eq{"assister_steam_id": steamID.Int64()},
+			sq.Eq{"victim_steam_id": steamID.Int64()}}).
+		OrderBy("created_on DESC", "kill_id DESC").
+		Limit(limit).
+		ToSql()
+	if errSql != nil {
+		return nil, errSql
+	}
+	rows, errQuery := store.db.QueryContext(ctx, query, args...)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	defer util.LogClose(store.logger, rows)
+	var kills []model.Kill
+	for rows.Next() {
+		var kill model.Kill
+		if errScan := rows.Scan(&kill.KillId, &kill.ServerName, &kill.MapName, &kill.KillerSID, &kill.KillerName,
+			&kill.AssisterSID, &kill.AssisterName, &kill.VictimSID, &kill.VictimName, &kill.Weapon, &kill.Crit,
+			&kill.Suicide, &kill.CreatedOn); errScan != nil {
+			return nil, errScan
+		}
+		kills = append(kills, kill)
+	}
+	return kills, nil
+}
+
+// FetchWeaponStats returns the kills and crits of the player for each weapon they have killed with, most used first
+func (store *SqliteStore) FetchWeaponStats(ctx context.Context, steamID steamid.SID64) (model.WeaponStatsCollection, error) {
+	query, args, errSql := sq.
+		Select("weapon", "COUNT(*)", "SUM(crit)").
+		From("player_kill").
+		Where(sq.And{sq.Eq{"killer_steam_id": steamID.Int64()}, sq.Eq{"suicide": false}}).
+		GroupBy("weapon").
+		OrderBy("COUNT(*) DESC", "weapon").
+		ToSql()
+	if errSql != nil {
+		return nil, errSql
+	}
+	rows, errQuery := store.db.QueryContext(ctx, query, args...)
+	if errQuery != nil {
+		return nil, errQuery
+	}
+	defer util.LogClose(store.logger, rows)
+	var stats model.WeaponStatsCollection
+	for rows.Next() {
+		var weaponStats model.WeaponStats
+		if errScan := rows.Scan(&weaponStats.Weapon, &weaponStats.Kills, &weaponStats.Crits); errScan != nil {
+			return nil, errScan
+		}
+		stats = append(stats, weaponStats)
+	}
+	return stats, nil
+}
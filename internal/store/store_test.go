@@ -28,6 +28,10 @@ func TestStore(t *testing.T) {
 		}
 	}(impl)
 	testStoreImpl(t, impl)
+	testWhitelistMigration(t, impl)
+	testVotes(t, impl)
+	testRageQuits(t, impl)
+	testKills(t, impl)
 }
 
 func testStoreImpl(t *testing.T, ds DataStore) {
@@ -54,4 +58,174 @@ func testStoreImpl(t *testing.T, ds DataStore) {
 	messages, errMessages := ds.FetchMessages(ctx, player1.SteamId)
 	require.NoError(t, errMessages)
 	require.Equal(t, 2, len(messages))
+
+	player2.AvatarHash = "fef49e7fa7e1997310d705b2a6158ff8dc1cdfeb"
+	player2.AvatarPerceptualHash = "4aa54aa54aa54aa5"
+	lastGameBanOn := time.Date(2023, 2, 24, 0, 0, 0, 0, time.UTC)
+	player2.NumberOfGameBans = 1
+	player2.LastGameBanOn = &lastGameBanOn
+	require.NoError(t, ds.SavePlayer(ctx, &player2))
+	var player3 model.Player
+	require.NoError(t, ds.GetPlayer(ctx, player1.SteamId, &player3))
+	require.Equal(t, player2.AvatarHash, player3.AvatarHash)
+	require.Equal(t, player2.AvatarPerceptualHash, player3.AvatarPerceptualHash)
+	require.Nil(t, player3.LastVACBanOn)
+	require.NotNil(t, player3.LastGameBanOn)
+	require.True(t, lastGameBanOn.Equal(*player3.LastGameBanOn))
+	require.Equal(t, player3.LastGameBanOn, player3.LastBanOn())
+}
+
+func testWhitelistMigration(t *testing.T, store *SqliteStore) {
+	ctx := context.Background()
+	player := model.NewPlayer(steamid.SID64(76561197961279984), golib.RandomString(10))
+	require.NoError(t, store.LoadOrCreatePlayer(ctx, player.SteamId, player))
+	whitelisted, errFetch := store.FetchWhitelisted(ctx)
+	require.NoError(t, errFetch)
+	require.Empty(t, whitelisted)
+
+	_, errExec := store.db.ExecContext(ctx, "UPDATE player SET whitelist = true WHERE steam_id = ?", player.SteamId.Int64())
+	require.NoError(t, errExec)
+	whitelisted, errFetch = store.FetchWhitelisted(ctx)
+	require.NoError(t, errFetch)
+	require.Equal(t, steamid.Collection{player.SteamId}, whitelisted)
+
+	require.NoError(t, store.ClearWhitelisted(ctx))
+	whitelisted, errFetch = store.FetchWhitelisted(ctx)
+	require.NoError(t, errFetch)
+	require.Empty(t, whitelisted)
+}
+
+func testVotes(t *testing.T, ds DataStore) {
+	ctx := context.Background()
+	defender := steamid.SID64(76561197961279985)
+	t0 := time.Now()
+	marked := model.Vote{
+		CallerSID:        steamid.SID64(76561197961279983),
+		CallerName:       "caller",
+		TargetSID:        steamid.SID64(76561197961279986),
+		TargetName:       "cheater",
+		TargetAttributes: []string{"cheater", "bot"},
+		Yes:              1,
+		No:               1,
+		Result:           model.VoteResultFailed,
+		Casts: []model.VoteCast{
+			{SteamId: steamid.SID64(76561197961279983), Name: "caller", Yes: true, CreatedOn: t0},
+			{SteamId: defender, Name: "defender", Yes: false, CreatedOn: t0},
+		},
+		CreatedOn: t0,
+	}
+	require.NoError(t, ds.SaveVote(ctx, &marked))
+	require.True(t, marked.VoteId > 0)
+	unmarked := model.Vote{
+		CallerName: "caller",
+		TargetName: "innocent",
+		Result:     model.VoteResultPassed,
+		Casts:      []model.VoteCast{{SteamId: steamid.SID64(76561197961279987), Name: "other", Yes: false, CreatedOn: t0}},
+		CreatedOn:  t0.Add(time.Second),
+	}
+	require.NoError(t, ds.SaveVote(ctx, &unmarked))
+
+	votes, errVotes := ds.FetchVotes(ctx, 10)
+	require.NoError(t, errVotes)
+	require.Equal(t, 2, len(votes))
+	require.Equal(t, unmarked.VoteId, votes[0].VoteId)
+	require.Equal(t, marked.TargetAttributes, votes[1].TargetAttributes)
+	require.Equal(t, model.VoteResultFailed, votes[1].Result)
+	require.Equal(t, 2, len(votes[1].Casts))
+	require.Equal(t, defender, votes[1].Defenders()[0].SteamId)
+
+	defenders, errDefenders := ds.FetchVoteDefenders(ctx, 10)
+	require.NoError(t, errDefenders)
+	require.Equal(t, 1, len(defenders))
+	require.Equal(t, defender, defenders[0].SteamId)
+	require.Equal(t, "defender", defenders[0].Name)
+	require.Equal(t, 1, defenders[0].Count)
+}
+
+func testRageQuits(t *testing.T, ds DataStore) {
+	ctx := context.Background()
+	player := model.NewPlayer(steamid.SID64(76561197961279988), golib.RandomString(10))
+	require.NoError(t, ds.LoadOrCreatePlayer(ctx, player.SteamId, player))
+	t0 := time.Now()
+	first := model.RageQuit{
+		SteamId:    player.SteamId,
+		Name:       player.Name,
+		ServerName: "Uncletopia | Seattle | 1 | All Maps",
+		MapName:    "pl_swiftwater_final1",
+		Reason:     "Disconnect by user.",
+		Cause:      model.RageQuitCauseDeath,
+		CreatedOn:  t0,
+	}
+	require.NoError(t, ds.SaveRageQuit(ctx, &first))
+	require.True(t, first.RageQuitId > 0)
+	second := first
+	second.Cause = model.RageQuitCauseKickVote
+	second.CreatedOn = t0.Add(time.Minute)
+	require.NoError(t, ds.SaveRageQuit(ctx, &second))
+	require.NoError(t, ds.SaveRageQuit(ctx, &model.RageQuit{SteamId: steamid.SID64(76561197961279989)}))
+
+	player.RageQuits = 2
+	require.NoError(t, ds.SavePlayer(ctx, player))
+	var loaded model.Player
+	require.NoError(t, ds.GetPlayer(ctx, player.SteamId, &loaded))
+	require.Equal(t, 2, loaded.RageQuits)
+
+	rageQuits, errFetch := ds.FetchRageQuits(ctx, player.SteamId)
+	require.NoError(t, errFetch)
+	require.Equal(t, 2, len(rageQuits))
+	require.Equal(t, first.RageQuitId, rageQuits[0].RageQuitId)
+	require.Equal(t, first.MapName, rageQuits[0].MapName)
+	require.Equal(t, first.ServerName, rageQuits[0].ServerName)
+	require.Equal(t, model.RageQuitCauseKickVote, rageQuits[1].Cause)
+}
+
+func testKills(t *testing.T, ds DataStore) {
+	ctx := context.Background()
+	sniper := steamid.SID64(76561197961279990)
+	victim := steamid.SID64(76561197961279991)
+	t0 := time.Now()
+	for i := 0; i < model.AnomalyMinKills; i++ {
+		kill := model.Kill{
+			KillerSID:  sniper,
+			KillerName: "sniper",
+			VictimSID:  victim,
+			VictimName: "victim",
+			Weapon:     "sniperrifle",
+			Crit:       true,
+			CreatedOn:  t0.Add(time.Duration(i) * time.Second),
+		}
+		require.NoError(t, ds.SaveKill(ctx, &kill))
+		require.True(t, kill.KillId > 0)
+	}
+	medic := steamid.SID64(76561197961279992)
+	require.NoError(t, ds.SaveKill(ctx, &model.Kill{KillerSID: sniper, AssisterSID: medic, AssisterName: "medic",
+		VictimSID: victim, Weapon: "smg", CreatedOn: t0}))
+	require.NoError(t, ds.SaveKill(ctx, &model.Kill{KillerSID: sniper, VictimSID: sniper, Weapon: model.WeaponWorld,
+		Suicide: true, CreatedOn: t0.Add(time.Minute)}))
+
+	stats, errStats := ds.FetchWeaponStats(ctx, sniper)
+	require.NoError(t, errStats)
+	require.Equal(t, model.WeaponStatsCollection{
+		{Weapon: "sniperrifle", Kills: model.AnomalyMinKills, Crits: model.AnomalyMinKills},
+		{Weapon: "smg", Kills: 1, Crits: 0},
+	}, stats)
+	require.Equal(t, stats[:1], stats.Anomalies())
+
+	kills, errKills := ds.FetchKills(ctx, victim, 5)
+	require.NoError(t, errKills)
+	require.Equal(t, 5, len(kills))
+	require.Equal(t, "sniperrifle", kills[0].Weapon)
+	require.True(t, kills[0].Crit)
+	suicides, errSuicides := ds.FetchKills(ctx, sniper, 1)
+	require.NoError(t, errSuicides)
+	require.True(t, suicides[0].Suicide)
+	assists, errAssists := ds.FetchKills(ctx, medic, 5)
+	require.NoError(t, errAssists)
+	require.Equal(t, 1, len(assists))
+	require.Equal(t, sniper, assists[0].KillerSID)
+	require.Equal(t, "medic", assists[0].AssisterName)
+	// Assists are not counted in the weapon stats of the assister
+	assisterStats, errAssisterStats := ds.FetchWeaponStats(ctx, medic)
+	require.NoError(t, errAssisterStats)
+	require.Empty(t, assisterStats)
 }
@@ -0,0 +1,181 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/widget"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/internal/tr"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// killHistoryLimit is the number of recent kills, assists and deaths shown
+const killHistoryLimit = 100
+
+// userKillStatsWindow shows the weapon stats of a player, flagging suspicious headshot rates, along with
+// their recent kills, assists and deaths
+type userKillStatsWindow struct {
+	fyne.Window
+
+	weaponList      *widget.List
+	boundWeapons    binding.ExternalUntypedList
+	killList        *widget.List
+	boundKills      binding.ExternalUntypedList
+	objectMu        sync.RWMutex
+	anomalyCount    binding.Int
+	weaponStatsFunc model.QueryWeaponStatsFunc
+	killsFunc       model.QueryKillsFunc
+	sid64           steamid.SID64
+	logger          *zap.Logger
+	labelSuspicious string
+	labelCritSuffix string
+}
+
+// Reload fetches the stats and kills of the player again, as new kills may have been recorded since the window
+// was opened
+func (window *userKillStatsWindow) Reload(ctx context.Context) {
+	stats, errStats := window.weaponStatsFunc(ctx, window.sid64)
+	if errStats != nil {
+		window.logger.Error("Failed to fetch weapon stats", zap.Error(errStats))
+	}
+	boundStats := make([]interface{}, len(stats))
+	for i, weaponStats := range stats {
+		boundStats[i] = weaponStats
+	}
+	if errSet := window.boundWeapons.Set(boundStats); errSet != nil {
+		window.logger.Error("Failed to set weapon stats list", zap.Error(errSet))
+	}
+	if errSetCount := window.anomalyCount.Set(len(stats.Anomalies())); errSetCount != nil {
+		window.logger.Error("Failed to set anomaly count", zap.Error(errSetCount))
+	}
+	kills, errKills := window.killsFunc(ctx, window.sid64, killHistoryLimit)
+	if errKills != nil {
+		window.logger.Error("Failed to fetch kills", zap.Error(errKills))
+	}
+	boundKills := make([]interface{}, len(kills))
+	for i, kill := range kills {
+		boundKills[i] = kill
+	}
+	if errSet := window.boundKills.Set(boundKills); errSet != nil {
+		window.logger.Error("Failed to set kill list", zap.Error(errSet))
+	}
+}
+
+// killDetails describes the kill feed entry
+func (window *userKillStatsWindow) killDetails(kill model.Kill) string {
+	killer := kill.KillerName
+	if kill.AssisterName != "" {
+		killer = fmt.Sprintf("%s + %s", killer, kill.AssisterName)
+	}
+	details := fmt.Sprintf("%s > %s (%s)", killer, kill.VictimName, kill.Weapon)
+	if kill.Crit {
+		details += window.labelCritSuffix
+	}
+	return details
+}
+
+func newUserKillStatsWindow(ctx context.Context, logger *zap.Logger, app fyne.App, weaponStatsFunc model.QueryWeaponStatsFunc,
+	killsFunc model.QueryKillsFunc, sid64 steamid.SID64) *userKillStatsWindow {
+	title := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "kill_stats_title", Other: "Kill Stats: {{ .SteamID }}"},
+		TemplateData: map[string]interface{}{
+			"SteamID": sid64,
+		}})
+	appWindow := app.NewWindow(title)
+	appWindow.SetCloseIntercept(func() {
+		appWindow.Hide()
+	})
+	ksw := &userKillStatsWindow{
+		Window:          appWindow,
+		logger:          logger,
+		boundWeapons:    binding.BindUntypedList(&[]interface{}{}),
+		boundKills:      binding.BindUntypedList(&[]interface{}{}),
+		anomalyCount:    binding.NewInt(),
+		weaponStatsFunc: weaponStatsFunc,
+		killsFunc:       killsFunc,
+		sid64:           sid64,
+		labelSuspicious: tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "kill_stats_label_suspicious", Other: "Suspicious Headshot Rate"}}),
+		labelCritSuffix: tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "kill_stats_label_crit", Other: " (crit)"}}),
+	}
+	ksw.weaponList = widget.NewListWithData(
+		ksw.boundWeapons,
+		func() fyne.CanvasObject {
+			return container.NewBorder(
+				nil,
+				nil,
+				widget.NewLabel(""),
+				widget.NewLabel(""),
+				widget.NewLabel(""))
+		},
+		func(i binding.DataItem, o fyne.CanvasObject) {
+			value := i.(binding.Untyped)
+			obj, _ := value.Get()
+			weaponStats := obj.(model.WeaponStats)
+			ksw.objectMu.Lock()
+			rootContainer := o.(*fyne.Container)
+			weapon := rootContainer.Objects[1].(*widget.Label)
+			weapon.SetText(weaponStats.Weapon)
+			anomaly := rootContainer.Objects[2].(*widget.Label)
+			if weaponStats.Anomalous() {
+				anomaly.SetText(ksw.labelSuspicious)
+			} else {
+				anomaly.SetText("")
+			}
+			details := rootContainer.Objects[0].(*widget.Label)
+			details.SetText(tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{ID: "kill_stats_label_weapon", Other: "Kills: {{ .Kills }} Crits: {{ .Crits }} ({{ .Rate }}%)"},
+				TemplateData: map[string]interface{}{
+					"Kills": weaponStats.Kills,
+					"Crits": weaponStats.Crits,
+					"Rate":  fmt.Sprintf("%.0f", weaponStats.CritRate()*100),
+				}}))
+			ksw.objectMu.Unlock()
+		})
+	ksw.killList = widget.NewListWithData(
+		ksw.boundKills,
+		func() fyne.CanvasObject {
+			return container.NewBorder(
+				nil,
+				nil,
+				widget.NewLabel(""),
+				nil,
+				widget.NewLabel(""))
+		},
+		func(i binding.DataItem, o fyne.CanvasObject) {
+			value := i.(binding.Untyped)
+			obj, _ := value.Get()
+			kill := obj.(model.Kill)
+			ksw.objectMu.Lock()
+			rootContainer := o.(*fyne.Container)
+			timeStamp := rootContainer.Objects[1].(*widget.Label)
+			timeStamp.SetText(kill.CreatedOn.Format(time.RFC822))
+			details := rootContainer.Objects[0].(*widget.Label)
+			details.SetText(ksw.killDetails(kill))
+			ksw.objectMu.Unlock()
+		})
+	ksw.Reload(ctx)
+
+	labelAnomalies := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "kill_stats_label_anomalies", Other: "Suspicious Weapons: "}})
+	labelWeapons := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "kill_stats_tab_weapons", Other: "Weapons"}})
+	labelKills := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "kill_stats_tab_kills", Other: "Recent Kills"}})
+	ksw.SetContent(container.NewBorder(
+		container.NewHBox(widget.NewLabelWithData(binding.IntToStringWithFormat(ksw.anomalyCount, fmt.Sprintf("%s%%d", labelAnomalies)))),
+		nil,
+		nil,
+		nil,
+		container.NewAppTabs(
+			container.NewTabItem(labelWeapons, container.NewVScroll(ksw.weaponList)),
+			container.NewTabItem(labelKills, container.NewVScroll(ksw.killList)))))
+	ksw.Resize(fyne.NewSize(sizeDialogueWidth, sizeDialogueHeight))
+	ksw.Show()
+	return ksw
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/leighmacdonald/bd/internal/tr"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"go.uber.org/zap"
+	"time"
 )
 
 type ruleListConfigDialog struct {
@@ -22,7 +23,60 @@ type ruleListConfigDialog struct {
 	settings  *model.Settings
 }
 
-func newRuleListConfigDialog(parent fyne.Window, logger *zap.Logger, settings *model.Settings) dialog.Dialog {
+// listStatusFunc returns the update status of a list by its url
+type listStatusFunc func(url string) (model.ListStatus, bool)
+
+// formatListStatus creates a short human-readable summary of the most recent list update
+func formatListStatus(status model.ListStatus, found bool) string {
+	if !found {
+		return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "lists_status_pending", Other: "Not updated yet"}})
+	}
+	lastSuccess := "-"
+	if !status.LastSuccess.IsZero() {
+		lastSuccess = status.LastSuccess.Format(time.Kitchen)
+	}
+	if status.LastError != nil {
+		return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "lists_status_error", Other: "Error: {{ .Error }} (Last Success: {{ .LastSuccess }})"},
+			TemplateData:   map[string]interface{}{"Error": status.LastError.Error(), "LastSuccess": lastSuccess}})
+	}
+	msg := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "lists_status_ok", Other: "Entries: {{ .Count }}, Updated: {{ .LastSuccess }} ({{ .Duration }})"},
+		TemplateData: map[string]interface{}{
+			"Count":       status.Count,
+			"LastSuccess": lastSuccess,
+			"Duration":    status.Duration.Round(time.Millisecond).String(),
+		}})
+	if status.Dropped > 0 {
+		msg += ", " + tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "lists_status_dropped", Other: "Invalid Entries Skipped: {{ .Dropped }}"},
+			TemplateData:   map[string]interface{}{"Dropped": status.Dropped}})
+	}
+	return msg
+}
+
+// newListTypeSelect creates a select widget which updates the type of the list config
+func newListTypeSelect(lc *model.ListConfig) *widget.Select {
+	listTypes := []model.ListType{model.ListTypeTF2BDPlayerList, model.ListTypeTF2BDRules, model.ListTypeTF2BDWhitelist}
+	labels := []string{
+		tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_type_players", Other: "Player List"}}),
+		tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_type_rules", Other: "Rules List"}}),
+		tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_type_whitelist", Other: "Whitelist"}}),
+	}
+	typeSelect := widget.NewSelect(labels, nil)
+	for idx, listType := range listTypes {
+		if listType == lc.ListType {
+			typeSelect.SetSelectedIndex(idx)
+		}
+	}
+	typeSelect.OnChanged = func(_ string) {
+		lc.ListType = listTypes[typeSelect.SelectedIndex()]
+	}
+	return typeSelect
+}
+
+func newRuleListConfigDialog(parent fyne.Window, logger *zap.Logger, settings *model.Settings, listStatus listStatusFunc) dialog.Dialog {
 	buttonEdit := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_button_edit", Other: "Edit"}})
 	buttonDelete := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_button_delete", Other: "Delete"}})
 	boundList := binding.BindUntypedList(&[]interface{}{})
@@ -35,7 +89,7 @@ func newRuleListConfigDialog(parent fyne.Window, logger *zap.Logger, settings *m
 				widget.NewButtonWithIcon(buttonEdit, theme.DocumentCreateIcon(), func() {}),
 				widget.NewButtonWithIcon(buttonDelete, theme.DeleteIcon(), func() {}),
 			),
-			widget.NewLabel(""),
+			container.NewVBox(widget.NewLabel(""), widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})),
 		)
 	}, func(i binding.DataItem, o fyne.CanvasObject) {
 		value := i.(binding.Untyped)
@@ -43,8 +97,11 @@ func newRuleListConfigDialog(parent fyne.Window, logger *zap.Logger, settings *m
 		lc := obj.(*model.ListConfig)
 		rootContainer := o.(*fyne.Container)
 
-		name := rootContainer.Objects[0].(*widget.Label)
+		labelContainer := rootContainer.Objects[0].(*fyne.Container)
+		name := labelContainer.Objects[0].(*widget.Label)
 		name.Bind(binding.BindString(&lc.Name))
+		status := labelContainer.Objects[1].(*widget.Label)
+		status.SetText(formatListStatus(listStatus(lc.URL)))
 
 		urlEntry := widget.NewEntryWithData(binding.BindString(&lc.URL))
 		urlEntry.Validator = validateUrl
@@ -60,13 +117,20 @@ func newRuleListConfigDialog(parent fyne.Window, logger *zap.Logger, settings *m
 			//enabledEntry := widget.NewCheckWithData(tr.One(tr.LabelEnabled), binding.BindBool(&lc.Enabled))
 			labelName := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_label_name", Other: "Name"}})
 			labelURL := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_label_url", Other: "URL"}})
+			labelType := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_label_type", Other: "Type"}})
 			labelEnabled := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_label_enabled", Other: "Enabled"}})
+			labelStrict := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_label_strict", Other: "Strict"}})
+			labelStrictHint := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{ID: "lists_label_strict_hint", Other: "Reject the entire list instead of skipping invalid entries"}})
+			strictCheck := widget.NewCheckWithData("", binding.BindBool(&lc.Strict))
 			titleEdit := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_title_edit", Other: "Edit"}})
 			buttonClose := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "lists_button_close", Other: "Close"}})
 			form := widget.NewForm([]*widget.FormItem{
 				{Text: labelName, Widget: nameEntry},
 				{Text: labelURL, Widget: urlEntry},
+				{Text: labelType, Widget: newListTypeSelect(lc)},
 				{Text: labelEnabled, Widget: enabledCheck},
+				{Text: labelStrict, Widget: strictCheck, HintText: labelStrictHint},
 			}...)
 
 			d := dialog.NewCustom(
@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/internal/tr"
+	"github.com/leighmacdonald/bd/pkg/rules"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"net/url"
+	"strings"
+)
+
+// formatMatched describes the part of the input which matched, including its position for text matches. Normalised
+// matches are positioned within the normalised text.
+func formatMatched(match *rules.MatchResult) string {
+	if match.Matched == "" || !match.IsTextMatch() {
+		return match.Matched
+	}
+	if match.Normalised != "" {
+		return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "match_details_matched_offset_normalised", Other: "\"{{ .Matched }}\" at position {{ .Offset }} of the normalised text"},
+			TemplateData:   map[string]any{"Matched": match.Matched, "Offset": match.Offset}})
+	}
+	return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "match_details_matched_offset", Other: "\"{{ .Matched }}\" at position {{ .Offset }}"},
+		TemplateData:   map[string]any{"Matched": match.Matched, "Offset": match.Offset}})
+}
+
+// newMatchDetails creates the form showing the evidence of a single match
+func newMatchDetails(match *rules.MatchResult) fyne.CanvasObject {
+	typeLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "match_details_label_type", Other: "Type"}})
+	attributesLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "match_details_label_attributes", Other: "Attributes"}})
+	patternLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "match_details_label_pattern", Other: "Pattern"}})
+	matchedLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "match_details_label_matched", Other: "Matched"}})
+	normalisedLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "match_details_label_normalised", Other: "Normalised"}})
+	listLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "match_details_label_list", Other: "List URL"}})
+
+	form := container.New(layout.NewFormLayout())
+	addRow := func(label string, value fyne.CanvasObject) {
+		form.Add(widget.NewLabelWithStyle(label, fyne.TextAlignTrailing, fyne.TextStyle{Bold: true}))
+		form.Add(value)
+	}
+	addText := func(label string, value string) {
+		if value == "" {
+			return
+		}
+		valueLabel := widget.NewLabel(value)
+		valueLabel.Wrapping = fyne.TextWrapWord
+		addRow(label, valueLabel)
+	}
+	addText(typeLabel, match.MatcherType)
+	addText(attributesLabel, strings.Join(match.Attributes, ", "))
+	triggers := match.Triggers
+	if len(triggers) == 0 {
+		triggers = rules.MatchResults{match}
+	}
+	for _, trigger := range triggers {
+		addText(patternLabel, fmt.Sprintf("[%s] %s", trigger.MatcherType, trigger.Pattern))
+		addText(matchedLabel, formatMatched(trigger))
+		addText(normalisedLabel, trigger.Normalised)
+	}
+	if match.UpdateURL != "" {
+		if updateURL, errURL := url.Parse(match.UpdateURL); errURL == nil {
+			addRow(listLabel, widget.NewHyperlink(match.UpdateURL, updateURL))
+		}
+	}
+	title := match.Origin
+	if match.Rule != "" {
+		title = fmt.Sprintf("%s: %s", match.Origin, match.Rule)
+	}
+	return widget.NewCard("", title, form)
+}
+
+// showMatchDetails shows the evidence for all the current matches of the player so that it can be reviewed
+// before taking action against them
+func showMatchDetails(parent fyne.Window, player *model.Player) {
+	player.RLock()
+	name := player.Name
+	matches := player.Matches
+	whitelist := player.WhitelistMatch
+	player.RUnlock()
+
+	title := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "match_details_title", Other: "Match Details: {{ .Name }}"},
+		TemplateData:   map[string]any{"Name": name}})
+	closeLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "match_details_button_close", Other: "Close"}})
+	content := container.NewVBox()
+	if whitelist != nil {
+		// Whitelisting overrides every match, so show where it came from first
+		content.Add(newMatchDetails(whitelist))
+	}
+	if len(matches) == 0 {
+		content.Add(widget.NewLabel(tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "match_details_none", Other: "The player has not matched any lists or rules"}})))
+	}
+	for _, match := range matches {
+		content.Add(newMatchDetails(match))
+	}
+	d := dialog.NewCustom(title, closeLabel, container.NewVScroll(content), parent)
+	d.Resize(fyne.NewSize(sizeWindowMainWidth, sizeDialogueHeight))
+	d.Show()
+}
@@ -17,6 +17,7 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 )
 
 type menuButton struct {
@@ -38,13 +39,40 @@ func newMenuButton(menu *fyne.Menu) *menuButton {
 
 const newItemLabel = "New..."
 
+type markDurationOption struct {
+	duration model.MarkDuration
+	label    string
+}
+
+// markDurationOptions returns the choices of how long a mark lasts, the first option is the default
+func markDurationOptions() []markDurationOption {
+	return []markDurationOption{
+		{duration: model.MarkPermanent, label: tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "mark_duration_permanent", Other: "Permanently"}})},
+		{duration: model.MarkTransient, label: tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "mark_duration_transient", Other: "Until Leaving Server"}})},
+		{duration: model.MarkDuration(time.Hour * 24), label: tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "mark_duration_day", Other: "For 1 Day"}})},
+		{duration: model.MarkDuration(time.Hour * 24 * 7), label: tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "mark_duration_week", Other: "For 7 Days"}})},
+		{duration: model.MarkDuration(time.Hour * 24 * 30), label: tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "mark_duration_month", Other: "For 30 Days"}})},
+	}
+}
+
 func generateAttributeMenu(window fyne.Window, sid64 steamid.SID64, attrList binding.StringList, markFunc model.MarkFunc) *fyne.Menu {
-	mkAttr := func(attrName string) func() {
-		clsAttribute := attrName
-		clsSteamId := sid64
-		return func() {
-			showUserError(markFunc(clsSteamId, []string{clsAttribute}), window)
+	durations := markDurationOptions()
+	mkAttr := func(attrName string) *fyne.Menu {
+		durationMenu := fyne.NewMenu(attrName)
+		for _, option := range durations {
+			clsAttribute := attrName
+			clsSteamId := sid64
+			clsDuration := option.duration
+			durationMenu.Items = append(durationMenu.Items, fyne.NewMenuItem(option.label, func() {
+				showUserError(markFunc(clsSteamId, []string{clsAttribute}, clsDuration), window)
+			}))
 		}
+		return durationMenu
 	}
 	markAsMenuLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
 		DefaultMessage: &i18n.Message{ID: "menu_markas_label", Other: "Mark As..."}})
@@ -54,7 +82,7 @@ func generateAttributeMenu(window fyne.Window, sid64 steamid.SID64, attrList bin
 		return strings.ToLower(knownAttributes[i]) < strings.ToLower(knownAttributes[j])
 	})
 	for _, mi := range knownAttributes {
-		markAsMenu.Items = append(markAsMenu.Items, fyne.NewMenuItem(mi, mkAttr(mi)))
+		markAsMenu.Items = append(markAsMenu.Items, &fyne.MenuItem{Label: mi, ChildMenu: mkAttr(mi)})
 	}
 	entry := widget.NewEntry()
 	entry.Validator = func(s string) error {
@@ -75,16 +103,28 @@ func generateAttributeMenu(window fyne.Window, sid64 steamid.SID64, attrList bin
 	}
 	attributeLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "mark_label_attr", Other: "Attribute Name"}})
 	fi := widget.NewFormItem(attributeLabel, entry)
+	var durationLabels []string
+	for _, option := range durations {
+		durationLabels = append(durationLabels, option.label)
+	}
+	durationSelect := widget.NewSelect(durationLabels, nil)
+	durationSelect.SetSelectedIndex(0)
+	durationLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "mark_label_duration", Other: "Duration"}})
+	durationItem := widget.NewFormItem(durationLabel, durationSelect)
 	markAsMenu.Items = append(markAsMenu.Items, fyne.NewMenuItem(newItemLabel, func() {
 		title := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "mark_title", Other: "Add custom mark attribute"}})
 		save := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "mark_button_save", Other: "Save"}})
 		cancel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "mark_button_cancel", Other: "Cancel"}})
 		w := dialog.NewForm(title, save, cancel,
-			[]*widget.FormItem{fi}, func(success bool) {
+			[]*widget.FormItem{fi, durationItem}, func(success bool) {
 				if !success {
 					return
 				}
-				showUserError(markFunc(sid64, []string{entry.Text}), window)
+				duration := model.MarkPermanent
+				if selected := durationSelect.SelectedIndex(); selected >= 0 {
+					duration = durations[selected].duration
+				}
+				showUserError(markFunc(sid64, []string{entry.Text}, duration), window)
 			}, window)
 		w.Show()
 	}))
@@ -203,8 +243,11 @@ func generateUserMenu(ctx context.Context, window fyne.Window, ui *Ui, steamId s
 	steamIdTitle := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "user_menu_steam_id", Other: "Copy SteamID..."}})
 	chatHistoryTitle := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "user_menu_chat_hist", Other: "View Chat History"}})
 	nameHistoryTitle := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "user_menu_name_hist", Other: "View Name History"}})
+	killStatsTitle := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "user_menu_kill_stats", Other: "View Kill Stats"}})
+	rageQuitsTitle := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "user_menu_rage_quits", Other: "View Rage Quits"}})
 	whitelistTitle := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "user_menu_whitelist", Other: "Whitelist"}})
 	notesTitle := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "user_menu_notes", Other: "Edit Notes"}})
+	matchDetailsTitle := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "user_menu_match_details", Other: "View Match Details"}})
 	var items []*fyne.MenuItem
 	if userId > 0 {
 		items = append(items, &fyne.MenuItem{
@@ -218,6 +261,15 @@ func generateUserMenu(ctx context.Context, window fyne.Window, ui *Ui, steamId s
 			showUserError(ui.bd.OnUnMark(clsSteamId), window)
 		}
 	}
+	if player := ui.bd.GetPlayer(steamId); player != nil {
+		// Matches are only known for players in the current game
+		items = append(items, &fyne.MenuItem{
+			Icon: theme.InfoIcon(),
+			Action: func() {
+				showMatchDetails(window, player)
+			},
+			Label: matchDetailsTitle})
+	}
 	items = append(items, []*fyne.MenuItem{
 		{
 			Icon:      theme.ZoomFitIcon(),
@@ -249,6 +301,18 @@ func generateUserMenu(ctx context.Context, window fyne.Window, ui *Ui, steamId s
 				ui.createNameHistoryWindow(ctx, steamId)
 			},
 			Label: nameHistoryTitle},
+		{
+			Icon: theme.HistoryIcon(),
+			Action: func() {
+				ui.createRageQuitWindow(ctx, steamId)
+			},
+			Label: rageQuitsTitle},
+		{
+			Icon: theme.WarningIcon(),
+			Action: func() {
+				ui.createKillStatsWindow(ctx, steamId)
+			},
+			Label: killStatsTitle},
 		{
 			Icon:      theme.VisibilityOffIcon(),
 			ChildMenu: generateWhitelistMenu(window, ui, steamId),
@@ -59,7 +59,7 @@ type playerWindow struct {
 }
 
 func (screen *playerWindow) showSettings(settings *model.Settings) {
-	d := newSettingsDialog(screen.logger, screen.window, settings)
+	d := newSettingsDialog(screen.logger, screen.window, settings, screen.bd.ListStatus)
 	d.Show()
 }
 
@@ -208,6 +208,7 @@ func (screen *playerWindow) createMainMenu() {
 	labelMainMenu := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "main_menu_heading", Other: "Bot Detector"}})
 	labelLaunch := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "main_menu_launch", Other: "Launch TF2"}})
 	labelChatLog := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "main_menu_chat_log", Other: "Chat Log"}})
+	labelVotes := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "main_menu_votes", Other: "Vote History"}})
 	labelConfigFolder := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "main_menu_config_folder", Other: "Open Config Folder"}})
 	labelSettings := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "main_menu_settings", Other: "Settings"}})
 	labelQuit := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "main_menu_quit", Other: "Quit"}})
@@ -226,6 +227,14 @@ func (screen *playerWindow) createMainMenu() {
 			Action:   screen.ui.windows.chat.Show,
 			Icon:     theme.MailComposeIcon(),
 		},
+		&fyne.MenuItem{
+			Label: labelVotes,
+			Action: func() {
+				screen.ui.windows.votes.Reload()
+				screen.ui.windows.votes.Show()
+			},
+			Icon: theme.HistoryIcon(),
+		},
 		&fyne.MenuItem{
 			Shortcut: shortCutFolder,
 			Label:    labelConfigFolder,
@@ -458,8 +467,10 @@ func generateRightSegments(ps *model.Player) []*widget.TextSegment {
 		if ps.Whitelisted {
 			suffix = " (WL)"
 		}
-		rightSegments = append(rightSegments,
-			&widget.TextSegment{Text: fmt.Sprintf("%s [%s] [%s]%s", ps.Match.Origin, ps.Match.MatcherType, strings.Join(ps.Match.Attributes, ","), suffix), Style: banStateStyle})
+		for _, match := range ps.Matches {
+			rightSegments = append(rightSegments,
+				&widget.TextSegment{Text: fmt.Sprintf("%s [%s] [%s]%s  ", match.Origin, match.MatcherType, strings.Join(match.Attributes, ","), suffix), Style: banStateStyle})
+		}
 	}
 	if banStateMsg != "" {
 		rightSegments = append(rightSegments, &widget.TextSegment{Text: banStateMsg, Style: banStateStyle})
@@ -491,11 +502,11 @@ func generateBanStateMsg(ps *model.Player) (string, widget.RichTextStyle) {
 	}
 	vacMsg := strings.Join(vacState, ", ")
 	vacMsgFull := vacMsg
-	if ps.LastVACBanOn != nil {
+	if lastBanOn := ps.LastBanOn(); lastBanOn != nil {
 		vacMsgFull = fmt.Sprintf("[%s] (%s - %d days)",
 			vacMsg,
-			ps.LastVACBanOn.Format("Mon Jan 02 2006"),
-			int(time.Since(*ps.LastVACBanOn).Hours()/24),
+			lastBanOn.Format("Mon Jan 02 2006"),
+			int(time.Since(*lastBanOn).Hours()/24),
 		)
 	}
 	return vacMsgFull, style
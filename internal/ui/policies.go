@@ -0,0 +1,256 @@
+package ui
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/internal/tr"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+type policiesConfigDialog struct {
+	dialog.Dialog
+
+	list        *widget.List
+	boundList   binding.UntypedList
+	boundListMu *sync.RWMutex
+}
+
+// actionTypeLabel returns the localised name of the action type
+func actionTypeLabel(actionType model.ActionType) string {
+	messages := map[model.ActionType]*i18n.Message{
+		model.ActionPartyWarning: {ID: "policies_action_party_warning", Other: "Party Warning"},
+		model.ActionChatWarning:  {ID: "policies_action_chat_warning", Other: "Chat Warning"},
+		model.ActionKick:         {ID: "policies_action_kick", Other: "Vote Kick"},
+		model.ActionVoiceMute:    {ID: "policies_action_voice_mute", Other: "Voice Mute"},
+	}
+	msg, found := messages[actionType]
+	if !found {
+		return string(actionType)
+	}
+	return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: msg})
+}
+
+// describePolicy summarises the actions of the policy in the order they are taken
+func describePolicy(policy *model.ActionPolicy) string {
+	var actions []string
+	for _, action := range policy.Actions {
+		if cooldown := action.GetCooldown(); cooldown > 0 {
+			actions = append(actions, fmt.Sprintf("%s (%s)", actionTypeLabel(action.Type), cooldown))
+		} else {
+			actions = append(actions, actionTypeLabel(action.Type))
+		}
+	}
+	return fmt.Sprintf("%s: %s", policy.Attribute, strings.Join(actions, " → "))
+}
+
+func validateCooldown(cooldown string) error {
+	if cooldown == "" {
+		return nil
+	}
+	duration, errParse := time.ParseDuration(cooldown)
+	if errParse != nil || duration < 0 {
+		msg := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "error_invalid_cooldown", Other: "Invalid cooldown, eg: 30s, 5m"}})
+		return errors.New(msg)
+	}
+	return nil
+}
+
+func validateAttribute(attribute string) error {
+	if strings.TrimSpace(attribute) == "" {
+		msg := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "error_attribute_empty", Other: "Attribute cannot be empty"}})
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// newPolicyEditDialog edits a copy of the policy, the policy is only updated and onSave called when the changes
+// are saved
+func newPolicyEditDialog(parent fyne.Window, policy *model.ActionPolicy, onSave func()) dialog.Dialog {
+	var (
+		actionTypes  []string
+		actionLabels = map[string]model.ActionType{}
+		actions      = append([]model.PolicyAction{}, policy.Actions...)
+		rows         = container.NewVBox()
+		render       func()
+	)
+	for _, actionType := range model.ActionTypes() {
+		label := actionTypeLabel(actionType)
+		actionTypes = append(actionTypes, label)
+		actionLabels[label] = actionType
+	}
+	render = func() {
+		rows.RemoveAll()
+		for i := range actions {
+			idx := i
+			typeSelect := widget.NewSelect(actionTypes, func(selected string) {
+				actions[idx].Type = actionLabels[selected]
+			})
+			typeSelect.SetSelected(actionTypeLabel(actions[idx].Type))
+			cooldownEntry := widget.NewEntry()
+			cooldownEntry.SetText(actions[idx].Cooldown)
+			cooldownEntry.Validator = validateCooldown
+			cooldownEntry.OnChanged = func(cooldown string) {
+				actions[idx].Cooldown = cooldown
+			}
+			upButton := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+				if idx == 0 {
+					return
+				}
+				actions[idx-1], actions[idx] = actions[idx], actions[idx-1]
+				render()
+			})
+			removeButton := widget.NewButtonWithIcon("", theme.ContentRemoveIcon(), func() {
+				actions = append(actions[:idx], actions[idx+1:]...)
+				render()
+			})
+			rows.Add(container.NewBorder(nil, nil, typeSelect, container.NewHBox(upButton, removeButton), cooldownEntry))
+		}
+		rows.Refresh()
+	}
+	render()
+
+	attributeEntry := widget.NewEntry()
+	attributeEntry.SetText(policy.Attribute)
+	attributeEntry.Validator = validateAttribute
+
+	addLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_button_add_action", Other: "Add Action"}})
+	addButton := widget.NewButtonWithIcon(addLabel, theme.ContentAddIcon(), func() {
+		actions = append(actions, model.PolicyAction{Type: model.ActionPartyWarning, Cooldown: model.DurationAnnounceMatchTimeout.String()})
+		render()
+	})
+	form := widget.NewForm([]*widget.FormItem{
+		{
+			Text:     tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_label_attribute", Other: "Attribute"}}),
+			Widget:   attributeEntry,
+			HintText: tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_label_attribute_hint", Other: "Use * for matches without a policy of their own"}}),
+		},
+		{
+			Text:     tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_label_actions", Other: "Actions"}}),
+			Widget:   container.NewBorder(nil, addButton, nil, nil, rows),
+			HintText: tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_label_actions_hint", Other: "Taken in order, the cooldown is the minimum time between repeats, eg: 30s, 5m"}}),
+		},
+	}...)
+	title := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_title_edit_policy", Other: "Edit Policy"}})
+	saveLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_button_save", Other: "Save"}})
+	cancelLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_button_cancel", Other: "Cancel"}})
+	d := dialog.NewCustomConfirm(title, saveLabel, cancelLabel, form, func(save bool) {
+		if !save {
+			return
+		}
+		if errValid := form.Validate(); errValid != nil {
+			showUserError(errValid, parent)
+			return
+		}
+		policy.Attribute = strings.TrimSpace(attributeEntry.Text)
+		policy.Actions = actions
+		onSave()
+	}, parent)
+	d.Resize(fyne.NewSize(sizeDialogueWidth, d.MinSize().Height))
+	return d
+}
+
+// newPoliciesDialog edits the per attribute action policies of the settings
+func newPoliciesDialog(parent fyne.Window, logger *zap.Logger, settings *model.Settings) *policiesConfigDialog {
+	pcd := policiesConfigDialog{
+		boundListMu: &sync.RWMutex{},
+		boundList:   binding.NewUntypedList(),
+	}
+	reload := func() {
+		pcd.boundListMu.Lock()
+		if errReload := pcd.boundList.Set(settings.GetPolicies().AsAny()); errReload != nil {
+			logger.Error("Failed to reload policies list", zap.Error(errReload))
+		}
+		pcd.boundListMu.Unlock()
+		pcd.list.Refresh()
+	}
+	selectedId := -1
+	_ = pcd.boundList.Set(settings.GetPolicies().AsAny())
+	pcd.list = widget.NewListWithData(pcd.boundList, func() fyne.CanvasObject {
+		return container.NewBorder(
+			nil,
+			nil,
+			nil,
+			container.NewHBox(widget.NewButtonWithIcon("", theme.SettingsIcon(), func() {})),
+			widget.NewLabel(""),
+		)
+	}, func(i binding.DataItem, object fyne.CanvasObject) {
+		value := i.(binding.Untyped)
+		obj, _ := value.Get()
+		policy := obj.(*model.ActionPolicy)
+
+		rootContainer := object.(*fyne.Container)
+		label := rootContainer.Objects[0].(*widget.Label)
+		btnContainer := rootContainer.Objects[1].(*fyne.Container)
+		editButton := btnContainer.Objects[0].(*widget.Button)
+
+		label.SetText(describePolicy(policy))
+		editButton.OnTapped = func() {
+			newPolicyEditDialog(parent, policy, reload).Show()
+		}
+		editButton.Refresh()
+	})
+	delButton := widget.NewButtonWithIcon(
+		tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "policies_button_delete", Other: "Delete"}}),
+		theme.ContentRemoveIcon(),
+		func() {})
+	delButton.Disable()
+
+	pcd.list.OnSelected = func(id widget.ListItemID) {
+		selectedId = id
+		delButton.Enable()
+	}
+	pcd.list.OnUnselected = func(id widget.ListItemID) {
+		delButton.Disable()
+		selectedId = -1
+	}
+	addLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_button_add", Other: "Add"}})
+	addButton := widget.NewButtonWithIcon(addLabel, theme.ContentAddIcon(), func() {
+		policy := &model.ActionPolicy{}
+		newPolicyEditDialog(parent, policy, func() {
+			settings.SetPolicies(append(settings.GetPolicies(), policy))
+			reload()
+		}).Show()
+	})
+	delButton.OnTapped = func() {
+		title := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_title_delete", Other: "Delete Policy"}})
+		msg := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_label_confirm", Other: "Are you sure?"}})
+		confirm := dialog.NewConfirm(title, msg, func(b bool) {
+			if !b {
+				return
+			}
+			var updatedPolicies model.ActionPolicyCollection
+			for idx, policy := range settings.GetPolicies() {
+				if idx == selectedId {
+					continue
+				}
+				updatedPolicies = append(updatedPolicies, policy)
+			}
+			settings.SetPolicies(updatedPolicies)
+			pcd.list.UnselectAll()
+			reload()
+		}, parent)
+		confirm.Show()
+	}
+
+	title := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_title_edit", Other: "Edit Policies"}})
+	closeLabel := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "policies_button_close", Other: "Close"}})
+	pcd.Dialog = dialog.NewCustom(title, closeLabel,
+		container.NewBorder(container.NewHBox(addButton, delButton), nil, nil, nil, pcd.list), parent)
+
+	pcd.Resize(fyne.NewSize(sizeDialogueWidth, sizeDialogueHeight))
+	return &pcd
+}
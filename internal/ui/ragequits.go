@@ -0,0 +1,111 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/widget"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/internal/tr"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+type userRageQuitWindow struct {
+	fyne.Window
+
+	list          *widget.List
+	boundList     binding.ExternalUntypedList
+	objectMu      sync.RWMutex
+	rageQuitCount binding.Int
+	rageQuitsFunc model.QueryRageQuitsFunc
+	sid64         steamid.SID64
+	logger        *zap.Logger
+}
+
+// rageQuitCauseLabel returns the localised description of the rage quit cause
+func rageQuitCauseLabel(cause model.RageQuitCause) string {
+	switch cause {
+	case model.RageQuitCauseKickVote:
+		return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "rage_quits_cause_kick_vote", Other: "Kick Vote"}})
+	default:
+		return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "rage_quits_cause_death", Other: "Killed By Us"}})
+	}
+}
+
+// Reload fetches the rage quits of the player again, as new incidents may have been recorded since the window
+// was opened
+func (rageQuitList *userRageQuitWindow) Reload(ctx context.Context) {
+	rageQuits, errFetch := rageQuitList.rageQuitsFunc(ctx, rageQuitList.sid64)
+	if errFetch != nil {
+		rageQuitList.logger.Error("Failed to fetch rage quits", zap.Error(errFetch))
+	}
+	if errSet := rageQuitList.boundList.Set(rageQuits.AsAny()); errSet != nil {
+		rageQuitList.logger.Error("Failed to set rage quit list", zap.Error(errSet))
+	}
+	if errSetCount := rageQuitList.rageQuitCount.Set(len(rageQuits)); errSetCount != nil {
+		rageQuitList.logger.Error("Failed to set rage quit count", zap.Error(errSetCount))
+	}
+	rageQuitList.list.ScrollToBottom()
+}
+
+func newUserRageQuitWindow(ctx context.Context, logger *zap.Logger, app fyne.App, rageQuitsFunc model.QueryRageQuitsFunc, sid64 steamid.SID64) *userRageQuitWindow {
+	title := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "rage_quits_title", Other: "Rage Quits: {{ .SteamID }}"},
+		TemplateData: map[string]interface{}{
+			"SteamID": sid64,
+		}})
+	appWindow := app.NewWindow(title)
+	appWindow.SetCloseIntercept(func() {
+		appWindow.Hide()
+	})
+	rql := &userRageQuitWindow{
+		Window:        appWindow,
+		logger:        logger,
+		boundList:     binding.BindUntypedList(&[]interface{}{}),
+		rageQuitCount: binding.NewInt(),
+		rageQuitsFunc: rageQuitsFunc,
+		sid64:         sid64,
+	}
+	rql.list = widget.NewListWithData(
+		rql.boundList,
+		func() fyne.CanvasObject {
+			return container.NewBorder(
+				nil,
+				nil,
+				widget.NewLabel(""),
+				nil,
+				widget.NewLabel(""))
+		},
+		func(i binding.DataItem, o fyne.CanvasObject) {
+			value := i.(binding.Untyped)
+			obj, _ := value.Get()
+			rageQuit := obj.(model.RageQuit)
+			rql.objectMu.Lock()
+			rootContainer := o.(*fyne.Container)
+			timeStamp := rootContainer.Objects[1].(*widget.Label)
+			timeStamp.SetText(rageQuit.CreatedOn.Format(time.RFC822))
+			details := rootContainer.Objects[0].(*widget.Label)
+			details.SetText(fmt.Sprintf("%s - %s (%s)", rageQuitCauseLabel(rageQuit.Cause), rageQuit.MapName, rageQuit.ServerName))
+			rql.objectMu.Unlock()
+		})
+	rql.Reload(ctx)
+
+	labelCount := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "rage_quits_label_count", Other: "Count: "}})
+	rql.SetContent(container.NewBorder(
+		container.NewHBox(widget.NewLabelWithData(binding.IntToStringWithFormat(rql.rageQuitCount, fmt.Sprintf("%s%%d", labelCount)))),
+		nil,
+		nil,
+		nil,
+		container.NewVScroll(rql.list)))
+	rql.Resize(fyne.NewSize(sizeDialogueWidth, sizeDialogueHeight))
+	rql.Show()
+	return rql
+}
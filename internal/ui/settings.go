@@ -17,10 +17,9 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"path/filepath"
-	"strings"
 )
 
-func newSettingsDialog(logger *zap.Logger, parent fyne.Window, origSettings *model.Settings) dialog.Dialog {
+func newSettingsDialog(logger *zap.Logger, parent fyne.Window, origSettings *model.Settings, listStatus listStatusFunc) dialog.Dialog {
 	const testSteamId = 76561197961279983
 
 	settings := clone.Clone[*model.Settings](origSettings)
@@ -113,12 +112,6 @@ func newSettingsDialog(logger *zap.Logger, parent fyne.Window, origSettings *mod
 	debugLogEnabledEntry := widget.NewCheckWithData("", binding.BindBool(&settings.DebugLogEnabled))
 
 	staticConfig := model.NewRconConfig(true)
-	boundTags := binding.NewString()
-	if errSet := boundTags.Set(strings.Join(settings.GetKickTags(), ",")); errSet != nil {
-		logger.Error("Failed to set tags", zap.Error(errSet))
-	}
-	tagsEntry := widget.NewEntryWithData(boundTags)
-	tagsEntry.Validator = validateTags
 	linksDialog := newLinksDialog(parent, logger, settings)
 	linksButton := widget.NewButtonWithIcon("Edit Links", theme.SettingsIcon(), func() {
 		linksDialog.Show()
@@ -126,17 +119,31 @@ func newSettingsDialog(logger *zap.Logger, parent fyne.Window, origSettings *mod
 	linksButton.Alignment = widget.ButtonAlignLeading
 	linksButton.Refresh()
 
-	listsDialog := newRuleListConfigDialog(parent, logger, settings)
+	policiesDialog := newPoliciesDialog(parent, logger, settings)
+	policiesButton := widget.NewButtonWithIcon("Edit Policies", theme.SettingsIcon(), func() {
+		policiesDialog.Show()
+	})
+	policiesButton.Alignment = widget.ButtonAlignLeading
+	policiesButton.Refresh()
+
+	listsDialog := newRuleListConfigDialog(parent, logger, settings, listStatus)
 	listsButton := widget.NewButtonWithIcon("Edit Lists", theme.SettingsIcon(), func() {
 		listsDialog.Show()
 	})
 	listsButton.Alignment = widget.ButtonAlignLeading
 	listsButton.Refresh()
 
+	listRefreshEntry := widget.NewEntryWithData(binding.BindString(&settings.ListRefreshInterval))
+	listRefreshEntry.Validator = validateListRefreshInterval
+
 	labelLists := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
 		DefaultMessage: &i18n.Message{ID: "settings_label_lists", Other: "Lists & Rules"}})
 	labelListsHint := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
 		DefaultMessage: &i18n.Message{ID: "settings_label_lists_hint", Other: "Configure your 3rd party player and rule lists"}})
+	labelListRefresh := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "settings_label_list_refresh", Other: "List Refresh Interval"}})
+	labelListRefreshHint := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "settings_label_list_refresh_hint", Other: "How often to check for list updates, eg: 30m, 1h, 12h"}})
 	labelLinks := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
 		DefaultMessage: &i18n.Message{ID: "settings_label_links", Other: "External Links"}})
 	labelLinksHint := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
@@ -145,10 +152,10 @@ func newSettingsDialog(logger *zap.Logger, parent fyne.Window, origSettings *mod
 		DefaultMessage: &i18n.Message{ID: "settings_label_kicker_enabled", Other: "Vote Kicker"}})
 	labelKickerEnabledHint := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
 		DefaultMessage: &i18n.Message{ID: "settings_label_kicker_enabled_hint", Other: "Enable vote kick functionality in-game"}})
-	labelKickableTags := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
-		DefaultMessage: &i18n.Message{ID: "settings_label_kickable_tags", Other: "Kickable Tags"}})
-	labelKickableTagsHint := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
-		DefaultMessage: &i18n.Message{ID: "settings_label_kickable_tags_hint", Other: "Attributes/Tags that when matched will trigger a in-game kick."}})
+	labelPolicies := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "settings_label_policies", Other: "Action Policies"}})
+	labelPoliciesHint := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "settings_label_policies_hint", Other: "Actions taken against players matching each attribute"}})
 	labelChatWarnEnabled := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
 		DefaultMessage: &i18n.Message{ID: "settings_label_chat_warn_enabled", Other: "Chat Warnings"}})
 	labelChatWarnEnabledHint := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
@@ -208,9 +215,10 @@ func newSettingsDialog(logger *zap.Logger, parent fyne.Window, origSettings *mod
 	settingsForm := &widget.Form{
 		Items: []*widget.FormItem{
 			{Text: labelLists, Widget: listsButton, HintText: labelListsHint},
+			{Text: labelListRefresh, Widget: listRefreshEntry, HintText: labelListRefreshHint},
 			{Text: labelLinks, Widget: linksButton, HintText: labelLinksHint},
 			{Text: labelKickerEnabled, Widget: kickerEnabledEntry, HintText: labelKickerEnabledHint},
-			{Text: labelKickableTags, Widget: tagsEntry, HintText: labelKickableTagsHint},
+			{Text: labelPolicies, Widget: policiesButton, HintText: labelPoliciesHint},
 			{Text: labelChatWarnEnabled, Widget: chatWarningsEnabledEntry, HintText: labelChatWarnEnabledHint},
 			{Text: labelPartyWarnEnabled, Widget: partyWarningsEnabledEntry, HintText: labelPartyWarnEnabledHint},
 			{Text: labelDiscordPresence, Widget: discordPresenceEnabledEntry, HintText: labelDiscordPresenceHint},
@@ -243,14 +251,6 @@ func newSettingsDialog(logger *zap.Logger, parent fyne.Window, origSettings *mod
 			origSettings.SetSteamID(newSid.String())
 			steamIdEntry.SetText(newSid.String())
 		}
-		var newTags []string
-		for _, t := range strings.Split(tagsEntry.Text, ",") {
-			if t == "" {
-				continue
-			}
-			newTags = append(newTags, strings.Trim(t, " "))
-		}
-		origSettings.SetKickTags(newTags)
 		origSettings.SetAPIKey(apiKeyEntry.Text)
 		origSettings.SetSteamDir(steamDirEntry.Text)
 		origSettings.SetTF2Dir(tf2RootEntry.Text)
@@ -265,6 +265,8 @@ func newSettingsDialog(logger *zap.Logger, parent fyne.Window, origSettings *mod
 		origSettings.SetDiscordPresenceEnabled(discordPresenceEnabledEntry.Checked)
 		origSettings.SetLinks(settings.GetLinks())
 		origSettings.SetLists(settings.GetLists())
+		origSettings.SetPolicies(settings.GetPolicies())
+		origSettings.SetListRefreshInterval(listRefreshEntry.Text)
 
 		if apiKeyOriginal != apiKeyEntry.Text {
 			if errSetKey := steamweb.SetKey(apiKeyEntry.Text); errSetKey != nil {
@@ -22,8 +22,8 @@ import (
 	"go.uber.org/zap"
 	"net/url"
 	"path/filepath"
-	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -56,6 +56,9 @@ type windows struct {
 	search      *searchWindow
 	chatHistory map[steamid.SID64]*userChatWindow
 	nameHistory map[steamid.SID64]*userNameWindow
+	rageQuits   map[steamid.SID64]*userRageQuitWindow
+	killStats   map[steamid.SID64]*userKillStatsWindow
+	votes       *voteHistoryWindow
 }
 
 type MenuCreator func(window fyne.Window, steamId steamid.SID64, userId int64) *fyne.Menu
@@ -90,6 +93,8 @@ func New(ctx context.Context, logger *zap.Logger, bd *detector.BD, settings *mod
 		windows: &windows{
 			chatHistory: map[steamid.SID64]*userChatWindow{},
 			nameHistory: map[steamid.SID64]*userNameWindow{},
+			rageQuits:   map[steamid.SID64]*userRageQuitWindow{},
+			killStats:   map[steamid.SID64]*userKillStatsWindow{},
 		},
 		avatarCache: &avatarCache{
 			RWMutex:    &sync.RWMutex{},
@@ -101,6 +106,8 @@ func New(ctx context.Context, logger *zap.Logger, bd *detector.BD, settings *mod
 
 	ui.windows.search = newSearchWindow(ctx, &ui)
 
+	ui.windows.votes = newVoteHistoryWindow(ctx, ui.logger, ui.application, bd.Store().FetchVotes, bd.Store().FetchVoteDefenders)
+
 	ui.windows.player = ui.newPlayerWindow(
 		ui.logger,
 		func(window fyne.Window, steamId steamid.SID64, userId int64) *fyne.Menu {
@@ -171,6 +178,25 @@ func (ui *Ui) createNameHistoryWindow(ctx context.Context, sid64 steamid.SID64)
 	ui.windows.nameHistory[sid64].Show()
 }
 
+func (ui *Ui) createRageQuitWindow(ctx context.Context, sid64 steamid.SID64) {
+	if rageQuitWindow, found := ui.windows.rageQuits[sid64]; found {
+		rageQuitWindow.Reload(ctx)
+	} else {
+		ui.windows.rageQuits[sid64] = newUserRageQuitWindow(ctx, ui.logger, ui.application, ui.bd.Store().FetchRageQuits, sid64)
+	}
+	ui.windows.rageQuits[sid64].Show()
+}
+
+func (ui *Ui) createKillStatsWindow(ctx context.Context, sid64 steamid.SID64) {
+	if killStatsWindow, found := ui.windows.killStats[sid64]; found {
+		killStatsWindow.Reload(ctx)
+	} else {
+		ui.windows.killStats[sid64] = newUserKillStatsWindow(ctx, ui.logger, ui.application,
+			ui.bd.Store().FetchWeaponStats, ui.bd.Store().FetchKills, sid64)
+	}
+	ui.windows.killStats[sid64].Show()
+}
+
 func (ui *Ui) Start(ctx context.Context) {
 	defer ui.bd.Shutdown()
 	ui.bd.AttachGui(ui)
@@ -242,23 +268,20 @@ func validateSteamRoot(newRoot string) error {
 	return nil
 }
 
-func validateTags(tagStr string) error {
-	var validTags []string
-	for _, tag := range strings.Split(tagStr, ",") {
-		normalized := strings.Trim(tag, " ")
-		for _, vt := range validTags {
-			if strings.EqualFold(vt, normalized) {
-				msg := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
-					DefaultMessage: &i18n.Message{
-						ID:    "error_invalid_tag",
-						Other: "Duplicate tag found: {{ .TagName }}"},
-					TemplateData: map[string]interface{}{
-						"TagName": vt,
-					}})
-				return errors.New(msg)
-			}
-		}
-		validTags = append(validTags, normalized)
+func validateListRefreshInterval(interval string) error {
+	if interval == "" {
+		return nil
+	}
+	duration, errParse := time.ParseDuration(interval)
+	if errParse != nil || duration < model.DurationListRefreshMin {
+		msg := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "error_invalid_refresh_interval",
+				Other: "Invalid interval, must be at least {{ .Min }}"},
+			TemplateData: map[string]interface{}{
+				"Min": model.DurationListRefreshMin.String(),
+			}})
+		return errors.New(msg)
 	}
 	return nil
 }
@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/widget"
+	"github.com/leighmacdonald/bd/internal/model"
+	"github.com/leighmacdonald/bd/internal/tr"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// voteHistoryLimit is the number of recent votes shown
+	voteHistoryLimit = 200
+	// voteDefendersLimit is the number of players shown who most often voted against kicking marked players
+	voteDefendersLimit = 100
+)
+
+// voteHistoryWindow shows the recorded kick votes and the players who voted against kicking marked players
+type voteHistoryWindow struct {
+	fyne.Window
+
+	ctx           context.Context
+	votesList     *widget.List
+	boundVotes    binding.ExternalUntypedList
+	defendersList *widget.List
+	boundDefender binding.ExternalUntypedList
+	objectMu      sync.RWMutex
+	votesFunc     model.QueryVotesFunc
+	defendersFunc model.QueryVoteDefendersFunc
+	logger        *zap.Logger
+}
+
+// voteResultLabel returns the localised outcome of the vote
+func voteResultLabel(result model.VoteResult) string {
+	switch result {
+	case model.VoteResultPassed:
+		return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "votes_result_passed", Other: "Passed"}})
+	case model.VoteResultFailed:
+		return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "votes_result_failed", Other: "Failed"}})
+	default:
+		return tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "votes_result_unknown", Other: "Unknown"}})
+	}
+}
+
+// voteDetails describes the vote along with the players who voted against kicking a marked target
+func voteDetails(vote *model.Vote) string {
+	details := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{ID: "votes_label_vote", Other: "{{ .Caller }} kick {{ .Target }}: {{ .Result }} ({{ .Yes }}/{{ .No }})"},
+		TemplateData: map[string]interface{}{
+			"Caller": vote.CallerName,
+			"Target": vote.TargetName,
+			"Result": voteResultLabel(vote.Result),
+			"Yes":    vote.Yes,
+			"No":     vote.No,
+		}})
+	if !vote.TargetMarked() {
+		return details
+	}
+	details = fmt.Sprintf("%s [%s]", details, strings.Join(vote.TargetAttributes, ", "))
+	var names []string
+	for _, defender := range vote.Defenders() {
+		names = append(names, defender.Name)
+	}
+	if len(names) > 0 {
+		details += " " + tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{ID: "votes_label_defended_by", Other: "Defended By: {{ .Names }}"},
+			TemplateData:   map[string]interface{}{"Names": strings.Join(names, ", ")}})
+	}
+	return details
+}
+
+// Reload fetches the votes and defenders again, as new votes may have been recorded since the window was last shown
+func (window *voteHistoryWindow) Reload() {
+	votes, errVotes := window.votesFunc(window.ctx, voteHistoryLimit)
+	if errVotes != nil {
+		window.logger.Error("Failed to fetch votes", zap.Error(errVotes))
+	}
+	boundVotes := make([]interface{}, len(votes))
+	for i, vote := range votes {
+		boundVotes[i] = vote
+	}
+	if errSet := window.boundVotes.Set(boundVotes); errSet != nil {
+		window.logger.Error("Failed to set vote list", zap.Error(errSet))
+	}
+	defenders, errDefenders := window.defendersFunc(window.ctx, voteDefendersLimit)
+	if errDefenders != nil {
+		window.logger.Error("Failed to fetch vote defenders", zap.Error(errDefenders))
+	}
+	boundDefenders := make([]interface{}, len(defenders))
+	for i, defender := range defenders {
+		boundDefenders[i] = defender
+	}
+	if errSet := window.boundDefender.Set(boundDefenders); errSet != nil {
+		window.logger.Error("Failed to set vote defender list", zap.Error(errSet))
+	}
+}
+
+func newVoteHistoryWindow(ctx context.Context, logger *zap.Logger, app fyne.App, votesFunc model.QueryVotesFunc,
+	defendersFunc model.QueryVoteDefendersFunc) *voteHistoryWindow {
+	title := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "votes_title", Other: "Vote History"}})
+	appWindow := app.NewWindow(title)
+	appWindow.SetCloseIntercept(func() {
+		appWindow.Hide()
+	})
+	vhw := &voteHistoryWindow{
+		Window:        appWindow,
+		ctx:           ctx,
+		logger:        logger,
+		boundVotes:    binding.BindUntypedList(&[]interface{}{}),
+		boundDefender: binding.BindUntypedList(&[]interface{}{}),
+		votesFunc:     votesFunc,
+		defendersFunc: defendersFunc,
+	}
+	vhw.votesList = widget.NewListWithData(
+		vhw.boundVotes,
+		func() fyne.CanvasObject {
+			return container.NewBorder(
+				nil,
+				nil,
+				widget.NewLabel(""),
+				nil,
+				widget.NewLabel(""))
+		},
+		func(i binding.DataItem, o fyne.CanvasObject) {
+			value := i.(binding.Untyped)
+			obj, _ := value.Get()
+			vote := obj.(*model.Vote)
+			vhw.objectMu.Lock()
+			rootContainer := o.(*fyne.Container)
+			timeStamp := rootContainer.Objects[1].(*widget.Label)
+			timeStamp.SetText(vote.CreatedOn.Format(time.RFC822))
+			details := rootContainer.Objects[0].(*widget.Label)
+			details.SetText(voteDetails(vote))
+			vhw.objectMu.Unlock()
+		})
+	vhw.defendersList = widget.NewListWithData(
+		vhw.boundDefender,
+		func() fyne.CanvasObject {
+			return container.NewBorder(
+				nil,
+				nil,
+				widget.NewLabel(""),
+				nil,
+				widget.NewLabel(""))
+		},
+		func(i binding.DataItem, o fyne.CanvasObject) {
+			value := i.(binding.Untyped)
+			obj, _ := value.Get()
+			defender := obj.(model.VoteDefender)
+			vhw.objectMu.Lock()
+			rootContainer := o.(*fyne.Container)
+			timeStamp := rootContainer.Objects[1].(*widget.Label)
+			timeStamp.SetText(defender.LastDefendedOn.Format(time.RFC822))
+			details := rootContainer.Objects[0].(*widget.Label)
+			details.SetText(tr.Localizer.MustLocalize(&i18n.LocalizeConfig{
+				DefaultMessage: &i18n.Message{ID: "votes_label_defender", Other: "{{ .Name }} ({{ .SteamID }}): {{ .Count }}"},
+				TemplateData: map[string]interface{}{
+					"Name":    defender.Name,
+					"SteamID": defender.SteamId.Int64(),
+					"Count":   defender.Count,
+				}}))
+			vhw.objectMu.Unlock()
+		})
+	labelVotes := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "votes_tab_votes", Other: "Votes"}})
+	labelDefenders := tr.Localizer.MustLocalize(&i18n.LocalizeConfig{DefaultMessage: &i18n.Message{ID: "votes_tab_defenders", Other: "Defenders Of Marked Players"}})
+	vhw.SetContent(container.NewAppTabs(
+		container.NewTabItem(labelVotes, container.NewVScroll(vhw.votesList)),
+		container.NewTabItem(labelDefenders, container.NewVScroll(vhw.defendersList))))
+	vhw.Resize(fyne.NewSize(sizeWindowChatWidth, sizeWindowChatHeight))
+	return vhw
+}
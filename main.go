@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/leighmacdonald/bd/internal/cache"
@@ -80,35 +79,62 @@ func main() {
 
 	localRules := rules.NewRuleSchema()
 	localPlayersList := rules.NewPlayerListSchema()
+	localWhitelist := rules.NewWhitelistSchema()
 
-	// Try and load our existing custom players/rules
+	// Try and load our existing custom players/rules. Local lists are loaded leniently so that a single
+	// invalid entry does not discard the entire list.
 	if util.Exists(settings.LocalPlayerListPath()) {
-		input, errInput := os.Open(settings.LocalPlayerListPath())
+		input, errInput := os.ReadFile(settings.LocalPlayerListPath())
 		if errInput != nil {
 			logger.Error("Failed to open local player list", zap.Error(errInput))
 		} else {
-			if errRead := json.NewDecoder(input).Decode(&localPlayersList); errRead != nil {
+			list, dropped, errRead := rules.ParsePlayerList(input, true)
+			if errRead != nil {
 				logger.Error("Failed to parse local player list", zap.Error(errRead))
 			} else {
+				localPlayersList = list
+				if dropped > 0 {
+					logger.Warn("Dropped invalid local player entries", zap.Int("count", dropped))
+				}
 				logger.Debug("Loaded local player list", zap.Int("count", len(localPlayersList.Players)))
 			}
-			util.LogClose(logger, input)
+		}
+	}
+	if util.Exists(settings.LocalWhitelistPath()) {
+		input, errInput := os.ReadFile(settings.LocalWhitelistPath())
+		if errInput != nil {
+			logger.Error("Failed to open local whitelist", zap.Error(errInput))
+		} else {
+			list, dropped, errRead := rules.ParsePlayerList(input, true)
+			if errRead != nil {
+				logger.Error("Failed to parse local whitelist", zap.Error(errRead))
+			} else {
+				localWhitelist = list
+				if dropped > 0 {
+					logger.Warn("Dropped invalid local whitelist entries", zap.Int("count", dropped))
+				}
+				logger.Debug("Loaded local whitelist", zap.Int("count", len(localWhitelist.Players)))
+			}
 		}
 	}
 	if util.Exists(settings.LocalRulesListPath()) {
-		input, errInput := os.Open(settings.LocalRulesListPath())
+		input, errInput := os.ReadFile(settings.LocalRulesListPath())
 		if errInput != nil {
 			logger.Error("Failed to open local rules list", zap.Error(errInput))
 		} else {
-			if errRead := json.NewDecoder(input).Decode(&localRules); errRead != nil {
+			list, dropped, errRead := rules.ParseRuleList(input, true)
+			if errRead != nil {
 				logger.Error("Failed to parse local rules list", zap.Error(errRead))
 			} else {
+				localRules = list
+				if dropped > 0 {
+					logger.Warn("Dropped invalid local rules", zap.Int("count", dropped))
+				}
 				logger.Debug("Loaded local rules list", zap.Int("count", len(localRules.Rules)))
 			}
-			util.LogClose(logger, input)
 		}
 	}
-	engine, ruleEngineErr := rules.New(&localRules, &localPlayersList)
+	engine, ruleEngineErr := rules.New(&localRules, &localPlayersList, &localWhitelist)
 	if ruleEngineErr != nil {
 		logger.Panic("Failed to setup rules engine", zap.Error(ruleEngineErr))
 	}
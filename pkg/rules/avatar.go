@@ -0,0 +1,125 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"image"
+	// Steam serves avatars as jpeg, png is registered for locally sourced images
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"strconv"
+)
+
+const (
+	// DefaultPerceptualDistance is the maximum number of differing bits between two perceptual hashes
+	// for them to be considered the same avatar when a rule does not define its own threshold
+	DefaultPerceptualDistance = 10
+	// maxPerceptualDistance is the number of bits in a perceptual hash
+	maxPerceptualDistance = 64
+
+	perceptualHashWidth  = 9
+	perceptualHashHeight = 8
+)
+
+// PerceptualHash computes the difference hash (dHash) of an encoded avatar image. Unlike HashBytes, the hash
+// of a re-encoded, resized or slightly altered image only differs by a small number of bits, so similar
+// avatars can be found by comparing the hamming distance between hashes.
+//
+// The image is reduced to a 9x8 grayscale image and each bit records if a pixel is brighter than its
+// right neighbour. The result is returned as 16 hex characters.
+func PerceptualHash(avatar []byte) (string, error) {
+	img, _, errDecode := image.Decode(bytes.NewReader(avatar))
+	if errDecode != nil {
+		return "", errors.Wrap(errDecode, "Failed to decode avatar")
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() < perceptualHashWidth || bounds.Dy() < perceptualHashHeight {
+		return "", errors.Errorf("Avatar too small: %dx%d", bounds.Dx(), bounds.Dy())
+	}
+	var gray [perceptualHashHeight][perceptualHashWidth]float64
+	for y := 0; y < perceptualHashHeight; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/perceptualHashHeight
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/perceptualHashHeight
+		for x := 0; x < perceptualHashWidth; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/perceptualHashWidth
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/perceptualHashWidth
+			// Box filter the area of the source image covered by the reduced pixel
+			var sum float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			gray[y][x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	var hash uint64
+	for y := 0; y < perceptualHashHeight; y++ {
+		for x := 0; x < perceptualHashWidth-1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", hash), nil
+}
+
+func parsePerceptualHash(hexDigest string) (uint64, error) {
+	if len(hexDigest) != 16 {
+		return 0, errors.Errorf("Invalid perceptual hash length: %d", len(hexDigest))
+	}
+	hash, errParse := strconv.ParseUint(hexDigest, 16, 64)
+	if errParse != nil {
+		return 0, errors.Wrap(errParse, "Invalid perceptual hash")
+	}
+	return hash, nil
+}
+
+// perceptualHash is a parsed perceptual hash along with the maximum distance another hash can be
+// from it to be considered a match
+type perceptualHash struct {
+	hash        uint64
+	maxDistance int
+}
+
+// perceptualAvatarMatcher matches avatars which are visually similar to a known avatar
+type perceptualAvatarMatcher struct {
+	origin     string
+	hashes     []perceptualHash
+	attributes []string
+}
+
+func (m perceptualAvatarMatcher) Type() avatarMatchType {
+	return avatarMatchReduced
+}
+
+func (m perceptualAvatarMatcher) Match(hexDigest string) *MatchResult {
+	hash, errHash := parsePerceptualHash(hexDigest)
+	if errHash != nil {
+		return nil
+	}
+	for _, known := range m.hashes {
+		if distance := bits.OnesCount64(hash ^ known.hash); distance <= known.maxDistance {
+			return &MatchResult{
+				Origin:      m.origin,
+				MatcherType: string(m.Type()),
+				Attributes:  m.attributes,
+				Pattern:     fmt.Sprintf("%016x", known.hash),
+				Matched:     fmt.Sprintf("%s (distance %d)", hexDigest, distance),
+			}
+		}
+	}
+	return nil
+}
+
+func newPerceptualAvatarMatcher(origin string, attributes []string, hashes ...perceptualHash) perceptualAvatarMatcher {
+	return perceptualAvatarMatcher{
+		origin:     origin,
+		hashes:     hashes,
+		attributes: attributes,
+	}
+}
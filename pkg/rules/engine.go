@@ -4,6 +4,7 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"github.com/leighmacdonald/steamid/v2/steamid"
 	"github.com/pkg/errors"
 	"io"
@@ -14,19 +15,52 @@ import (
 )
 
 var (
-	errDuplicateSteamID = errors.New("duplicate steam id")
+	// ErrDuplicateSteamID is returned when marking a player with attributes and an expiry they are already marked with
+	ErrDuplicateSteamID = errors.New("duplicate steam id")
 )
 
+// RuleError describes a single rule from a list that could not be loaded.
+type RuleError struct {
+	List  string
+	Index int
+	Err   error
+}
+
+func (e RuleError) Error() string {
+	return fmt.Sprintf("%s: rule %d: %v", e.List, e.Index, e.Err)
+}
+
+func (e RuleError) Unwrap() error {
+	return e.Err
+}
+
+// RuleErrors is returned when importing a rules list where some of the rules were invalid. The invalid
+// rules are skipped while the rest of the list is still loaded.
+type RuleErrors []RuleError
+
+func (e RuleErrors) Error() string {
+	var msgs []string
+	for _, ruleErr := range e {
+		msgs = append(msgs, ruleErr.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
 const (
 	exportIndentSize = 4
+	// transientSource is the source of the matchers created for transient marks, which keeps them separate
+	// from the matchers of the local player list
+	transientSource = "transient"
 )
 
-func New(localRules *RuleSchema, localPlayers *PlayerListSchema) (*Engine, error) {
+func New(localRules *RuleSchema, localPlayers *PlayerListSchema, localWhitelist *PlayerListSchema) (*Engine, error) {
 	re := Engine{
-		RWMutex:        &sync.RWMutex{},
-		matchersSteam:  nil,
-		matchersText:   nil,
-		matchersAvatar: nil,
+		RWMutex:           &sync.RWMutex{},
+		matchersSteam:     steamIDIndex{},
+		matchersRule:      nil,
+		textIndex:         newTextIndex(nil),
+		transientMarks:    map[steamid.SID64]playerDefinition{},
+		matchersWhitelist: steamIDIndex{},
 	}
 	if localRules != nil {
 		if _, errImport := re.ImportRules(localRules); errImport != nil {
@@ -45,17 +79,30 @@ func New(localRules *RuleSchema, localPlayers *PlayerListSchema) (*Engine, error
 		ls := NewPlayerListSchema()
 		re.playerLists = append(re.playerLists, &ls)
 	}
+	if localWhitelist != nil {
+		if _, errImport := re.ImportWhitelist(localWhitelist); errImport != nil {
+			return nil, errors.Wrap(errImport, "Failed to load local whitelist")
+		}
+	} else {
+		ls := NewWhitelistSchema()
+		re.whitelists = append(re.whitelists, &ls)
+	}
 	return &re, nil
 }
 
 type Engine struct {
 	*sync.RWMutex
-	matchersSteam  []SteamIDMatcher
-	matchersText   []TextMatcher
-	matchersAvatar []AvatarMatcher
-	rulesLists     []*RuleSchema
-	playerLists    []*PlayerListSchema
-	knownTags      []string
+	matchersSteam steamIDIndex
+	matchersRule  []ruleMatcher
+	textIndex     *textIndex
+	rulesLists    []*RuleSchema
+	playerLists   []*PlayerListSchema
+	knownTags     []string
+	// transientMarks holds the marks which are only kept in memory
+	transientMarks map[steamid.SID64]playerDefinition
+	// whitelists holds the whitelists, the first of which is the local whitelist
+	whitelists        []*PlayerListSchema
+	matchersWhitelist steamIDIndex
 }
 
 type MarkOpts struct {
@@ -63,27 +110,41 @@ type MarkOpts struct {
 	Attributes []string
 	Proof      []string
 	Name       string
+	// ExpiresOn is when the mark is removed, the zero value never expires. Expiry applies to the players
+	// entry as a whole, so marking an already marked player keeps whichever expiry lasts the longest.
+	ExpiresOn time.Time
+	// Transient marks are only kept in memory until ClearTransient is called and are never written
+	// to the local player list. ExpiresOn is ignored for transient marks.
+	Transient bool
 }
 
 func (e *Engine) FindNewestEntries(max int, validAttrs []string) steamid.Collection {
 	e.RLock()
 	defer e.RUnlock()
 	var matchers []steamIDMatcher
-	for _, m := range e.matchersSteam {
-		sm := m.(steamIDMatcher)
-		valid := false
-		for _, tag := range sm.attributes {
-			for _, okTags := range validAttrs {
-				if strings.EqualFold(tag, okTags) {
-					valid = true
-					break
+	for _, entries := range e.matchersSteam {
+		// Only include each steam id once, using the most recently seen entry
+		newest := -1
+		for idx, sm := range entries {
+			valid := false
+			for _, tag := range sm.attributes {
+				for _, okTags := range validAttrs {
+					if strings.EqualFold(tag, okTags) {
+						valid = true
+						break
+					}
 				}
 			}
+			if !valid {
+				continue
+			}
+			if newest == -1 || sm.lastSeen.Time > entries[newest].lastSeen.Time {
+				newest = idx
+			}
 		}
-		if !valid {
-			continue
+		if newest >= 0 {
+			matchers = append(matchers, entries[newest])
 		}
-		matchers = append(matchers, sm)
 	}
 	sort.Slice(matchers, func(i, j int) bool {
 		return matchers[i].lastSeen.Time > matchers[j].lastSeen.Time
@@ -115,14 +176,12 @@ func (e *Engine) Unmark(steamID steamid.SID64) bool {
 		players = append(players, knownPlayer)
 	}
 	e.playerLists[0].Players = players
-	// Remove the matcher from memory
-	var validMatchers []SteamIDMatcher
-	for _, matcher := range e.matchersSteam {
-		if match := matcher.Match(steamID); match == nil {
-			validMatchers = append(validMatchers, matcher)
-		}
+	if _, isTransient := e.transientMarks[steamID]; isTransient {
+		delete(e.transientMarks, steamID)
+		found = true
 	}
-	e.matchersSteam = validMatchers
+	// Remove the matchers from memory
+	e.matchersSteam.remove(steamID)
 	return found
 }
 
@@ -131,6 +190,14 @@ func (e *Engine) Mark(opts MarkOpts) error {
 		return errors.New("Invalid attribute count")
 	}
 	e.Lock()
+	defer e.Unlock()
+	if opts.Transient {
+		return e.markTransient(opts)
+	}
+	expiresOn := 0
+	if !opts.ExpiresOn.IsZero() {
+		expiresOn = int(opts.ExpiresOn.Unix())
+	}
 	updatedAttributes := false
 	for idx, knownPlayer := range e.playerLists[0].Players {
 		knownSid64, errSid64 := steamid.StringToSID64(knownPlayer.SteamID)
@@ -138,45 +205,152 @@ func (e *Engine) Mark(opts MarkOpts) error {
 			continue
 		}
 		if knownSid64 == opts.SteamID {
-			var newAttr []string
-			for _, updatedAttr := range opts.Attributes {
-				isNew := true
-				for _, existingAttr := range knownPlayer.Attributes {
-					if strings.EqualFold(updatedAttr, existingAttr) {
-						isNew = false
-						break
-					}
-				}
-				if isNew {
-					newAttr = append(newAttr, updatedAttr)
-				}
-			}
-			if len(newAttr) == 0 {
-				e.Unlock()
-				return errDuplicateSteamID
+			newAttr := newAttributes(knownPlayer.Attributes, opts.Attributes)
+			newExpiresOn := longestExpiry(knownPlayer.ExpiresOn, expiresOn)
+			if len(newAttr) == 0 && newExpiresOn == knownPlayer.ExpiresOn {
+				return ErrDuplicateSteamID
 			}
 			e.playerLists[0].Players[idx].Attributes = append(e.playerLists[0].Players[idx].Attributes, newAttr...)
+			e.playerLists[0].Players[idx].ExpiresOn = newExpiresOn
+			e.matchersSteam.add(newLocalSteamIDMatcher(opts.SteamID, e.playerLists[0].Players[idx]))
 			updatedAttributes = true
 		}
 	}
 	if !updatedAttributes {
-		e.playerLists[0].Players = append(e.playerLists[0].Players, playerDefinition{
-			Attributes: opts.Attributes,
-			LastSeen: playerLastSeen{
-				Time:       int(time.Now().Unix()),
-				PlayerName: opts.Name,
-			},
-			SteamID: opts.SteamID.String(),
-			Proof:   opts.Proof,
-		})
+		definition := newMarkDefinition(opts)
+		definition.ExpiresOn = expiresOn
+		e.playerLists[0].Players = append(e.playerLists[0].Players, definition)
+		e.matchersSteam.add(newLocalSteamIDMatcher(opts.SteamID, definition))
 	}
-	e.Unlock()
-	if !updatedAttributes {
-		e.registerSteamIDMatcher(newSteamIDMatcher(LocalRuleName, opts.SteamID, opts.Attributes))
+	return nil
+}
+
+// markTransient adds the attributes to the in memory mark of the player. The caller must hold the lock.
+func (e *Engine) markTransient(opts MarkOpts) error {
+	definition, found := e.transientMarks[opts.SteamID]
+	if !found {
+		definition = newMarkDefinition(opts)
+		definition.Attributes = nil
 	}
+	newAttr := newAttributes(definition.Attributes, opts.Attributes)
+	if len(newAttr) == 0 {
+		return ErrDuplicateSteamID
+	}
+	// Copy the attributes so the slice is never shared with previously created matchers
+	definition.Attributes = append(append([]string{}, definition.Attributes...), newAttr...)
+	e.transientMarks[opts.SteamID] = definition
+	matcher := newLocalSteamIDMatcher(opts.SteamID, definition)
+	matcher.source = transientSource
+	e.matchersSteam.add(matcher)
 	return nil
 }
 
+// ClearTransient removes all transient marks, returning the number of players which were unmarked
+func (e *Engine) ClearTransient() int {
+	e.Lock()
+	defer e.Unlock()
+	count := len(e.transientMarks)
+	for steamID := range e.transientMarks {
+		e.matchersSteam.removeList(steamID, LocalRuleName, transientSource)
+	}
+	e.transientMarks = map[steamid.SID64]playerDefinition{}
+	return count
+}
+
+// SweepExpired removes the entries of all player lists which have expired along with their matchers,
+// returning the number of entries removed.
+func (e *Engine) SweepExpired(now time.Time) int {
+	e.Lock()
+	defer e.Unlock()
+	removed := sweepExpiredLists(e.playerLists, e.matchersSteam, now)
+	if removed > 0 {
+		e.updateKnownTags()
+	}
+	return removed + sweepExpiredLists(e.whitelists, e.matchersWhitelist, now)
+}
+
+// sweepExpiredLists removes the expired entries of the lists and their matchers from the index
+func sweepExpiredLists(lists []*PlayerListSchema, index steamIDIndex, now time.Time) int {
+	removed := 0
+	for _, list := range lists {
+		active := activePlayers(list.Players, now)
+		if len(active) == len(list.Players) {
+			continue
+		}
+		for _, player := range list.Players {
+			if !player.expired(now) {
+				continue
+			}
+			if steamID, errSid := steamid.StringToSID64(player.SteamID); errSid == nil {
+				index.removeList(steamID, list.FileInfo.Title, list.SourceURL)
+			}
+		}
+		removed += len(list.Players) - len(active)
+		list.Players = active
+	}
+	return removed
+}
+
+// activePlayers returns the players which have not expired
+func activePlayers(players []playerDefinition, now time.Time) []playerDefinition {
+	// Prevents json encoder outputting `null` value instead of empty array `[]`
+	active := []playerDefinition{}
+	for _, player := range players {
+		if !player.expired(now) {
+			active = append(active, player)
+		}
+	}
+	return active
+}
+
+func newMarkDefinition(opts MarkOpts) playerDefinition {
+	return playerDefinition{
+		Attributes: opts.Attributes,
+		LastSeen: playerLastSeen{
+			Time:       int(time.Now().Unix()),
+			PlayerName: opts.Name,
+		},
+		SteamID: opts.SteamID.String(),
+		Proof:   opts.Proof,
+	}
+}
+
+// newAttributes returns the attributes from updated which are not already in existing
+func newAttributes(existing []string, updated []string) []string {
+	var newAttr []string
+	for _, updatedAttr := range updated {
+		isNew := true
+		for _, existingAttr := range existing {
+			if strings.EqualFold(updatedAttr, existingAttr) {
+				isNew = false
+				break
+			}
+		}
+		if isNew {
+			newAttr = append(newAttr, updatedAttr)
+		}
+	}
+	return newAttr
+}
+
+// longestExpiry returns the expiry which lasts the longest, where zero never expires
+func longestExpiry(a int, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func newLocalSteamIDMatcher(sid64 steamid.SID64, definition playerDefinition) steamIDMatcher {
+	matcher := newSteamIDMatcher(LocalRuleName, sid64, definition.Attributes)
+	matcher.lastSeen = definition.LastSeen
+	matcher.expiresOn = definition.ExpiresOn
+	return matcher
+}
+
 // UniqueTags returns a list of the unique known tags across all player lists
 func (e *Engine) UniqueTags() []string {
 	e.RLock()
@@ -196,7 +370,7 @@ func (e *Engine) ExportPlayers(listName string, w io.Writer) error {
 	defer e.RUnlock()
 	for _, pl := range e.playerLists {
 		if listName == pl.FileInfo.Title {
-			return newJSONPrettyEncoder(w).Encode(pl)
+			return newJSONPrettyEncoder(w).Encode(pl.exportCopy())
 		}
 	}
 	return errors.Errorf("Unknown player list: %s", listName)
@@ -208,163 +382,441 @@ func (e *Engine) ExportRules(listName string, w io.Writer) error {
 	defer e.RUnlock()
 	for _, pl := range e.rulesLists {
 		if listName == pl.FileInfo.Title {
-			return newJSONPrettyEncoder(w).Encode(pl)
+			return newJSONPrettyEncoder(w).Encode(pl.exportCopy())
 		}
 	}
 	return errors.Errorf("Unknown rule list: %s", listName)
 }
 
-// ImportRules loads the provided ruleset for use
+// ImportRules loads the provided ruleset for use. Each rule is registered as a single matcher
+// so that its triggers are evaluated together according to the rules trigger mode.
+//
+// Lists are identified by their title and source url. Importing a list which is already loaded
+// replaces the existing list and its matchers.
+//
+// Rules which fail to load are skipped and reported using RuleErrors, the returned count reflects
+// the rules that were successfully loaded.
 func (e *Engine) ImportRules(list *RuleSchema) (int, error) {
-	count := 0
-	for _, rule := range list.Rules {
-		if rule.Triggers.UsernameTextMatch != nil {
-			attrs := rule.Triggers.UsernameTextMatch.Attributes
-			if len(attrs) == 0 {
-				attrs = append(attrs, "trigger_name")
-			}
-			e.registerTextMatcher(newGeneralTextMatcher(
-				list.FileInfo.Title,
-				textMatchTypeName,
-				rule.Triggers.UsernameTextMatch.Mode,
-				rule.Triggers.UsernameTextMatch.CaseSensitive,
-				attrs,
-				rule.Triggers.UsernameTextMatch.Patterns...))
-			count++
-		}
-
-		if rule.Triggers.ChatMsgTextMatch != nil {
-			attrs := rule.Triggers.ChatMsgTextMatch.Attributes
-			if len(attrs) == 0 {
-				attrs = append(attrs, "trigger_msg")
+	var (
+		matchers []ruleMatcher
+		ruleErrs RuleErrors
+	)
+	for ruleIdx, rule := range list.Rules {
+		matcher, errMatcher := newRuleMatcherFromDefinition(list.FileInfo.Title, rule)
+		if errMatcher != nil {
+			ruleErrs = append(ruleErrs, RuleError{List: list.FileInfo.Title, Index: ruleIdx, Err: errMatcher})
+			continue
+		}
+		if matcher.triggerCount() == 0 {
+			continue
+		}
+		matcher.source = list.SourceURL
+		matcher.updateURL = list.FileInfo.UpdateURL
+		matchers = append(matchers, matcher)
+	}
+	e.Lock()
+	e.removeRuleMatchers(func(matcher ruleMatcher) bool {
+		return sameList(matcher.origin, matcher.source, list.FileInfo.Title, list.SourceURL)
+	})
+	e.matchersRule = append(e.matchersRule, matchers...)
+	e.textIndex = newTextIndex(e.matchersRule)
+	if listIdx := e.rulesListIndex(list.FileInfo.Title, list.SourceURL); listIdx >= 0 {
+		e.rulesLists[listIdx] = list
+	} else {
+		e.rulesLists = append(e.rulesLists, list)
+	}
+	e.Unlock()
+	if len(ruleErrs) > 0 {
+		return len(matchers), ruleErrs
+	}
+	return len(matchers), nil
+}
+
+// removeRuleMatchers removes all the rule matchers that the predicate returns true for. The caller
+// is responsible for locking and rebuilding the text index.
+func (e *Engine) removeRuleMatchers(predicate func(matcher ruleMatcher) bool) {
+	var remaining []ruleMatcher
+	for _, matcher := range e.matchersRule {
+		if !predicate(matcher) {
+			remaining = append(remaining, matcher)
+		}
+	}
+	e.matchersRule = remaining
+}
+
+// sameList returns true if both titles and sources identify the same list. Lists loaded from a source are
+// identified by it alone, so that a list whose title changed replaces the copy loaded under the previous title.
+func sameList(title string, source string, otherTitle string, otherSource string) bool {
+	if source != "" {
+		return source == otherSource
+	}
+	return otherSource == "" && title == otherTitle
+}
+
+func (e *Engine) rulesListIndex(title string, source string) int {
+	for idx, list := range e.rulesLists {
+		if sameList(list.FileInfo.Title, list.SourceURL, title, source) {
+			return idx
+		}
+	}
+	return -1
+}
+
+func findPlayerList(lists []*PlayerListSchema, title string, source string) int {
+	for idx, list := range lists {
+		if sameList(list.FileInfo.Title, list.SourceURL, title, source) {
+			return idx
+		}
+	}
+	return -1
+}
+
+func newRuleMatcherFromDefinition(origin string, rule ruleDefinition) (ruleMatcher, error) {
+	matcher, errMatcher := newRuleMatcher(rule.Description, rule.Triggers.Mode)
+	if errMatcher != nil {
+		return ruleMatcher{}, errMatcher
+	}
+	matcher.origin = origin
+	matcher.transientMark = rule.Actions.TransientMark
+	if rule.Triggers.UsernameTextMatch != nil {
+		attrs := rule.Triggers.UsernameTextMatch.Attributes
+		if len(attrs) == 0 {
+			attrs = append(attrs, "trigger_name")
+		}
+		newMatcher := newTextMatcher
+		if rule.Triggers.UsernameTextMatch.Normalise {
+			newMatcher = newNormalisedTextMatcher
+		}
+		textMatcher, errText := newMatcher(
+			origin,
+			textMatchTypeName,
+			rule.Triggers.UsernameTextMatch.Mode,
+			rule.Triggers.UsernameTextMatch.CaseSensitive,
+			attrs,
+			rule.Triggers.UsernameTextMatch.Patterns...)
+		if errText != nil {
+			return ruleMatcher{}, errors.Wrap(errText, "Invalid username trigger")
+		}
+		matcher.text = append(matcher.text, textMatcher)
+	}
+	if rule.Triggers.ChatMsgTextMatch != nil {
+		attrs := rule.Triggers.ChatMsgTextMatch.Attributes
+		if len(attrs) == 0 {
+			attrs = append(attrs, "trigger_msg")
+		}
+		newMatcher := newTextMatcher
+		if rule.Triggers.ChatMsgTextMatch.Normalise {
+			newMatcher = newNormalisedTextMatcher
+		}
+		textMatcher, errText := newMatcher(
+			origin,
+			textMatchTypeMessage,
+			rule.Triggers.ChatMsgTextMatch.Mode,
+			rule.Triggers.ChatMsgTextMatch.CaseSensitive,
+			attrs,
+			rule.Triggers.ChatMsgTextMatch.Patterns...)
+		if errText != nil {
+			return ruleMatcher{}, errors.Wrap(errText, "Invalid chat message trigger")
+		}
+		matcher.text = append(matcher.text, textMatcher)
+	}
+	if len(rule.Triggers.AvatarMatch) > 0 {
+		var (
+			hashes           []string
+			perceptualHashes []perceptualHash
+		)
+		for _, h := range rule.Triggers.AvatarMatch {
+			if len(h.AvatarHash) == 40 {
+				hashes = append(hashes, h.AvatarHash)
 			}
-			e.registerTextMatcher(newGeneralTextMatcher(
-				list.FileInfo.Title,
-				textMatchTypeMessage,
-				rule.Triggers.ChatMsgTextMatch.Mode,
-				rule.Triggers.ChatMsgTextMatch.CaseSensitive,
-				attrs,
-				rule.Triggers.ChatMsgTextMatch.Patterns...))
-			count++
-		}
-		if len(rule.Triggers.AvatarMatch) > 0 {
-			var hashes []string
-			for _, h := range rule.Triggers.AvatarMatch {
-				if len(h.AvatarHash) != 40 {
-					continue
+			if h.PerceptualHash != "" {
+				hash, errHash := parsePerceptualHash(h.PerceptualHash)
+				if errHash != nil {
+					return ruleMatcher{}, errors.Wrap(errHash, "Invalid avatar trigger")
 				}
-				hashes = append(hashes, h.AvatarHash)
+				maxDistance := DefaultPerceptualDistance
+				if h.MaxDistance != nil {
+					maxDistance = *h.MaxDistance
+				}
+				if maxDistance < 0 || maxDistance > maxPerceptualDistance {
+					return ruleMatcher{}, errors.Errorf("Invalid avatar trigger max distance: %d", maxDistance)
+				}
+				perceptualHashes = append(perceptualHashes, perceptualHash{hash: hash, maxDistance: maxDistance})
 			}
-			e.registerAvatarMatcher(newAvatarMatcher(
-				list.FileInfo.Title,
+		}
+		if len(hashes) > 0 {
+			matcher.avatar = append(matcher.avatar, newAvatarMatcher(
+				origin,
 				avatarMatchExact,
+				[]string{"trigger_avatar"},
 				hashes...))
-			count++
+		}
+		if len(perceptualHashes) > 0 {
+			matcher.avatar = append(matcher.avatar, newPerceptualAvatarMatcher(
+				origin,
+				[]string{"trigger_avatar"},
+				perceptualHashes...))
 		}
 	}
-	e.rulesLists = append(e.rulesLists, list)
-	return count, nil
+	if rule.Triggers.ProfileMatch != nil {
+		attrs := rule.Triggers.ProfileMatch.Attributes
+		if len(attrs) == 0 {
+			attrs = append(attrs, "trigger_profile")
+		}
+		profile, errProfile := newProfileMatcher(origin, *rule.Triggers.ProfileMatch, attrs)
+		if errProfile != nil {
+			return ruleMatcher{}, errors.Wrap(errProfile, "Invalid profile trigger")
+		}
+		matcher.profile = append(matcher.profile, profile)
+	}
+	return matcher, nil
 }
 
-// ImportPlayers loads the provided player list for matching
+// ImportPlayers loads the provided player list for matching. Importing a list which is already loaded
+// replaces the existing list and its matchers. Entries which have expired are removed from the list.
 func (e *Engine) ImportPlayers(list *PlayerListSchema) (int, error) {
-	var playerAttrs []string
-	var count int
-	for _, player := range list.Players {
+	matchers, players, errMatchers := newPlayerListMatchers(list)
+	if errMatchers != nil {
+		return 0, errMatchers
+	}
+	e.Lock()
+	e.playerLists = replacePlayerList(e.playerLists, e.matchersSteam, list, players, matchers)
+	e.updateKnownTags()
+	e.Unlock()
+	return len(matchers), nil
+}
+
+// newPlayerListMatchers creates the steam id matchers for the entries of the list which have not expired
+func newPlayerListMatchers(list *PlayerListSchema) ([]steamIDMatcher, []playerDefinition, error) {
+	var matchers []steamIDMatcher
+	players := activePlayers(list.Players, time.Now())
+	for _, player := range players {
 		steamID, errSid := steamid.StringToSID64(player.SteamID)
 		if errSid != nil {
-			return 0, errors.Wrap(errSid, "Failed to parse steamid")
+			return nil, nil, errors.Wrap(errSid, "Failed to parse steamid")
 		}
 		if !steamID.Valid() {
-			return 0, errors.Errorf("Received malformed steamid: %v", steamID)
+			return nil, nil, errors.Errorf("Received malformed steamid: %v", steamID)
 		}
-		e.registerSteamIDMatcher(newSteamIDMatcher(list.FileInfo.Title, steamID, player.Attributes))
-		playerAttrs = append(playerAttrs, player.Attributes...)
-		count++
+		matcher := newSteamIDMatcher(list.FileInfo.Title, steamID, player.Attributes)
+		matcher.source = list.SourceURL
+		matcher.updateURL = list.FileInfo.UpdateURL
+		matcher.lastSeen = player.LastSeen
+		matcher.expiresOn = player.ExpiresOn
+		matchers = append(matchers, matcher)
 	}
-	e.Lock()
-	for _, newTag := range playerAttrs {
-		found := false
-		for _, known := range e.knownTags {
-			if strings.EqualFold(newTag, known) {
-				found = true
-				break
+	return matchers, players, nil
+}
+
+// replacePlayerList adds the list to lists, replacing any already loaded copy of the list along with its
+// matchers in the index. The caller must hold the lock.
+func replacePlayerList(lists []*PlayerListSchema, index steamIDIndex, list *PlayerListSchema,
+	players []playerDefinition, matchers []steamIDMatcher) []*PlayerListSchema {
+	if listIdx := findPlayerList(lists, list.FileInfo.Title, list.SourceURL); listIdx >= 0 {
+		removePlayerMatchers(index, lists[listIdx])
+		list.Players = players
+		lists[listIdx] = list
+	} else {
+		list.Players = players
+		lists = append(lists, list)
+	}
+	for _, matcher := range matchers {
+		index.add(matcher)
+	}
+	return lists
+}
+
+// removePlayerMatchers removes the steam id matchers which were created from the list
+func removePlayerMatchers(index steamIDIndex, list *PlayerListSchema) {
+	for _, player := range list.Players {
+		steamID, errSid := steamid.StringToSID64(player.SteamID)
+		if errSid != nil {
+			continue
+		}
+		index.removeList(steamID, list.FileInfo.Title, list.SourceURL)
+	}
+}
+
+// removePlayerLists removes the lists loaded from the source url along with their matchers
+func removePlayerLists(lists []*PlayerListSchema, index steamIDIndex, source string) ([]*PlayerListSchema, bool) {
+	found := false
+	var remaining []*PlayerListSchema
+	for _, list := range lists {
+		if list.SourceURL == source {
+			removePlayerMatchers(index, list)
+			found = true
+			continue
+		}
+		remaining = append(remaining, list)
+	}
+	return remaining, found
+}
+
+// updateKnownTags rebuilds the unique tags across all loaded player lists
+func (e *Engine) updateKnownTags() {
+	var knownTags []string
+	for _, list := range e.playerLists {
+		for _, player := range list.Players {
+			knownTags = mergeAttributes(knownTags, player.Attributes...)
+		}
+	}
+	e.knownTags = knownTags
+}
+
+// IsLoaded returns true if any rules, player lists or whitelists loaded from the source url are in use
+func (e *Engine) IsLoaded(source string) bool {
+	e.RLock()
+	defer e.RUnlock()
+	for _, lists := range [][]*PlayerListSchema{e.playerLists, e.whitelists} {
+		for _, list := range lists {
+			if list.SourceURL == source {
+				return true
 			}
 		}
-		if !found {
-			e.knownTags = append(e.knownTags, newTag)
+	}
+	for _, list := range e.rulesLists {
+		if list.SourceURL == source {
+			return true
 		}
 	}
-	e.playerLists = append(e.playerLists, list)
-	e.Unlock()
-	return count, nil
+	return false
 }
 
-func (e *Engine) registerSteamIDMatcher(matcher SteamIDMatcher) {
+// RemoveList unloads all the rules, player lists and whitelists, along with their matchers, which were loaded
+// from the source url. Returns true if any lists were removed.
+func (e *Engine) RemoveList(source string) bool {
+	if source == "" {
+		// Local lists have no source and cannot be removed
+		return false
+	}
 	e.Lock()
-	e.matchersSteam = append(e.matchersSteam, matcher)
-	e.Unlock()
+	defer e.Unlock()
+	var foundPlayers, foundWhitelist bool
+	e.playerLists, foundPlayers = removePlayerLists(e.playerLists, e.matchersSteam, source)
+	e.whitelists, foundWhitelist = removePlayerLists(e.whitelists, e.matchersWhitelist, source)
+	found := foundPlayers || foundWhitelist
+	var rulesLists []*RuleSchema
+	for _, list := range e.rulesLists {
+		if list.SourceURL == source {
+			found = true
+			continue
+		}
+		rulesLists = append(rulesLists, list)
+	}
+	e.rulesLists = rulesLists
+	if found {
+		e.removeRuleMatchers(func(matcher ruleMatcher) bool {
+			return matcher.source == source
+		})
+		e.textIndex = newTextIndex(e.matchersRule)
+		e.updateKnownTags()
+	}
+	return found
 }
 
-func (e *Engine) registerAvatarMatcher(matcher AvatarMatcher) {
+func (e *Engine) registerSteamIDMatcher(matcher steamIDMatcher) {
 	e.Lock()
-	e.matchersAvatar = append(e.matchersAvatar, matcher)
+	e.matchersSteam.add(matcher)
 	e.Unlock()
 }
 
-func (e *Engine) registerTextMatcher(matcher TextMatcher) {
+func (e *Engine) registerRuleMatcher(matcher ruleMatcher) {
 	e.Lock()
-	e.matchersText = append(e.matchersText, matcher)
+	e.matchersRule = append(e.matchersRule, matcher)
+	e.textIndex = newTextIndex(e.matchersRule)
 	e.Unlock()
 }
 
-func (e *Engine) matchTextType(text string, matchType textMatchType) *MatchResult {
-	for _, matcher := range e.matchersText {
-		if matcher.Type() != textMatchTypeAny && matcher.Type() != matchType {
-			continue
-		}
-		match := matcher.Match(text)
-		if match != nil {
-			return match
-		}
+// registerAvatarMatcher registers a standalone avatar matcher as a rule with a single trigger
+func (e *Engine) registerAvatarMatcher(matcher AvatarMatcher) {
+	e.registerRuleMatcher(ruleMatcher{mode: modeTrigMatchAny, avatar: []AvatarMatcher{matcher}})
+}
+
+// registerTextMatcher registers a standalone text matcher as a rule with a single trigger
+func (e *Engine) registerTextMatcher(matcher TextMatcher) {
+	e.registerRuleMatcher(ruleMatcher{mode: modeTrigMatchAny, text: []TextMatcher{matcher}})
+}
+
+// MatchRules evaluates every known rule against the provided player state and returns the first
+// rule that fired.
+func (e *Engine) MatchRules(input MatchInput) *MatchResult {
+	if results := e.matchRules(input, true); len(results) > 0 {
+		return results[0]
 	}
 	return nil
 }
 
-func (e *Engine) MatchSteam(steamID steamid.SID64) *MatchResult {
-	for _, sm := range e.matchersSteam {
-		match := sm.Match(steamID)
-		if match != nil {
-			return match
+// MatchRulesAll evaluates every known rule against the provided player state and returns all
+// the rules that fired.
+func (e *Engine) MatchRulesAll(input MatchInput) MatchResults {
+	return e.matchRules(input, false)
+}
+
+func (e *Engine) matchRules(input MatchInput, first bool) MatchResults {
+	e.RLock()
+	defer e.RUnlock()
+	hits := e.textIndex.match(input)
+	var results MatchResults
+	for ruleIdx, matcher := range e.matchersRule {
+		if match := matcher.match(input, hits, e.textIndex.ids[ruleIdx]); match != nil {
+			results = append(results, match)
+			if first {
+				break
+			}
 		}
 	}
+	return results
+}
+
+func (e *Engine) MatchSteam(steamID steamid.SID64) *MatchResult {
+	if results := e.MatchSteamAll(steamID); len(results) > 0 {
+		return results[0]
+	}
 	return nil
 }
 
+// MatchSteamAll returns a match for every player list entry of the steam id
+func (e *Engine) MatchSteamAll(steamID steamid.SID64) MatchResults {
+	e.RLock()
+	defer e.RUnlock()
+	return e.matchersSteam.match(steamID)
+}
+
+// MatchPlayer returns all the matches for a player across every list and matcher type
+func (e *Engine) MatchPlayer(steamID steamid.SID64, input MatchInput) MatchResults {
+	return append(e.MatchSteamAll(steamID), e.MatchRulesAll(input)...)
+}
+
 func (e *Engine) MatchName(name string) *MatchResult {
-	return e.matchTextType(name, textMatchTypeName)
+	return e.MatchRules(MatchInput{Name: name})
 }
 
 func (e *Engine) MatchMessage(text string) *MatchResult {
-	return e.matchTextType(text, textMatchTypeMessage)
+	return e.MatchRules(MatchInput{Message: text})
 }
 
-//func (e *Engine) matchAny(text string) *MatchResult {
-//	return e.matchTextType(text, textMatchTypeAny)
-//}
+// MatchChat evaluates the rules against the state of a player along with a chat message they sent, so that rules
+// combining chat triggers with name, avatar or profile triggers can fire. Only rules where a chat trigger matched
+// are returned, rules firing on the player state alone are left to MatchPlayer.
+func (e *Engine) MatchChat(input MatchInput) MatchResults {
+	var results MatchResults
+	for _, match := range e.MatchRulesAll(input) {
+		if match.IsMessageMatch() {
+			results = append(results, match)
+		}
+	}
+	return results
+}
 
 func (e *Engine) matchAvatar(avatar []byte) *MatchResult {
 	if avatar == nil {
 		return nil
 	}
-	hexDigest := HashBytes(avatar)
-	for _, matcher := range e.matchersAvatar {
-		match := matcher.Match(hexDigest)
-		if match != nil {
-			return match
-		}
+	input := MatchInput{AvatarHash: HashBytes(avatar)}
+	if perceptual, errHash := PerceptualHash(avatar); errHash == nil {
+		input.AvatarPerceptualHash = perceptual
 	}
-	return nil
+	return e.MatchRules(input)
 }
 
 func HashBytes(b []byte) string {
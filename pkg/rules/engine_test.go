@@ -3,10 +3,16 @@ package rules
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/leighmacdonald/steamid/v2/steamid"
 	"github.com/stretchr/testify/require"
 	"image"
+	"image/color"
 	"image/jpeg"
+	"sort"
 	"testing"
+	"time"
 )
 
 func genTestRules() RuleSchema {
@@ -94,7 +100,7 @@ const customListTitle = "Custom List"
 
 func TestSteamRules(t *testing.T) {
 	const testSteamID = 76561197961279983
-	re, _ := New(nil, nil)
+	re, _ := New(nil, nil, nil)
 	re.registerSteamIDMatcher(newSteamIDMatcher(customListTitle, testSteamID, []string{"test_attr"}))
 	steamMatch := re.MatchSteam(testSteamID)
 	require.NotNil(t, steamMatch, "Failed to match steamid")
@@ -103,7 +109,7 @@ func TestSteamRules(t *testing.T) {
 }
 
 func TestTextRules(t *testing.T) {
-	re, reErr := New(nil, nil)
+	re, reErr := New(nil, nil, nil)
 	require.NoError(t, reErr)
 	tr := genTestRules()
 	_, errImport := re.ImportRules(&tr)
@@ -111,11 +117,11 @@ func TestTextRules(t *testing.T) {
 	testAttrs := []string{"test_attr"}
 	re.registerTextMatcher(newGeneralTextMatcher(customListTitle, textMatchTypeName, textMatchModeContains, false, testAttrs, "test", "blah"))
 
-	rm, eRm := newRegexTextMatcher(customListTitle, textMatchTypeMessage, testAttrs, `^test.+?`)
+	rm, eRm := newRegexTextMatcher(customListTitle, textMatchTypeMessage, true, testAttrs, `^test.+?`)
 	require.NoError(t, eRm)
 	re.registerTextMatcher(rm)
 
-	_, badRegex := newRegexTextMatcher(customListTitle, textMatchTypeName, testAttrs, `^t\s\x\t`)
+	_, badRegex := newRegexTextMatcher(customListTitle, textMatchTypeName, true, testAttrs, `^t\s\x\t`)
 	require.Error(t, badRegex)
 
 	testCases := []struct {
@@ -149,10 +155,853 @@ func TestAvatarRules(t *testing.T) {
 	var buf bytes.Buffer
 	testAvatar := image.NewRGBA(image.Rect(0, 0, 50, 50))
 	require.NoError(t, jpeg.Encode(bufio.NewWriter(&buf), testAvatar, &jpeg.Options{Quality: 10}))
-	re, reErr := New(nil, nil)
+	re, reErr := New(nil, nil, nil)
 	require.NoError(t, reErr)
-	re.registerAvatarMatcher(newAvatarMatcher(listName, avatarMatchExact, HashBytes(buf.Bytes())))
+	re.registerAvatarMatcher(newAvatarMatcher(listName, avatarMatchExact, []string{"test_attr"}, HashBytes(buf.Bytes())))
 	result := re.matchAvatar(buf.Bytes())
 	require.NotNil(t, result)
 	require.Equal(t, listName, result.Origin)
 }
+
+func genTestAvatar(t *testing.T, quality int, pixel func(x, y int) uint8) []byte {
+	t.Helper()
+	avatar := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			avatar.SetGray(x, y, color.Gray{Y: pixel(x, y)})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, avatar, &jpeg.Options{Quality: quality}))
+	return buf.Bytes()
+}
+
+func TestPerceptualAvatarRules(t *testing.T) {
+	checkered := func(x, y int) uint8 {
+		return uint8((x/8+y/8)%2*160 + x)
+	}
+	original := genTestAvatar(t, 95, checkered)
+	reEncoded := genTestAvatar(t, 30, checkered)
+	altered := genTestAvatar(t, 95, func(x, y int) uint8 {
+		if x < 12 && y < 12 {
+			return 255
+		}
+		return checkered(x, y) + 10
+	})
+	unrelated := genTestAvatar(t, 95, func(x, y int) uint8 {
+		return uint8(255 - y*4)
+	})
+	require.NotEqual(t, HashBytes(original), HashBytes(reEncoded))
+
+	hash, errHash := PerceptualHash(original)
+	require.NoError(t, errHash)
+	require.Len(t, hash, 16)
+	_, errInvalid := PerceptualHash([]byte("not an image"))
+	require.Error(t, errInvalid)
+
+	ruleList := genTestRules()
+	ruleList.Rules = []ruleDefinition{{
+		Description: "bot avatar",
+		Actions:     ruleActions{Mark: []string{"bot"}},
+		Triggers: ruleTriggers{
+			AvatarMatch: []ruleTriggerAvatarMatch{{PerceptualHash: hash}},
+		},
+	}}
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	_, errImport := re.ImportRules(&ruleList)
+	require.NoError(t, errImport)
+
+	for _, avatar := range [][]byte{original, reEncoded, altered} {
+		result := re.matchAvatar(avatar)
+		require.NotNil(t, result)
+		require.Equal(t, "bot avatar", result.Rule)
+	}
+	require.Nil(t, re.matchAvatar(unrelated))
+
+	exact := 0
+	ruleList.Rules[0].Triggers.AvatarMatch[0].MaxDistance = &exact
+	_, errReimport := re.ImportRules(&ruleList)
+	require.NoError(t, errReimport)
+	require.NotNil(t, re.matchAvatar(original))
+	require.Nil(t, re.matchAvatar(altered))
+
+	invalid := maxPerceptualDistance + 1
+	ruleList.Rules[0].Triggers.AvatarMatch[0].MaxDistance = &invalid
+	_, errDistance := re.ImportRules(&ruleList)
+	require.Error(t, errDistance)
+}
+
+func TestProfileRules(t *testing.T) {
+	days := func(n int) *int {
+		return &n
+	}
+	daysAgo := func(n int) time.Time {
+		return time.Now().AddDate(0, 0, -n)
+	}
+	ruleList := genTestRules()
+	ruleList.Rules = []ruleDefinition{
+		{
+			Description: "fresh account",
+			Triggers: ruleTriggers{
+				ProfileMatch: &ruleTriggerProfileMatch{AccountAgeMaxDays: days(30)},
+			},
+		},
+		{
+			Description: "recently banned",
+			Triggers: ruleTriggers{
+				ProfileMatch: &ruleTriggerProfileMatch{VACBansMin: days(2), LastBanMaxDays: days(90), Attributes: []string{"banned"}},
+			},
+		},
+		{
+			Description: "private bot",
+			Triggers: ruleTriggers{
+				Mode:         modeTrigMatchAll,
+				ProfileMatch: &ruleTriggerProfileMatch{PrivateProfile: true},
+				UsernameTextMatch: &ruleTriggerNameMatch{
+					Mode:     textMatchModeContains,
+					Patterns: []string{"bot"},
+				},
+			},
+		},
+		{
+			Description: "no conditions",
+			Triggers:    ruleTriggers{ProfileMatch: &ruleTriggerProfileMatch{}},
+		},
+	}
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	count, errImport := re.ImportRules(&ruleList)
+	require.Error(t, errImport)
+	require.Equal(t, 3, count)
+
+	lastBan := daysAgo(10)
+	oldBan := daysAgo(365)
+	testCases := []struct {
+		input MatchInput
+		rules []string
+	}{
+		{input: MatchInput{Name: "bot"}},
+		{input: MatchInput{Profile: &ProfileInput{AccountCreatedOn: daysAgo(5)}}, rules: []string{"fresh account"}},
+		{input: MatchInput{Profile: &ProfileInput{AccountCreatedOn: daysAgo(500)}}},
+		{input: MatchInput{Profile: &ProfileInput{}}},
+		{input: MatchInput{Profile: &ProfileInput{AccountCreatedOn: daysAgo(500), VACBans: 2, LastBanOn: &lastBan}}, rules: []string{"recently banned"}},
+		{input: MatchInput{Profile: &ProfileInput{AccountCreatedOn: daysAgo(500), VACBans: 1, LastBanOn: &lastBan}}},
+		{input: MatchInput{Profile: &ProfileInput{AccountCreatedOn: daysAgo(500), VACBans: 3, LastBanOn: &oldBan}}},
+		{input: MatchInput{Name: "bot", Profile: &ProfileInput{Private: true}}, rules: []string{"private bot"}},
+		{input: MatchInput{Name: "player", Profile: &ProfileInput{Private: true}}},
+	}
+	for num, tc := range testCases {
+		var matched []string
+		for _, match := range re.MatchRulesAll(tc.input) {
+			matched = append(matched, match.Rule)
+		}
+		require.Equal(t, tc.rules, matched, "Test %d failed", num)
+	}
+}
+
+func TestRuleTriggerModes(t *testing.T) {
+	const avatarHash = "fef49e7fa7e1997310d705b2a6158ff8dc1cdfeb"
+	ruleList := genTestRules()
+	ruleList.Rules = []ruleDefinition{
+		{
+			Description: "name and avatar",
+			Triggers: ruleTriggers{
+				Mode:        modeTrigMatchAll,
+				AvatarMatch: []ruleTriggerAvatarMatch{{AvatarHash: avatarHash}},
+				UsernameTextMatch: &ruleTriggerNameMatch{
+					Mode:     textMatchModeContains,
+					Patterns: []string{"all_name"},
+				},
+			},
+		},
+		{
+			Description: "name or message",
+			Triggers: ruleTriggers{
+				Mode: modeTrigMatchAny,
+				UsernameTextMatch: &ruleTriggerNameMatch{
+					Mode:     textMatchModeEqual,
+					Patterns: []string{"any_name"},
+				},
+				ChatMsgTextMatch: &ruleTriggerTextMatch{
+					Mode:     textMatchModeContains,
+					Patterns: []string{"any_message"},
+				},
+			},
+		},
+	}
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	count, errImport := re.ImportRules(&ruleList)
+	require.NoError(t, errImport)
+	require.Equal(t, 2, count)
+
+	require.Nil(t, re.MatchName("all_name"), "match_all rule fired with a single trigger")
+	require.Nil(t, re.MatchRules(MatchInput{Name: "all_name", AvatarHash: HashBytes([]byte("x"))}))
+	allMatch := re.MatchRules(MatchInput{Name: "all_name", AvatarHash: avatarHash})
+	require.NotNil(t, allMatch)
+	require.Equal(t, "name and avatar", allMatch.Rule)
+	require.Equal(t, matcherTypeRule, allMatch.MatcherType)
+	require.Equal(t, []string{"trigger_name", "trigger_avatar"}, allMatch.Attributes)
+
+	nameMatch := re.MatchName("any_name")
+	require.NotNil(t, nameMatch)
+	require.Equal(t, "name or message", nameMatch.Rule)
+	require.Equal(t, []string{"trigger_name"}, nameMatch.Attributes)
+	require.NotNil(t, re.MatchMessage("an any_message here"))
+
+	ruleList.Rules[0].Triggers.Mode = "match_some"
+	modeCount, errMode := re.ImportRules(&ruleList)
+	require.Error(t, errMode)
+	require.Equal(t, 1, modeCount)
+}
+
+func TestChatRules(t *testing.T) {
+	ruleList := genTestRules()
+	ruleList.Rules = []ruleDefinition{
+		{
+			Description: "name and message",
+			Triggers: ruleTriggers{
+				Mode: modeTrigMatchAll,
+				UsernameTextMatch: &ruleTriggerNameMatch{
+					Mode:     textMatchModeContains,
+					Patterns: []string{"all_name"},
+				},
+				ChatMsgTextMatch: &ruleTriggerTextMatch{
+					Mode:     textMatchModeContains,
+					Patterns: []string{"all_message"},
+				},
+			},
+		},
+		{
+			Description: "name only",
+			Triggers: ruleTriggers{
+				Mode: modeTrigMatchAny,
+				UsernameTextMatch: &ruleTriggerNameMatch{
+					Mode:     textMatchModeContains,
+					Patterns: []string{"all_name"},
+				},
+			},
+		},
+	}
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	count, errImport := re.ImportRules(&ruleList)
+	require.NoError(t, errImport)
+	require.Equal(t, 2, count)
+
+	require.Empty(t, re.MatchChat(MatchInput{Message: "an all_message here"}), "match_all rule fired without the name")
+	require.Empty(t, re.MatchChat(MatchInput{Name: "all_name", Message: "hello"}), "name only rule matched on chat")
+	matches := re.MatchChat(MatchInput{Name: "all_name", Message: "an all_message here"})
+	require.Equal(t, 1, len(matches))
+	require.Equal(t, "name and message", matches[0].Rule)
+	require.Equal(t, 2, len(matches[0].Triggers))
+	require.True(t, matches[0].IsMessageMatch())
+}
+
+func TestRegexRules(t *testing.T) {
+	ruleList := genTestRules()
+	ruleList.Rules = []ruleDefinition{
+		{
+			Description: "bad regex",
+			Triggers: ruleTriggers{
+				UsernameTextMatch: &ruleTriggerNameMatch{
+					Mode:     textMatchModeRegex,
+					Patterns: []string{`^t\s\x\t`},
+				},
+			},
+		},
+		{
+			Description: "regex ci",
+			Triggers: ruleTriggers{
+				UsernameTextMatch: &ruleTriggerNameMatch{
+					Mode:     textMatchModeRegex,
+					Patterns: []string{`^bot\d+$`},
+				},
+			},
+		},
+		{
+			Description: "regex cs",
+			Triggers: ruleTriggers{
+				ChatMsgTextMatch: &ruleTriggerTextMatch{
+					CaseSensitive: true,
+					Mode:          textMatchModeRegex,
+					Patterns:      []string{`^Free\s+hats`},
+				},
+			},
+		},
+	}
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	count, errImport := re.ImportRules(&ruleList)
+	require.Equal(t, 2, count)
+	var ruleErrs RuleErrors
+	require.ErrorAs(t, errImport, &ruleErrs)
+	require.Equal(t, 1, len(ruleErrs))
+	require.Equal(t, 0, ruleErrs[0].Index)
+	require.Equal(t, ruleList.FileInfo.Title, ruleErrs[0].List)
+
+	require.NotNil(t, re.MatchName("BOT1234"))
+	require.Nil(t, re.MatchName("not_bot1234"))
+	require.NotNil(t, re.MatchMessage("Free  hats at example.com"))
+	require.Nil(t, re.MatchMessage("free hats at example.com"))
+}
+
+func TestMatchPlayer(t *testing.T) {
+	const testSteamID = 76561197961279983
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	re.registerSteamIDMatcher(newSteamIDMatcher("list a", testSteamID, []string{"cheater"}))
+	re.registerSteamIDMatcher(newSteamIDMatcher("list b", testSteamID, []string{"bot", "Cheater"}))
+	re.registerSteamIDMatcher(newSteamIDMatcher("list c", testSteamID+1, []string{"racist"}))
+	re.registerTextMatcher(newGeneralTextMatcher("list c", textMatchTypeName, textMatchModeContains, false, []string{"trigger_name"}, "bot"))
+
+	matches := re.MatchPlayer(testSteamID, MatchInput{Name: "a bot name"})
+	require.Equal(t, 3, len(matches))
+	require.Equal(t, []string{"list a", "list b", "list c"}, matches.Origins())
+	require.Equal(t, []string{"cheater", "bot", "trigger_name"}, matches.Attributes())
+	require.Equal(t, 0, len(re.MatchPlayer(testSteamID+2, MatchInput{Name: "player"})))
+}
+
+func TestMarkSteamIndex(t *testing.T) {
+	const testSteamID = 76561197961279983
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	list := NewPlayerListSchema(playerDefinition{
+		Attributes: []string{"bot"},
+		LastSeen:   playerLastSeen{Time: 100},
+		SteamID:    steamid.SID64(testSteamID).String(),
+	})
+	list.FileInfo.Title = customListTitle
+	_, errImport := re.ImportPlayers(&list)
+	require.NoError(t, errImport)
+
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID, Attributes: []string{"cheater"}}))
+	require.ErrorIs(t, re.Mark(MarkOpts{SteamID: testSteamID, Attributes: []string{"cheater"}}), ErrDuplicateSteamID)
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID, Attributes: []string{"racist"}}))
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID + 1, Attributes: []string{"cheater"}}))
+
+	matches := re.MatchSteamAll(testSteamID)
+	require.Equal(t, 2, len(matches))
+	require.Equal(t, []string{customListTitle, LocalRuleName}, matches.Origins())
+	require.Equal(t, []string{"bot", "cheater", "racist"}, matches.Attributes())
+	require.Equal(t, steamid.Collection{testSteamID, testSteamID + 1}, sortedCollection(re.FindNewestEntries(10, []string{"cheater"})))
+	require.Equal(t, steamid.Collection{testSteamID}, re.FindNewestEntries(10, []string{"bot"}))
+
+	require.True(t, re.Unmark(testSteamID))
+	require.Nil(t, re.MatchSteam(testSteamID))
+	require.NotNil(t, re.MatchSteam(testSteamID+1))
+	require.Equal(t, steamid.Collection{testSteamID + 1}, re.FindNewestEntries(10, []string{"cheater"}))
+}
+
+func TestMarkExpiry(t *testing.T) {
+	const testSteamID = 76561197961279983
+	now := time.Now()
+	local := NewPlayerListSchema(
+		playerDefinition{Attributes: []string{"cheater"}, SteamID: steamid.SID64(testSteamID).String(), ExpiresOn: int(now.Add(-time.Hour).Unix())},
+		playerDefinition{Attributes: []string{"cheater"}, SteamID: steamid.SID64(testSteamID + 1).String(), ExpiresOn: int(now.Add(time.Hour).Unix())},
+	)
+	re, reErr := New(nil, &local, nil)
+	require.NoError(t, reErr)
+	// Expired entries are removed on load
+	require.Nil(t, re.MatchSteam(testSteamID))
+	require.NotNil(t, re.MatchSteam(testSteamID+1))
+	require.Len(t, re.playerLists[0].Players, 1)
+
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID, Attributes: []string{"suspicious"}, ExpiresOn: now.Add(-time.Second)}))
+	// Expired marks stop matching before they are swept
+	require.Nil(t, re.MatchSteam(testSteamID))
+	require.Equal(t, 1, re.SweepExpired(now))
+	require.Len(t, re.playerLists[0].Players, 1)
+	require.Equal(t, 0, re.SweepExpired(now))
+
+	// Re-marking keeps the longest expiry, with permanent marks never expiring
+	require.ErrorIs(t, re.Mark(MarkOpts{SteamID: testSteamID + 1, Attributes: []string{"cheater"}, ExpiresOn: now.Add(time.Minute)}), ErrDuplicateSteamID)
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID + 1, Attributes: []string{"cheater"}, ExpiresOn: now.Add(time.Hour * 2)}))
+	require.Equal(t, int(now.Add(time.Hour*2).Unix()), re.playerLists[0].Players[0].ExpiresOn)
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID + 1, Attributes: []string{"cheater"}}))
+	require.Equal(t, 0, re.playerLists[0].Players[0].ExpiresOn)
+	require.ErrorIs(t, re.Mark(MarkOpts{SteamID: testSteamID + 1, Attributes: []string{"cheater"}, ExpiresOn: now.Add(time.Hour * 3)}), ErrDuplicateSteamID)
+	require.Equal(t, 0, re.SweepExpired(now.Add(time.Hour*4)))
+
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID + 2, Attributes: []string{"suspicious"}, ExpiresOn: now.Add(time.Hour * 24 * 7)}))
+	var buf bytes.Buffer
+	require.NoError(t, re.ExportPlayers(LocalRuleName, &buf))
+	var exported PlayerListSchema
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+	require.Len(t, exported.Players, 2)
+	require.Equal(t, int(now.Add(time.Hour*24*7).Unix()), exported.Players[1].ExpiresOn)
+}
+
+func TestTransientMark(t *testing.T) {
+	const testSteamID = 76561197961279983
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID, Attributes: []string{"cheater"}}))
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID, Attributes: []string{"suspicious"}, Transient: true}))
+	require.ErrorIs(t, re.Mark(MarkOpts{SteamID: testSteamID, Attributes: []string{"suspicious"}, Transient: true}), ErrDuplicateSteamID)
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID + 1, Attributes: []string{"suspicious"}, Transient: true}))
+	require.Equal(t, []string{"cheater", "suspicious"}, re.MatchSteamAll(testSteamID).Attributes())
+
+	// Transient marks are never written to the local player list
+	require.Len(t, re.playerLists[0].Players, 1)
+	require.Equal(t, []string{"cheater"}, re.playerLists[0].Players[0].Attributes)
+
+	require.True(t, re.Unmark(testSteamID+1))
+	require.Nil(t, re.MatchSteam(testSteamID+1))
+
+	require.NoError(t, re.Mark(MarkOpts{SteamID: testSteamID + 1, Attributes: []string{"suspicious"}, Transient: true}))
+	require.Equal(t, 2, re.ClearTransient())
+	require.Equal(t, []string{"cheater"}, re.MatchSteamAll(testSteamID).Attributes())
+	require.Nil(t, re.MatchSteam(testSteamID+1))
+	require.Equal(t, 0, re.ClearTransient())
+
+	ruleList := genTestRules()
+	ruleList.Rules = []ruleDefinition{{
+		Description: "spam bot",
+		Actions:     ruleActions{TransientMark: []string{"bot"}},
+		Triggers: ruleTriggers{
+			ChatMsgTextMatch: &ruleTriggerTextMatch{Mode: textMatchModeContains, Patterns: []string{"free skins"}},
+		},
+	}}
+	_, errImport := re.ImportRules(&ruleList)
+	require.NoError(t, errImport)
+	match := re.MatchMessage("get free skins here")
+	require.NotNil(t, match)
+	require.Equal(t, []string{"bot"}, match.TransientMark)
+}
+
+func TestWhitelist(t *testing.T) {
+	const testSteamID = 76561197961279983
+	local := NewWhitelistSchema(playerDefinition{Attributes: []string{"friend"}, SteamID: steamid.SID64(testSteamID).String()})
+	re, reErr := New(nil, nil, &local)
+	require.NoError(t, reErr)
+	match := re.MatchWhitelist(testSteamID)
+	require.NotNil(t, match)
+	require.Equal(t, LocalWhitelistName, match.Origin)
+	require.Equal(t, matcherTypeWhitelist, match.MatcherType)
+	// Whitelists do not produce player matches
+	require.Nil(t, re.MatchSteam(testSteamID))
+
+	shared := NewPlayerListSchema(
+		playerDefinition{Attributes: []string{"friend"}, SteamID: steamid.SID64(testSteamID).String()},
+		playerDefinition{Attributes: []string{"friend"}, SteamID: steamid.SID64(testSteamID + 1).String()},
+	)
+	shared.FileInfo.Title = "friends"
+	shared.FileInfo.UpdateURL = "https://example.com/whitelist.json"
+	shared.SourceURL = shared.FileInfo.UpdateURL
+	count, errImport := re.ImportWhitelist(&shared)
+	require.NoError(t, errImport)
+	require.Equal(t, 2, count)
+	require.True(t, re.IsLoaded(shared.SourceURL))
+	// The local whitelist is preferred as the source
+	require.Equal(t, LocalWhitelistName, re.MatchWhitelist(testSteamID).Origin)
+	sharedMatch := re.MatchWhitelist(testSteamID + 1)
+	require.NotNil(t, sharedMatch)
+	require.Equal(t, "friends", sharedMatch.Origin)
+	require.Equal(t, shared.FileInfo.UpdateURL, sharedMatch.UpdateURL)
+
+	require.NoError(t, re.Whitelist(testSteamID+2, "player"))
+	require.ErrorIs(t, re.Whitelist(testSteamID+2, "player"), ErrDuplicateSteamID)
+	require.NotNil(t, re.MatchWhitelist(testSteamID+2))
+	var buf bytes.Buffer
+	require.NoError(t, re.ExportWhitelist(LocalWhitelistName, &buf))
+	exported, _, errParse := ParsePlayerList(buf.Bytes(), false)
+	require.NoError(t, errParse)
+	require.Len(t, exported.Players, 2)
+	require.Equal(t, LocalWhitelistName, exported.FileInfo.Title)
+
+	require.True(t, re.Unwhitelist(testSteamID))
+	require.False(t, re.Unwhitelist(testSteamID))
+	// Still whitelisted by the shared list
+	require.Equal(t, "friends", re.MatchWhitelist(testSteamID).Origin)
+
+	require.True(t, re.RemoveList(shared.SourceURL))
+	require.Nil(t, re.MatchWhitelist(testSteamID))
+	require.Nil(t, re.MatchWhitelist(testSteamID+1))
+	require.NotNil(t, re.MatchWhitelist(testSteamID+2))
+}
+
+func sortedCollection(collection steamid.Collection) steamid.Collection {
+	sort.Slice(collection, func(i, j int) bool {
+		return collection[i] < collection[j]
+	})
+	return collection
+}
+
+func genSyntheticPlayerList(count int) PlayerListSchema {
+	const baseSteamID = 76561197960265729
+	list := NewPlayerListSchema()
+	list.FileInfo.Title = "synthetic"
+	for i := 0; i < count; i++ {
+		list.Players = append(list.Players, playerDefinition{
+			Attributes: []string{"cheater"},
+			LastSeen:   playerLastSeen{Time: i},
+			SteamID:    steamid.SID64(baseSteamID + i*2).String(),
+		})
+	}
+	return list
+}
+
+func BenchmarkImportPlayers(b *testing.B) {
+	list := genSyntheticPlayerList(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		re, _ := New(nil, nil, nil)
+		if _, errImport := re.ImportPlayers(&list); errImport != nil {
+			b.Fatal(errImport)
+		}
+	}
+}
+
+func BenchmarkMatchSteam(b *testing.B) {
+	const baseSteamID = 76561197960265729
+	list := genSyntheticPlayerList(100000)
+	re, _ := New(nil, nil, nil)
+	if _, errImport := re.ImportPlayers(&list); errImport != nil {
+		b.Fatal(errImport)
+	}
+	// Check a full server worth of players per iteration, half of which are listed
+	players := make(steamid.Collection, 24)
+	for i := range players {
+		players[i] = steamid.SID64(baseSteamID + i*4001)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, sid64 := range players {
+			re.MatchSteamAll(sid64)
+		}
+	}
+}
+
+func genSyntheticRules(count int) RuleSchema {
+	modes := []textMatchMode{textMatchModeContains, textMatchModeStartsWith, textMatchModeEndsWith, textMatchModeEqual, textMatchModeWord}
+	list := NewRuleSchema()
+	list.FileInfo.Title = "synthetic"
+	for i := 0; i < count; i++ {
+		rule := ruleDefinition{Description: fmt.Sprintf("rule %d", i)}
+		pattern := fmt.Sprintf("Pattern%d", i)
+		mode := modes[i%len(modes)]
+		if i%2 == 0 {
+			rule.Triggers.UsernameTextMatch = &ruleTriggerNameMatch{Mode: mode, CaseSensitive: i%3 == 0, Patterns: []string{pattern}}
+		} else {
+			rule.Triggers.ChatMsgTextMatch = &ruleTriggerTextMatch{Mode: mode, CaseSensitive: i%3 == 0, Patterns: []string{pattern, "x" + pattern}}
+		}
+		list.Rules = append(list.Rules, rule)
+	}
+	return list
+}
+
+// matchRulesUncompiled evaluates each rule on its own without using the compiled text index
+func matchRulesUncompiled(re *Engine, input MatchInput) MatchResults {
+	var results MatchResults
+	for _, matcher := range re.matchersRule {
+		if match := matcher.Match(input); match != nil {
+			results = append(results, match)
+		}
+	}
+	return results
+}
+
+func TestCompiledTextRules(t *testing.T) {
+	ruleList := genSyntheticRules(60)
+	ruleList.Rules = append(ruleList.Rules, ruleDefinition{
+		Description: "regex",
+		Triggers: ruleTriggers{
+			UsernameTextMatch: &ruleTriggerNameMatch{Mode: textMatchModeRegex, Patterns: []string{"^pattern4[0-9]$"}},
+		},
+	})
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	_, errImport := re.ImportRules(&ruleList)
+	require.NoError(t, errImport)
+	re.registerTextMatcher(newGeneralTextMatcher(customListTitle, textMatchTypeAny, textMatchModeContains, false, []string{"test"}, "pattern2"))
+	for _, input := range []MatchInput{
+		{Name: "Pattern0"},
+		{Name: "pattern0"},
+		{Name: "PATTERN2 suffix"},
+		{Name: "prefix pattern4"},
+		{Name: "prefix Pattern5 suffix", Message: "xPattern5"},
+		{Name: "Pattern10", Message: "Pattern11"},
+		{Name: "pattern40", Message: "some pattern9 words xpattern9"},
+		{Message: "prefix pattern3 suffix xpattern3"},
+		{Message: "xpattern7xpattern27"},
+		{Name: "no match", Message: "no match"},
+	} {
+		require.Equal(t, matchRulesUncompiled(re, input), re.MatchRulesAll(input), "input: %v", input)
+	}
+	require.Equal(t, 3, len(re.MatchRulesAll(MatchInput{Name: "Pattern10", Message: "Pattern11"})))
+}
+
+func TestNormaliseText(t *testing.T) {
+	for input, expected := range map[string]string{
+		"plain name":                          "plain name",
+		"Ｆｕｌｌｗｉｄｔｈ":                           "Fullwidth",
+		"Сhеаt bоt":                           "Cheat bot",
+		"zero\u200bwidth":                     "zerowidth",
+		"rtl\u202eoverride":                   "rtloverride",
+		"comb\u0301ined":                      "combined",
+		"𝐛𝐨𝐭":                                 "bot",
+		"blankㅤ":                              "blank",
+		"z\u0334a\u0337l\u0336g\u0335o\u0338": "zalgo",
+		"\u0301leading":                       "leading",
+		// Scripts written using combining marks are left intact
+		"नमस्ते दुनिया": "नमस्ते दुनिया",
+		"สวัสดี":        "สวัสดี",
+		"مَرْحَبًا":     "مَرْحَبًا",
+	} {
+		require.Equal(t, expected, NormaliseText(input), "input: %q", input)
+	}
+}
+
+func TestNormalisedTextRules(t *testing.T) {
+	ruleList := genTestRules()
+	ruleList.Rules = []ruleDefinition{
+		{
+			Description: "normalised name",
+			Triggers: ruleTriggers{
+				UsernameTextMatch: &ruleTriggerNameMatch{Mode: textMatchModeContains, Patterns: []string{"cheat bot"}, Normalise: true},
+			},
+		},
+		{
+			Description: "normalised message",
+			Triggers: ruleTriggers{
+				ChatMsgTextMatch: &ruleTriggerTextMatch{Mode: textMatchModeWord, Patterns: []string{"ｍｙｇｏｄ"}, Normalise: true},
+			},
+		},
+		{
+			Description: "normalised regex",
+			Triggers: ruleTriggers{
+				ChatMsgTextMatch: &ruleTriggerTextMatch{Mode: textMatchModeRegex, Patterns: []string{"^join .+ discord$"}, Normalise: true},
+			},
+		},
+		{
+			Description: "raw name",
+			Triggers: ruleTriggers{
+				UsernameTextMatch: &ruleTriggerNameMatch{Mode: textMatchModeContains, Patterns: []string{"cheat bot"}},
+			},
+		},
+	}
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	_, errImport := re.ImportRules(&ruleList)
+	require.NoError(t, errImport)
+	testCases := []struct {
+		input      MatchInput
+		rules      []string
+		normalised string
+	}{
+		{input: MatchInput{Name: "xx Сhеаt\u200b bоt xx"}, rules: []string{"normalised name"}, normalised: "xx Cheat bot xx"},
+		{input: MatchInput{Name: "cheat bot"}, rules: []string{"normalised name", "raw name"}, normalised: "cheat bot"},
+		{input: MatchInput{Message: "oh mygod\u202e"}, rules: []string{"normalised message"}, normalised: "oh mygod"},
+		{input: MatchInput{Message: "join ｍｙ discord"}, rules: []string{"normalised regex"}, normalised: "join my discord"},
+		{input: MatchInput{Name: "cheat", Message: "bot"}},
+	}
+	for num, tc := range testCases {
+		matches := re.MatchRulesAll(tc.input)
+		require.Equal(t, matchRulesUncompiled(re, tc.input), matches, "Test %d failed", num)
+		var matched []string
+		for _, match := range matches {
+			matched = append(matched, match.Rule)
+		}
+		require.Equal(t, tc.rules, matched, "Test %d failed", num)
+		if len(matches) > 0 {
+			require.Equal(t, tc.normalised, matches[0].Normalised, "Test %d failed", num)
+		}
+	}
+}
+
+func TestMatchDetails(t *testing.T) {
+	ruleList := genTestRules()
+	ruleList.Rules = []ruleDefinition{
+		{
+			Description: "name and message",
+			Triggers: ruleTriggers{
+				Mode:              modeTrigMatchAll,
+				UsernameTextMatch: &ruleTriggerNameMatch{Mode: textMatchModeWord, Patterns: []string{"Bot"}},
+				ChatMsgTextMatch:  &ruleTriggerTextMatch{Mode: textMatchModeRegex, Patterns: []string{"[0-9]+ ?%"}},
+			},
+		},
+		{
+			Description: "suffix",
+			Triggers: ruleTriggers{
+				UsernameTextMatch: &ruleTriggerNameMatch{Mode: textMatchModeEndsWith, Patterns: []string{".EXE"}},
+			},
+		},
+	}
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	_, errImport := re.ImportRules(&ruleList)
+	require.NoError(t, errImport)
+
+	match := re.MatchRules(MatchInput{Name: "evil bot here", Message: "get 50% off cheats"})
+	require.NotNil(t, match)
+	require.Equal(t, "name and message", match.Rule)
+	require.Equal(t, "http://localhost", match.UpdateURL)
+	require.Equal(t, 2, len(match.Triggers))
+	require.Equal(t, "Bot", match.Pattern)
+	require.Equal(t, "bot", match.Matched)
+	require.Equal(t, 5, match.Offset)
+	require.Equal(t, "[0-9]+ ?%", match.Triggers[1].Pattern)
+	require.Equal(t, "50%", match.Triggers[1].Matched)
+	require.Equal(t, 4, match.Triggers[1].Offset)
+
+	suffix := re.MatchName("cheater.exe")
+	require.NotNil(t, suffix)
+	require.Equal(t, ".exe", suffix.Matched)
+	require.Equal(t, 7, suffix.Offset)
+
+	const testSteamID = steamid.SID64(76561197961279983)
+	players := NewPlayerListSchema(playerDefinition{Attributes: []string{"cheater"}, SteamID: testSteamID.String()})
+	players.FileInfo.Title = customListTitle
+	players.FileInfo.UpdateURL = "http://localhost/players.json"
+	_, errPlayers := re.ImportPlayers(&players)
+	require.NoError(t, errPlayers)
+	steamMatch := re.MatchSteam(testSteamID)
+	require.NotNil(t, steamMatch)
+	require.Equal(t, "http://localhost/players.json", steamMatch.UpdateURL)
+	require.Equal(t, testSteamID.String(), steamMatch.Pattern)
+}
+
+func TestMatchOffsets(t *testing.T) {
+	ruleList := genTestRules()
+	ruleList.Rules = []ruleDefinition{
+		{
+			Description: "contains",
+			Triggers: ruleTriggers{
+				UsernameTextMatch: &ruleTriggerNameMatch{Mode: textMatchModeContains, Patterns: []string{"missing", "BOT", "kill"}},
+			},
+		},
+		{
+			Description: "word",
+			Triggers: ruleTriggers{
+				ChatMsgTextMatch: &ruleTriggerTextMatch{Mode: textMatchModeWord, Patterns: []string{"ᴄheat"}},
+			},
+		},
+		{
+			Description: "suffix",
+			Triggers: ruleTriggers{
+				UsernameTextMatch: &ruleTriggerNameMatch{Mode: textMatchModeEndsWith, Patterns: []string{"BOT"}},
+			},
+		},
+		{
+			Description: "normalised",
+			Triggers: ruleTriggers{
+				UsernameTextMatch: &ruleTriggerNameMatch{Mode: textMatchModeContains, Patterns: []string{"bot"}, Normalise: true},
+			},
+		},
+	}
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+	_, errImport := re.ImportRules(&ruleList)
+	require.NoError(t, errImport)
+
+	// The kelvin sign is 3 bytes but lower cases to a single byte "k", so offsets in the lower cased text
+	// must be mapped back to the original
+	input := MatchInput{Name: "\u212Aill the Ｂot bot", Message: "free ᴄheat ᴄheats"}
+	matches := re.MatchRulesAll(input)
+	require.Equal(t, matchRulesUncompiled(re, input), matches)
+	require.Equal(t, 4, len(matches))
+
+	// Earlier patterns take precedence over earlier positions
+	require.Equal(t, "BOT", matches[0].Pattern)
+	require.Equal(t, "bot", matches[0].Matched)
+	require.Equal(t, len("\u212Aill the Ｂot "), matches[0].Offset)
+	require.Equal(t, "ᴄheat", matches[1].Matched)
+	require.Equal(t, len("free "), matches[1].Offset)
+	require.Equal(t, "bot", matches[2].Matched)
+	// Normalised matches refer to the normalised text
+	require.Equal(t, "Kill the Bot bot", matches[3].Normalised)
+	require.Equal(t, "Bot", matches[3].Triggers[0].Matched)
+	require.Equal(t, len("Kill the "), matches[3].Triggers[0].Offset)
+
+	kelvin := re.MatchName("\u212Aill")
+	require.NotNil(t, kelvin)
+	require.Equal(t, "\u212Aill", kelvin.Matched)
+	require.Equal(t, 0, kelvin.Offset)
+}
+
+func benchmarkMatchRules(b *testing.B, matchFn func(re *Engine, input MatchInput) MatchResults) {
+	ruleList := genSyntheticRules(5000)
+	re, _ := New(nil, nil, nil)
+	if _, errImport := re.ImportRules(&ruleList); errImport != nil {
+		b.Fatal(errImport)
+	}
+	input := MatchInput{
+		Name:    "Some Player Name",
+		Message: "this is a reasonably long chat message sent by a player to check for Pattern123 spam",
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matchFn(re, input)
+	}
+}
+
+func BenchmarkMatchRulesUncompiled(b *testing.B) {
+	benchmarkMatchRules(b, matchRulesUncompiled)
+}
+
+func BenchmarkMatchRulesCompiled(b *testing.B) {
+	benchmarkMatchRules(b, func(re *Engine, input MatchInput) MatchResults {
+		return re.MatchRulesAll(input)
+	})
+}
+
+func TestReimportList(t *testing.T) {
+	const (
+		testSteamID = 76561197961279983
+		sourceURL   = "http://localhost/list.json"
+	)
+	re, reErr := New(nil, nil, nil)
+	require.NoError(t, reErr)
+
+	ruleList := genTestRules()
+	ruleList.SourceURL = sourceURL
+	players := NewPlayerListSchema(playerDefinition{Attributes: []string{"cheater"}, SteamID: steamid.SID64(testSteamID).String()})
+	players.FileInfo.Title = customListTitle
+	players.SourceURL = sourceURL
+	for i := 0; i < 2; i++ {
+		_, errRules := re.ImportRules(&ruleList)
+		require.NoError(t, errRules)
+		_, errPlayers := re.ImportPlayers(&players)
+		require.NoError(t, errPlayers)
+	}
+	require.Equal(t, 1, len(re.MatchRulesAll(MatchInput{Name: "test_contains_value_ci"})))
+	require.Equal(t, 1, len(re.MatchSteamAll(testSteamID)))
+	require.Equal(t, 2, len(re.rulesLists))
+	require.Equal(t, 2, len(re.playerLists))
+
+	// The same title from another source is a distinct list
+	otherSource := genTestRules()
+	otherSource.SourceURL = "http://localhost/other.json"
+	_, errOther := re.ImportRules(&otherSource)
+	require.NoError(t, errOther)
+	require.Equal(t, 2, len(re.MatchRulesAll(MatchInput{Name: "test_contains_value_ci"})))
+
+	// Replacing a list drops entries which no longer exist
+	updated := NewPlayerListSchema(playerDefinition{Attributes: []string{"bot"}, SteamID: steamid.SID64(testSteamID + 1).String()})
+	updated.FileInfo.Title = customListTitle
+	updated.SourceURL = sourceURL
+	_, errUpdated := re.ImportPlayers(&updated)
+	require.NoError(t, errUpdated)
+	require.Nil(t, re.MatchSteam(testSteamID))
+	require.NotNil(t, re.MatchSteam(testSteamID+1))
+	require.Equal(t, []string{"bot"}, re.UniqueTags())
+
+	// A list whose title changed replaces the copy loaded from the same source
+	renamed := genTestRules()
+	renamed.FileInfo.Title = "renamed"
+	renamed.SourceURL = sourceURL
+	_, errRenamed := re.ImportRules(&renamed)
+	require.NoError(t, errRenamed)
+	require.Equal(t, 2, len(re.MatchRulesAll(MatchInput{Name: "test_contains_value_ci"})))
+	require.Equal(t, 3, len(re.rulesLists))
+
+	require.False(t, re.RemoveList(""))
+	require.True(t, re.RemoveList(sourceURL))
+	require.False(t, re.RemoveList(sourceURL))
+	require.Nil(t, re.MatchSteam(testSteamID+1))
+	require.Equal(t, 1, len(re.MatchRulesAll(MatchInput{Name: "test_contains_value_ci"})))
+	require.Equal(t, 2, len(re.rulesLists))
+	require.Equal(t, 1, len(re.playerLists))
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type MatchResult struct {
@@ -12,6 +13,105 @@ type MatchResult struct {
 	Attributes []string
 	//Proof       []string
 	MatcherType string
+	Rule        string // Description of the rule that fired, empty for non-rule matches
+	Normalised  string // Normalised form of the text that was matched, empty when the rule does not normalise text
+	// Pattern is the pattern, hash or condition of the trigger which matched
+	Pattern string
+	// Matched is the part of the input which satisfied the pattern, such as the matched substring of a name
+	Matched string
+	// Offset is the byte offset of Matched within the matched text. Only set for text matches. When Normalised
+	// is set, Matched and Offset refer to the normalised text rather than the original.
+	Offset int
+	// UpdateURL is the update_url of the list that the match was generated against
+	UpdateURL string
+	// Triggers holds the matches of the individual triggers which caused a rule to fire
+	Triggers MatchResults
+	// TransientMark holds the attributes the rule requests the player to be transiently marked with
+	TransientMark []string
+}
+
+const matcherTypeRule = "rule"
+
+// IsMessageMatch returns true if the match, or one of the triggers of a rule match, was made against a chat message
+func (m *MatchResult) IsMessageMatch() bool {
+	if textMatchType(m.MatcherType) == textMatchTypeMessage {
+		return true
+	}
+	for _, trigger := range m.Triggers {
+		if trigger.IsMessageMatch() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTextMatch returns true if the match was made against a name or chat message, in which case Offset is valid
+func (m *MatchResult) IsTextMatch() bool {
+	switch textMatchType(m.MatcherType) {
+	case textMatchTypeAny, textMatchTypeName, textMatchTypeMessage:
+		return true
+	default:
+		return false
+	}
+}
+
+// MatchResults is a collection of all the matches found for a single player
+type MatchResults []*MatchResult
+
+// Attributes returns the unique set of attributes across all the matches
+func (results MatchResults) Attributes() []string {
+	var attrs []string
+	for _, result := range results {
+		attrs = mergeAttributes(attrs, result.Attributes...)
+	}
+	return attrs
+}
+
+// Origins returns the unique list titles that generated the matches
+func (results MatchResults) Origins() []string {
+	var origins []string
+	for _, result := range results {
+		found := false
+		for _, origin := range origins {
+			if origin == result.Origin {
+				found = true
+				break
+			}
+		}
+		if !found {
+			origins = append(origins, result.Origin)
+		}
+	}
+	return origins
+}
+
+// mergeAttributes appends the attributes that do not already exist in the destination
+func mergeAttributes(attrs []string, newAttrs ...string) []string {
+	for _, attr := range newAttrs {
+		found := false
+		for _, existing := range attrs {
+			if strings.EqualFold(attr, existing) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+// MatchInput holds the known state of a player that rules are evaluated against. Fields which
+// are left empty are considered unknown and will never satisfy a trigger.
+type MatchInput struct {
+	Name       string
+	Message    string
+	AvatarHash string
+	// AvatarPerceptualHash is the PerceptualHash of the avatar, used by the hash_reduced matchers
+	AvatarPerceptualHash string
+	// Profile is nil until the steam profile of the player has been fetched
+	Profile *ProfileInput
 }
 
 type textMatchType string
@@ -27,8 +127,8 @@ type avatarMatchType string
 const (
 	// 1:1 match of avatar
 	avatarMatchExact avatarMatchType = "hash_full"
-	// Reduced matcher
-	//avatarMatchReduced avatarMatchType = "hash_reduced"
+	// Perceptual hash of the avatar which also matches visually similar avatars
+	avatarMatchReduced avatarMatchType = "hash_reduced"
 )
 
 // AvatarMatcher provides an interface to match avatars using custom methods
@@ -51,18 +151,27 @@ func (m avatarMatcher) Type() avatarMatchType {
 func (m avatarMatcher) Match(hexDigest string) *MatchResult {
 	for _, hash := range m.hashes {
 		if hash == hexDigest {
-			return &MatchResult{Origin: m.origin, MatcherType: string(m.Type()), Attributes: m.attributes}
+			return &MatchResult{Origin: m.origin, MatcherType: string(m.Type()), Attributes: m.attributes, Pattern: hash, Matched: hexDigest}
 		}
 	}
 	return nil
 }
 
-func newAvatarMatcher(origin string, avatarMatchType avatarMatchType, hashes ...string) avatarMatcher {
+func newAvatarMatcher(origin string, avatarMatchType avatarMatchType, attributes []string, hashes ...string) avatarMatcher {
 	return avatarMatcher{
-		origin:    origin,
-		matchType: avatarMatchType,
-		hashes:    hashes,
+		origin:     origin,
+		matchType:  avatarMatchType,
+		hashes:     hashes,
+		attributes: attributes,
+	}
+}
+
+// avatarInput returns the hash of the input which the matcher operates on
+func avatarInput(matcher AvatarMatcher, input MatchInput) string {
+	if matcher.Type() == avatarMatchReduced {
+		return input.AvatarPerceptualHash
 	}
+	return input.AvatarHash
 }
 
 // TextMatcher provides an interface to build text based matchers for names or in game messages
@@ -80,13 +189,27 @@ type SteamIDMatcher interface {
 type steamIDMatcher struct {
 	steamID    steamid.SID64
 	origin     string
+	source     string
+	updateURL  string
 	attributes []string
 	lastSeen   playerLastSeen
+	// expiresOn is the unix timestamp after which the matcher no longer matches, zero never expires
+	expiresOn int
 }
 
 func (m steamIDMatcher) Match(sid64 steamid.SID64) *MatchResult {
+	if m.expiresOn > 0 && int64(m.expiresOn) <= time.Now().Unix() {
+		return nil
+	}
 	if sid64 == m.steamID {
-		return &MatchResult{Origin: m.origin, MatcherType: "steam_id", Attributes: m.attributes}
+		return &MatchResult{
+			Origin:      m.origin,
+			MatcherType: "steam_id",
+			Attributes:  m.attributes,
+			Pattern:     m.steamID.String(),
+			Matched:     sid64.String(),
+			UpdateURL:   m.updateURL,
+		}
 	}
 	return nil
 }
@@ -95,17 +218,76 @@ func newSteamIDMatcher(origin string, sid64 steamid.SID64, attributes []string)
 	return steamIDMatcher{steamID: sid64, origin: origin, attributes: attributes}
 }
 
+// steamIDIndex stores the steam id matchers keyed by their steam id so lookups do not depend on the
+// size of the loaded lists. Multiple lists can contain the same steam id so each id maps to a matcher
+// for every origin.
+type steamIDIndex map[steamid.SID64][]steamIDMatcher
+
+// add registers the matcher, replacing any existing matcher for the same steam id and list.
+func (index steamIDIndex) add(matcher steamIDMatcher) {
+	existing := index[matcher.steamID]
+	for i, known := range existing {
+		if known.origin == matcher.origin && known.source == matcher.source {
+			existing[i] = matcher
+			return
+		}
+	}
+	index[matcher.steamID] = append(existing, matcher)
+}
+
+// remove deletes all matchers for the steam id, returning true if any existed.
+func (index steamIDIndex) remove(sid64 steamid.SID64) bool {
+	_, found := index[sid64]
+	delete(index, sid64)
+	return found
+}
+
+// removeList deletes the matcher for the steam id which belongs to the list.
+func (index steamIDIndex) removeList(sid64 steamid.SID64, origin string, source string) {
+	existing := index[sid64]
+	var remaining []steamIDMatcher
+	for _, known := range existing {
+		if known.origin == origin && known.source == source {
+			continue
+		}
+		remaining = append(remaining, known)
+	}
+	if len(remaining) == 0 {
+		delete(index, sid64)
+		return
+	}
+	index[sid64] = remaining
+}
+
+func (index steamIDIndex) match(sid64 steamid.SID64) MatchResults {
+	var results MatchResults
+	for _, matcher := range index[sid64] {
+		if match := matcher.Match(sid64); match != nil {
+			results = append(results, match)
+		}
+	}
+	return results
+}
+
 type regexTextMatcher struct {
 	matcherType textMatchType
 	patterns    []*regexp.Regexp
+	sources     []string
 	origin      string
 	attributes  []string
 }
 
 func (m regexTextMatcher) Match(value string) *MatchResult {
-	for _, re := range m.patterns {
-		if re.MatchString(value) {
-			return &MatchResult{Origin: m.origin, MatcherType: string(m.Type())}
+	for idx, re := range m.patterns {
+		if loc := re.FindStringIndex(value); loc != nil {
+			return &MatchResult{
+				Origin:      m.origin,
+				MatcherType: string(m.Type()),
+				Attributes:  m.attributes,
+				Pattern:     m.sources[idx],
+				Matched:     value[loc[0]:loc[1]],
+				Offset:      loc[0],
+			}
 		}
 	}
 	return nil
@@ -115,9 +297,12 @@ func (m regexTextMatcher) Type() textMatchType {
 	return m.matcherType
 }
 
-func newRegexTextMatcher(origin string, matcherType textMatchType, attributes []string, patterns ...string) (regexTextMatcher, error) {
+func newRegexTextMatcher(origin string, matcherType textMatchType, caseSensitive bool, attributes []string, patterns ...string) (regexTextMatcher, error) {
 	var compiled []*regexp.Regexp
 	for _, inputPattern := range patterns {
+		if !caseSensitive {
+			inputPattern = "(?i)" + inputPattern
+		}
 		c, compErr := regexp.Compile(inputPattern)
 		if compErr != nil {
 			return regexTextMatcher{}, errors.Wrapf(compErr, "Invalid regex pattern: %s", inputPattern)
@@ -128,6 +313,7 @@ func newRegexTextMatcher(origin string, matcherType textMatchType, attributes []
 		origin:      origin,
 		matcherType: matcherType,
 		patterns:    compiled,
+		sources:     patterns,
 		attributes:  attributes,
 	}, nil
 }
@@ -141,81 +327,83 @@ type generalTextMatcher struct {
 	origin        string
 }
 
+func (m generalTextMatcher) result(pattern string, matched string, offset int) *MatchResult {
+	return &MatchResult{
+		Origin:      m.origin,
+		MatcherType: string(m.Type()),
+		Attributes:  m.attributes,
+		Pattern:     pattern,
+		Matched:     matched,
+		Offset:      offset,
+	}
+}
+
 func (m generalTextMatcher) Match(value string) *MatchResult {
-	switch m.mode {
-	case textMatchModeStartsWith:
-		for _, prefix := range m.patterns {
-			if m.caseSensitive {
-				if strings.HasPrefix(value, prefix) {
-					return &MatchResult{Origin: m.origin, Attributes: m.attributes}
-				}
-			} else {
-				if strings.HasPrefix(strings.ToLower(value), strings.ToLower(prefix)) {
-					return &MatchResult{Origin: m.origin}
-				}
-			}
+	compare := value
+	var offsets []int
+	if !m.caseSensitive {
+		compare, offsets = lowerText(value)
+	}
+	for _, pattern := range m.patterns {
+		comparePattern := pattern
+		if !m.caseSensitive {
+			comparePattern = strings.ToLower(pattern)
 		}
-	case textMatchModeEndsWith:
-		for _, prefix := range m.patterns {
-			if m.caseSensitive {
-				if strings.HasSuffix(value, prefix) {
-					return &MatchResult{Origin: m.origin}
-				}
-			} else {
-				if strings.HasSuffix(strings.ToLower(value), strings.ToLower(prefix)) {
-					return &MatchResult{Origin: m.origin, MatcherType: string(m.Type())}
-				}
+		offset := -1
+		switch m.mode {
+		case textMatchModeStartsWith:
+			if strings.HasPrefix(compare, comparePattern) {
+				offset = 0
 			}
-		}
-	case textMatchModeEqual:
-		for _, prefix := range m.patterns {
-			if m.caseSensitive {
-				if value == prefix {
-					return &MatchResult{Origin: m.origin}
-				}
-			} else {
-				if strings.EqualFold(value, prefix) {
-					return &MatchResult{Origin: m.origin}
-				}
+		case textMatchModeEndsWith:
+			if strings.HasSuffix(compare, comparePattern) {
+				offset = len(compare) - len(comparePattern)
 			}
-		}
-	case textMatchModeContains:
-		for _, prefix := range m.patterns {
-			if m.caseSensitive {
-				if strings.Contains(value, prefix) {
-					return &MatchResult{Origin: m.origin, MatcherType: string(m.Type())}
-				}
-			} else {
-				if strings.Contains(strings.ToLower(value), strings.ToLower(prefix)) {
-					return &MatchResult{Origin: m.origin}
-				}
+		case textMatchModeEqual:
+			if compare == comparePattern {
+				offset = 0
 			}
+		case textMatchModeContains:
+			offset = strings.Index(compare, comparePattern)
+		case textMatchModeWord:
+			offset = wordIndex(compare, comparePattern)
 		}
-	case textMatchModeWord:
-		if !m.caseSensitive {
-			value = strings.ToLower(value)
-		}
-		for _, iw := range strings.Split(value, " ") {
-			for _, p := range m.patterns {
-				if m.caseSensitive {
-					if p == iw {
-						return &MatchResult{Origin: m.origin}
-					}
-				} else {
-					if strings.EqualFold(strings.ToLower(p), iw) {
-						return &MatchResult{Origin: m.origin}
-					}
-				}
+		if offset >= 0 {
+			end := offset + len(comparePattern)
+			if offsets != nil {
+				// Lower casing can change the length of characters, so map the match back to the original value
+				offset, end = offsets[offset], offsets[end]
 			}
+			return m.result(pattern, value[offset:end], offset)
 		}
 	}
 	return nil
 }
 
+// wordIndex returns the offset of the first space separated word of value equal to word, or -1 if there is none
+func wordIndex(value string, word string) int {
+	offset := 0
+	for _, valueWord := range strings.Split(value, " ") {
+		if valueWord == word {
+			return offset
+		}
+		offset += len(valueWord) + 1
+	}
+	return -1
+}
+
 func (m generalTextMatcher) Type() textMatchType {
 	return m.matcherType
 }
 
+// newTextMatcher creates the text matcher implementation suitable for the match mode given
+func newTextMatcher(origin string, matcherType textMatchType, matchMode textMatchMode, caseSensitive bool, attributes []string, patterns ...string) (TextMatcher, error) {
+	if matchMode == textMatchModeRegex {
+		return newRegexTextMatcher(origin, matcherType, caseSensitive, attributes, patterns...)
+	}
+	return newGeneralTextMatcher(origin, matcherType, matchMode, caseSensitive, attributes, patterns...), nil
+}
+
 func newGeneralTextMatcher(origin string, matcherType textMatchType, matchMode textMatchMode, caseSensitive bool, attributes []string, patterns ...string) TextMatcher {
 	return generalTextMatcher{
 		origin:        origin,
@@ -226,3 +414,138 @@ func newGeneralTextMatcher(origin string, matcherType textMatchType, matchMode t
 		attributes:    attributes,
 	}
 }
+
+// ruleMatcher evaluates all the triggers of a single rule definition as one unit according
+// to the rules trigger mode.
+type ruleMatcher struct {
+	origin      string
+	source      string
+	updateURL   string
+	description string
+	mode        ruleTriggerMode
+	text        []TextMatcher
+	avatar      []AvatarMatcher
+	profile     []profileMatcher
+	// transientMark holds the attributes of the rules transient_mark action
+	transientMark []string
+}
+
+func newRuleMatcher(description string, mode ruleTriggerMode) (ruleMatcher, error) {
+	switch mode {
+	case "":
+		// Lists which do not define a mode keep the original behaviour of each trigger
+		// firing on its own.
+		mode = modeTrigMatchAny
+	case modeTrigMatchAny, modeTrigMatchAll:
+	default:
+		return ruleMatcher{}, errors.Errorf("Invalid trigger mode: %s", mode)
+	}
+	return ruleMatcher{description: description, mode: mode}, nil
+}
+
+func (m ruleMatcher) triggerCount() int {
+	return len(m.text) + len(m.avatar) + len(m.profile)
+}
+
+func (m ruleMatcher) Match(input MatchInput) *MatchResult {
+	return m.match(input, nil, nil)
+}
+
+// match evaluates the rule using the precompiled text trigger hits where available. triggerIDs maps
+// each text trigger to its id within the textIndex, or -1 if it must be evaluated directly.
+func (m ruleMatcher) match(input MatchInput, hits *textHits, triggerIDs []int) *MatchResult {
+	var matched []*MatchResult
+	for textIdx, matcher := range m.text {
+		var match *MatchResult
+		if hits == nil || triggerIDs[textIdx] < 0 {
+			match = matchTextInput(matcher, input)
+		} else if hit := hits.hit(triggerIDs[textIdx]); hit != nil {
+			match = hitResult(matcher, hit)
+		}
+		if match != nil {
+			matched = append(matched, match)
+		} else if m.mode == modeTrigMatchAll {
+			return nil
+		}
+	}
+	for _, matcher := range m.avatar {
+		var match *MatchResult
+		if hash := avatarInput(matcher, input); hash != "" {
+			match = matcher.Match(hash)
+		}
+		if match != nil {
+			matched = append(matched, match)
+		} else if m.mode == modeTrigMatchAll {
+			return nil
+		}
+	}
+	for _, matcher := range m.profile {
+		var match *MatchResult
+		if input.Profile != nil {
+			match = matcher.Match(*input.Profile)
+		}
+		if match != nil {
+			matched = append(matched, match)
+		} else if m.mode == modeTrigMatchAll {
+			return nil
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	result := &MatchResult{
+		Origin:        matched[0].Origin,
+		MatcherType:   matcherTypeRule,
+		Rule:          m.description,
+		Normalised:    matched[0].Normalised,
+		Pattern:       matched[0].Pattern,
+		Matched:       matched[0].Matched,
+		Offset:        matched[0].Offset,
+		UpdateURL:     m.updateURL,
+		Triggers:      matched,
+		TransientMark: m.transientMark,
+	}
+	for _, match := range matched {
+		result.Attributes = mergeAttributes(result.Attributes, match.Attributes...)
+		if result.Normalised == "" {
+			result.Normalised = match.Normalised
+		}
+	}
+	return result
+}
+
+// hitResult creates the result of a compiled trigger from its hit in the textIndex
+func hitResult(matcher TextMatcher, hit *textHit) *MatchResult {
+	if normalised, isNormalised := matcher.(normalisedTextMatcher); isNormalised {
+		matcher = normalised.TextMatcher
+	}
+	result := matcher.(generalTextMatcher).result(hit.pattern, hit.matched, hit.offset)
+	result.Normalised = hit.normalised
+	return result
+}
+
+// matchTextInput runs the text matcher against the input field corresponding to its type.
+func matchTextInput(matcher TextMatcher, input MatchInput) *MatchResult {
+	switch matcher.Type() {
+	case textMatchTypeName:
+		if input.Name == "" {
+			return nil
+		}
+		return matcher.Match(input.Name)
+	case textMatchTypeMessage:
+		if input.Message == "" {
+			return nil
+		}
+		return matcher.Match(input.Message)
+	default:
+		for _, value := range []string{input.Name, input.Message} {
+			if value == "" {
+				continue
+			}
+			if match := matcher.Match(value); match != nil {
+				return match
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package rules
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// confusables maps characters which are commonly used in place of their visually identical latin
+// counterparts to the latin character. Characters with a compatibility decomposition, such as fullwidth
+// forms, are already handled by the compatibility normalisation and are not included.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c',
+	'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	'ь': 'b', 'г': 'r', 'п': 'n',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C',
+	'Т': 'T', 'У': 'Y', 'Х': 'X', 'Ѕ': 'S', 'І': 'I', 'Ј': 'J', 'Ԁ': 'D', 'Ԛ': 'Q', 'Ԝ': 'W',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u',
+	'χ': 'x', 'γ': 'y',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O',
+	'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	// Latin lookalikes
+	'ı': 'i', 'ȷ': 'j', 'ɑ': 'a', 'ɡ': 'g', 'ʏ': 'y', 'ᴀ': 'A', 'ʙ': 'B', 'ᴄ': 'C', 'ᴅ': 'D', 'ᴇ': 'E',
+	'ɢ': 'G', 'ʜ': 'H', 'ɪ': 'I', 'ᴊ': 'J', 'ᴋ': 'K', 'ʟ': 'L', 'ᴍ': 'M', 'ɴ': 'N', 'ᴏ': 'O', 'ᴘ': 'P',
+	'ʀ': 'R', 'ꜱ': 'S', 'ᴛ': 'T', 'ᴜ': 'U', 'ᴠ': 'V', 'ᴡ': 'W', 'ᴢ': 'Z',
+}
+
+// invisible holds characters which render as blank space but are not covered by the unicode format or
+// control categories
+var invisible = map[rune]bool{
+	'ᅟ': true, // Hangul choseong filler
+	'ᅠ': true, // Hangul jungseong filler
+	'⠀': true, // Braille pattern blank
+	'ㅤ': true, // Hangul filler
+	'ﾠ': true, // Halfwidth hangul filler
+}
+
+func isInvisible(r rune) bool {
+	return invisible[r] || unicode.In(r, unicode.Cf, unicode.Cc)
+}
+
+// isDisguisingMark returns true if the combining mark following base only serves to disguise the text. Once
+// decomposed, latin, greek and cyrillic letters along with common characters such as digits and spaces carry
+// accents as combining marks which are removed. Other scripts such as devanagari, thai and arabic are written
+// using combining marks, so removing them would change the text.
+func isDisguisingMark(base rune, r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me) && unicode.In(base, unicode.Latin, unicode.Greek, unicode.Cyrillic, unicode.Common)
+}
+
+// NormaliseText reduces text to a canonical form so that visually identical text compares equal.
+//
+// The text is decomposed using NFKD, which also converts compatibility forms such as fullwidth and
+// mathematical characters, before removing control, zero-width and bidirectional override characters along
+// with the combining marks of scripts which do not need them, see isDisguisingMark. Known lookalike characters
+// are then replaced with their latin counterpart and the result is recomposed using NFKC.
+func NormaliseText(text string) string {
+	var (
+		builder strings.Builder
+		base    rune
+	)
+	for _, r := range norm.NFKD.String(text) {
+		if isInvisible(r) || isDisguisingMark(base, r) {
+			continue
+		}
+		if !unicode.In(r, unicode.Mn, unicode.Me) {
+			base = r
+		}
+		if replacement, found := confusables[r]; found {
+			r = replacement
+		}
+		builder.WriteRune(r)
+	}
+	return norm.NFKC.String(builder.String())
+}
+
+// normalisedTextMatcher normalises the text using NormaliseText before passing it to the wrapped matcher. The
+// normalised text is included in the match result.
+type normalisedTextMatcher struct {
+	TextMatcher
+}
+
+func (m normalisedTextMatcher) Match(value string) *MatchResult {
+	normalised := NormaliseText(value)
+	match := m.TextMatcher.Match(normalised)
+	if match != nil {
+		match.Normalised = normalised
+	}
+	return match
+}
+
+// newNormalisedTextMatcher creates a text matcher which matches against the normalised form of the text. Patterns,
+// other than regular expressions, are normalised as well so that they can be written using lookalike characters.
+func newNormalisedTextMatcher(origin string, matcherType textMatchType, matchMode textMatchMode, caseSensitive bool, attributes []string, patterns ...string) (TextMatcher, error) {
+	if matchMode != textMatchModeRegex {
+		normalisedPatterns := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			normalisedPatterns[i] = NormaliseText(pattern)
+		}
+		patterns = normalisedPatterns
+	}
+	matcher, errMatcher := newTextMatcher(origin, matcherType, matchMode, caseSensitive, attributes, patterns...)
+	if errMatcher != nil {
+		return nil, errMatcher
+	}
+	return normalisedTextMatcher{TextMatcher: matcher}, nil
+}
@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+const matcherTypeProfile = "profile"
+
+// ProfileInput holds the steam profile and ban state of a player. Fields which are unknown, such as the
+// creation date of a private profile, are left as their zero value.
+type ProfileInput struct {
+	AccountCreatedOn time.Time
+	Private          bool
+	CommunityBanned  bool
+	VACBans          int
+	GameBans         int
+	LastBanOn        *time.Time
+}
+
+// profileMatcher matches players whose profile satisfies every one of the configured conditions. Conditions
+// which are not set are ignored.
+type profileMatcher struct {
+	origin          string
+	accountAgeMax   *time.Duration
+	vacBansMin      *int
+	gameBansMin     *int
+	lastBanMax      *time.Duration
+	private         bool
+	communityBanned bool
+	attributes      []string
+	// conditions describes the configured conditions
+	conditions string
+}
+
+func newProfileMatcher(origin string, trigger ruleTriggerProfileMatch, attributes []string) (profileMatcher, error) {
+	matcher := profileMatcher{
+		origin:          origin,
+		vacBansMin:      trigger.VACBansMin,
+		gameBansMin:     trigger.GameBansMin,
+		private:         trigger.PrivateProfile,
+		communityBanned: trigger.CommunityBanned,
+		attributes:      attributes,
+	}
+	for _, minimum := range []*int{trigger.AccountAgeMaxDays, trigger.VACBansMin, trigger.GameBansMin, trigger.LastBanMaxDays} {
+		if minimum != nil && *minimum < 0 {
+			return profileMatcher{}, errors.Errorf("Invalid profile trigger threshold: %d", *minimum)
+		}
+	}
+	if trigger.AccountAgeMaxDays != nil {
+		age := daysDuration(*trigger.AccountAgeMaxDays)
+		matcher.accountAgeMax = &age
+	}
+	if trigger.LastBanMaxDays != nil {
+		age := daysDuration(*trigger.LastBanMaxDays)
+		matcher.lastBanMax = &age
+	}
+	var conditions []string
+	for name, value := range map[string]*int{
+		"account_age_max_days": trigger.AccountAgeMaxDays,
+		"vac_bans_min":         trigger.VACBansMin,
+		"game_bans_min":        trigger.GameBansMin,
+		"last_ban_max_days":    trigger.LastBanMaxDays,
+	} {
+		if value != nil {
+			conditions = append(conditions, fmt.Sprintf("%s=%d", name, *value))
+		}
+	}
+	if matcher.private {
+		conditions = append(conditions, "private_profile")
+	}
+	if matcher.communityBanned {
+		conditions = append(conditions, "community_banned")
+	}
+	if len(conditions) == 0 {
+		return profileMatcher{}, errors.New("Profile trigger has no conditions")
+	}
+	sort.Strings(conditions)
+	matcher.conditions = strings.Join(conditions, " ")
+	return matcher, nil
+}
+
+func daysDuration(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func (m profileMatcher) Match(profile ProfileInput) *MatchResult {
+	now := time.Now()
+	if m.accountAgeMax != nil && (profile.AccountCreatedOn.IsZero() || now.Sub(profile.AccountCreatedOn) > *m.accountAgeMax) {
+		return nil
+	}
+	if m.vacBansMin != nil && profile.VACBans < *m.vacBansMin {
+		return nil
+	}
+	if m.gameBansMin != nil && profile.GameBans < *m.gameBansMin {
+		return nil
+	}
+	if m.lastBanMax != nil && (profile.LastBanOn == nil || now.Sub(*profile.LastBanOn) > *m.lastBanMax) {
+		return nil
+	}
+	if m.private && !profile.Private {
+		return nil
+	}
+	if m.communityBanned && !profile.CommunityBanned {
+		return nil
+	}
+	return &MatchResult{Origin: m.origin, MatcherType: matcherTypeProfile, Attributes: m.attributes, Pattern: m.conditions}
+}
@@ -1,19 +1,37 @@
 package rules
 
+import "time"
+
 type ruleTriggerMode string
 
-//const (
-// modeTrigMatchAny ruleTriggerMode = "match_any"
-// modeTrigMatchAll ruleTriggerMode = "match_all"
-//)
+const (
+	modeTrigMatchAny ruleTriggerMode = "match_any"
+	modeTrigMatchAll ruleTriggerMode = "match_all"
+)
 
 const (
 	LocalRuleName   = "local"
 	LocalRuleAuthor = "local"
 	urlPlayerSchema = "https://raw.githubusercontent.com/PazerOP/tf2_bot_detector/master/schemas/v3/playerlist.schema.json"
 	urlRuleSchema   = "https://raw.githubusercontent.com/PazerOP/tf2_bot_detector/master/schemas/v3/rules.schema.json"
+	// urlBDPlayerSchema and urlBDRuleSchema are the $id of the embedded schemas, which extend the v3 schemas
+	// with the fields only supported by bd
+	urlBDPlayerSchema = "https://raw.githubusercontent.com/leighmacdonald/bd/master/pkg/rules/schemas/playerlist.schema.json"
+	urlBDRuleSchema   = "https://raw.githubusercontent.com/leighmacdonald/bd/master/pkg/rules/schemas/rules.schema.json"
 )
 
+// exportSchemaURL returns the $schema a list is exported with. Lists using fields only supported by bd
+// reference the bd schema as they do not validate against the v3 schema.
+func exportSchemaURL(current string, v3URL string, bdURL string, extended bool) string {
+	if extended {
+		return bdURL
+	}
+	if current == "" {
+		return v3URL
+	}
+	return current
+}
+
 type textMatchMode string
 
 const (
@@ -28,6 +46,9 @@ const (
 type baseSchema struct {
 	Schema   string   `json:"$schema" yaml:"schema"`
 	FileInfo fileInfo `json:"file_info" yaml:"file_info"`
+	// SourceURL is the location the list was loaded from. Along with the title it identifies the list
+	// within the engine so that reloading a list replaces the existing copy. It is not part of the file format.
+	SourceURL string `json:"-" yaml:"-"`
 }
 
 type fileInfo struct {
@@ -78,15 +99,50 @@ type RuleSchema struct {
 	Rules []ruleDefinition `json:"rules" yaml:"rules"`
 }
 
+// exportCopy returns a copy of the list referencing the schema it validates against
+func (list RuleSchema) exportCopy() RuleSchema {
+	extended := false
+	for _, rule := range list.Rules {
+		if rule.extended() {
+			extended = true
+			break
+		}
+	}
+	list.Schema = exportSchemaURL(list.Schema, urlRuleSchema, urlBDRuleSchema, extended)
+	return list
+}
+
 type ruleTriggerNameMatch struct {
 	CaseSensitive bool          `json:"case_sensitive" yaml:"case_sensitive"`
 	Mode          textMatchMode `json:"mode" yaml:"mode"`
 	Patterns      []string      `json:"patterns" yaml:"patterns"`
 	Attributes    []string      `json:"attributes" yaml:"attributes"` // New
+	// Normalise matches against the text after applying NormaliseText
+	Normalise bool `json:"normalise,omitempty" yaml:"normalise"`
 }
 
 type ruleTriggerAvatarMatch struct {
-	AvatarHash string `json:"avatar_hash"`
+	AvatarHash string `json:"avatar_hash,omitempty"`
+	// PerceptualHash is the hex encoded dHash of the avatar as computed by PerceptualHash
+	PerceptualHash string `json:"perceptual_hash,omitempty" yaml:"perceptual_hash"`
+	// MaxDistance is the number of bits the perceptual hash may differ by, DefaultPerceptualDistance when unset
+	MaxDistance *int `json:"max_distance,omitempty" yaml:"max_distance"`
+}
+
+// ruleTriggerProfileMatch matches on the steam profile and ban state of a player. Every condition which is
+// set must be satisfied for the trigger to match.
+type ruleTriggerProfileMatch struct {
+	// AccountAgeMaxDays matches accounts created within the last N days
+	AccountAgeMaxDays *int `json:"account_age_max_days,omitempty" yaml:"account_age_max_days"`
+	// VACBansMin matches accounts with at least N VAC bans
+	VACBansMin *int `json:"vac_bans_min,omitempty" yaml:"vac_bans_min"`
+	// GameBansMin matches accounts with at least N game bans
+	GameBansMin *int `json:"game_bans_min,omitempty" yaml:"game_bans_min"`
+	// LastBanMaxDays matches accounts which received a ban within the last N days
+	LastBanMaxDays  *int     `json:"last_ban_max_days,omitempty" yaml:"last_ban_max_days"`
+	PrivateProfile  bool     `json:"private_profile,omitempty" yaml:"private_profile"`
+	CommunityBanned bool     `json:"community_banned,omitempty" yaml:"community_banned"`
+	Attributes      []string `json:"attributes,omitempty" yaml:"attributes"`
 }
 
 type ruleTriggerTextMatch struct {
@@ -94,6 +150,8 @@ type ruleTriggerTextMatch struct {
 	Mode          textMatchMode `json:"mode"`
 	Patterns      []string      `json:"patterns"`
 	Attributes    []string      `json:"attributes" yaml:"attributes"` // New
+	// Normalise matches against the text after applying NormaliseText
+	Normalise bool `json:"normalise,omitempty" yaml:"normalise"`
 }
 
 type ruleTriggers struct {
@@ -101,6 +159,7 @@ type ruleTriggers struct {
 	Mode              ruleTriggerMode          `json:"mode" yaml:"mode"`
 	UsernameTextMatch *ruleTriggerNameMatch    `json:"username_text_match" yaml:"username_text_match"`
 	ChatMsgTextMatch  *ruleTriggerTextMatch    `json:"chatmsg_text_match" yaml:"chat_msg_text_match"`
+	ProfileMatch      *ruleTriggerProfileMatch `json:"profile_match,omitempty" yaml:"profile_match"`
 }
 
 type ruleActions struct {
@@ -115,11 +174,43 @@ type ruleDefinition struct {
 	Triggers    ruleTriggers `json:"triggers,omitempty"`
 }
 
+// extended returns true if the rule uses any of the fields which are not part of the v3 schema
+func (rule ruleDefinition) extended() bool {
+	if len(rule.Actions.TransientMark) > 0 || rule.Triggers.ProfileMatch != nil {
+		return true
+	}
+	if rule.Triggers.UsernameTextMatch != nil && rule.Triggers.UsernameTextMatch.Normalise {
+		return true
+	}
+	if rule.Triggers.ChatMsgTextMatch != nil && rule.Triggers.ChatMsgTextMatch.Normalise {
+		return true
+	}
+	for _, avatarMatch := range append(rule.Triggers.AvatarMatch, rule.Actions.AvatarMatch...) {
+		if avatarMatch.PerceptualHash != "" {
+			return true
+		}
+	}
+	return false
+}
+
 type PlayerListSchema struct {
 	baseSchema
 	Players []playerDefinition `json:"players"`
 }
 
+// exportCopy returns a copy of the list referencing the schema it validates against
+func (list PlayerListSchema) exportCopy() PlayerListSchema {
+	extended := false
+	for _, player := range list.Players {
+		if player.ExpiresOn > 0 {
+			extended = true
+			break
+		}
+	}
+	list.Schema = exportSchemaURL(list.Schema, urlPlayerSchema, urlBDPlayerSchema, extended)
+	return list
+}
+
 type playerLastSeen struct {
 	PlayerName string `json:"player_name,omitempty"`
 	Time       int    `json:"time,omitempty"`
@@ -131,4 +222,11 @@ type playerDefinition struct {
 	SteamID    string         `json:"steamid"`
 	Proof      []string       `json:"proof,omitempty"`
 	Origin     string         `json:"origin,omitempty"` // TODO add to schema?
+	// ExpiresOn is the unix timestamp after which the entry is removed, zero never expires
+	ExpiresOn int `json:"expires_on,omitempty"`
+}
+
+// expired returns true if the entry has an expiry which has passed
+func (p playerDefinition) expired(now time.Time) bool {
+	return p.ExpiresOn > 0 && int64(p.ExpiresOn) <= now.Unix()
 }
@@ -0,0 +1,425 @@
+package rules
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textIndex is the compiled form of the non-regex text triggers across every loaded rule. Rather than
+// evaluating each trigger on its own, all the patterns for a field are matched against the input in a
+// single pass and the resulting hits are then used when evaluating the individual rules.
+//
+// The index must be rebuilt whenever the loaded rules change.
+type textIndex struct {
+	// triggerCount is the number of compiled triggers, trigger ids are assigned sequentially
+	triggerCount int
+	// ids maps the [rule][text trigger] position of a ruleMatcher to its trigger id. Triggers which are
+	// not compiled, such as regex triggers, have an id of -1.
+	ids     [][]int
+	name    fieldIndex
+	message fieldIndex
+	// normalisedName and normalisedMessage hold the triggers which match against the normalised text
+	normalisedName    fieldIndex
+	normalisedMessage fieldIndex
+}
+
+func newTextIndex(matchers []ruleMatcher) *textIndex {
+	index := textIndex{
+		ids:               make([][]int, len(matchers)),
+		name:              newFieldIndex(),
+		message:           newFieldIndex(),
+		normalisedName:    newFieldIndex(),
+		normalisedMessage: newFieldIndex(),
+	}
+	for ruleIdx, matcher := range matchers {
+		index.ids[ruleIdx] = make([]int, len(matcher.text))
+		for textIdx, textMatcher := range matcher.text {
+			name, message := &index.name, &index.message
+			if normalised, isNormalised := textMatcher.(normalisedTextMatcher); isNormalised {
+				textMatcher = normalised.TextMatcher
+				name, message = &index.normalisedName, &index.normalisedMessage
+			}
+			general, ok := textMatcher.(generalTextMatcher)
+			if !ok {
+				index.ids[ruleIdx][textIdx] = -1
+				continue
+			}
+			triggerID := index.triggerCount
+			index.triggerCount++
+			index.ids[ruleIdx][textIdx] = triggerID
+			switch general.matcherType {
+			case textMatchTypeName:
+				name.add(general, triggerID)
+			case textMatchTypeMessage:
+				message.add(general, triggerID)
+			default:
+				name.add(general, triggerID)
+				message.add(general, triggerID)
+			}
+		}
+	}
+	for _, field := range []*fieldIndex{&index.name, &index.message, &index.normalisedName, &index.normalisedMessage} {
+		field.build()
+	}
+	return &index
+}
+
+// match runs the input through the compiled patterns returning the triggers that matched
+func (index *textIndex) match(input MatchInput) *textHits {
+	textHits := textHits{hits: make([]*textHit, index.triggerCount)}
+	if input.Name != "" {
+		index.name.match(input.Name, "", &textHits)
+		textHits.matchNormalised(&index.normalisedName, input.Name)
+	}
+	if input.Message != "" {
+		index.message.match(input.Message, "", &textHits)
+		textHits.matchNormalised(&index.normalisedMessage, input.Message)
+	}
+	return &textHits
+}
+
+// patternRef identifies a single pattern of a compiled trigger
+type patternRef struct {
+	triggerID int
+	// position of the pattern within the trigger, earlier patterns take precedence as when evaluating the trigger
+	position int
+	// pattern is the pattern as given to the trigger, before lower casing
+	pattern string
+	// length is the length of the compiled pattern
+	length int
+}
+
+// textHit holds the details of the first match of a trigger, equal to the result of evaluating the trigger directly
+type textHit struct {
+	position   int
+	pattern    string
+	matched    string
+	offset     int
+	normalised string
+}
+
+// textHits holds the triggers of a textIndex that matched a single input
+type textHits struct {
+	hits []*textHit
+	// inputs counts the fields matched so far, hits from earlier fields take precedence over later ones
+	inputs int
+	// hitInput is the value of inputs when each hit was found
+	hitInput []int
+}
+
+// add records a match of the pattern unless the trigger already matched an earlier field or pattern
+func (h *textHits) add(ref patternRef, matched string, offset int, normalised string) {
+	if h.hitInput == nil {
+		h.hitInput = make([]int, len(h.hits))
+	}
+	if existing := h.hits[ref.triggerID]; existing != nil {
+		if h.hitInput[ref.triggerID] < h.inputs || existing.position < ref.position ||
+			existing.position == ref.position && existing.offset <= offset {
+			return
+		}
+	}
+	h.hits[ref.triggerID] = &textHit{
+		position:   ref.position,
+		pattern:    ref.pattern,
+		matched:    matched,
+		offset:     offset,
+		normalised: normalised,
+	}
+	h.hitInput[ref.triggerID] = h.inputs
+}
+
+// matchNormalised matches the normalised value against the field
+func (h *textHits) matchNormalised(field *fieldIndex, value string) {
+	if field.empty() {
+		return
+	}
+	normalised := NormaliseText(value)
+	field.match(normalised, normalised, h)
+}
+
+// hit returns the match of the trigger, or nil if it did not match
+func (h *textHits) hit(triggerID int) *textHit {
+	return h.hits[triggerID]
+}
+
+// fieldIndex holds the compiled patterns for a single input field
+type fieldIndex struct {
+	caseSensitive   modeIndex
+	caseInsensitive modeIndex
+}
+
+func newFieldIndex() fieldIndex {
+	return fieldIndex{caseSensitive: newModeIndex(), caseInsensitive: newModeIndex()}
+}
+
+func (f *fieldIndex) add(matcher generalTextMatcher, triggerID int) {
+	index := &f.caseSensitive
+	if !matcher.caseSensitive {
+		index = &f.caseInsensitive
+	}
+	for position, pattern := range matcher.patterns {
+		compiled := pattern
+		if !matcher.caseSensitive {
+			compiled = strings.ToLower(pattern)
+		}
+		index.add(matcher.mode, compiled, patternRef{triggerID: triggerID, position: position, pattern: pattern, length: len(compiled)})
+	}
+}
+
+func (f *fieldIndex) empty() bool {
+	return f.caseSensitive.empty() && f.caseInsensitive.empty()
+}
+
+func (f *fieldIndex) build() {
+	f.caseSensitive.contains.build()
+	f.caseInsensitive.contains.build()
+}
+
+// match records the hits of the patterns against the value, normalised is the value when it has been normalised
+func (f *fieldIndex) match(value string, normalised string, hits *textHits) {
+	hits.inputs++
+	if !f.caseSensitive.empty() {
+		f.caseSensitive.match(value, func(ref patternRef, offset int) {
+			hits.add(ref, value[offset:offset+ref.length], offset, normalised)
+		})
+	}
+	if !f.caseInsensitive.empty() {
+		lower, offsets := lowerText(value)
+		f.caseInsensitive.match(lower, func(ref patternRef, offset int) {
+			start, end := offset, offset+ref.length
+			if offsets != nil {
+				start, end = offsets[start], offsets[end]
+			}
+			hits.add(ref, value[start:end], start, normalised)
+		})
+	}
+}
+
+// lowerText returns the lower cased text, equal to strings.ToLower, along with the offset within text of the
+// character each byte of the lower cased text was produced from and a final entry for the end of the text. Lower
+// casing can change the length of characters, so offsets found in the lower cased text must be mapped back
+// through them before slicing the original text. The offsets are nil when they are unchanged, as for ascii text.
+func lowerText(text string) (string, []int) {
+	ascii := true
+	for i := 0; i < len(text) && ascii; i++ {
+		ascii = text[i] < utf8.RuneSelf
+	}
+	if ascii {
+		return strings.ToLower(text), nil
+	}
+	var builder strings.Builder
+	builder.Grow(len(text))
+	offsets := make([]int, 0, len(text)+1)
+	for offset, r := range text {
+		start := builder.Len()
+		builder.WriteRune(unicode.ToLower(r))
+		for i := start; i < builder.Len(); i++ {
+			offsets = append(offsets, offset)
+		}
+	}
+	return builder.String(), append(offsets, len(text))
+}
+
+// modeIndex holds a compiled structure for each of the supported text match modes
+type modeIndex struct {
+	count    int
+	contains *ahoCorasick
+	prefix   *trieNode
+	suffix   *trieNode
+	equal    map[string][]patternRef
+	word     map[string][]patternRef
+}
+
+func newModeIndex() modeIndex {
+	return modeIndex{
+		contains: newAhoCorasick(),
+		prefix:   newTrieNode(),
+		suffix:   newTrieNode(),
+		equal:    map[string][]patternRef{},
+		word:     map[string][]patternRef{},
+	}
+}
+
+func (m *modeIndex) empty() bool {
+	return m.count == 0
+}
+
+func (m *modeIndex) add(mode textMatchMode, pattern string, ref patternRef) {
+	switch mode {
+	case textMatchModeContains:
+		m.contains.add(pattern, ref)
+	case textMatchModeStartsWith:
+		m.prefix.add(pattern, false, ref)
+	case textMatchModeEndsWith:
+		m.suffix.add(pattern, true, ref)
+	case textMatchModeEqual:
+		m.equal[pattern] = append(m.equal[pattern], ref)
+	case textMatchModeWord:
+		m.word[pattern] = append(m.word[pattern], ref)
+	default:
+		return
+	}
+	m.count++
+}
+
+// match reports the byte offset of every pattern found in the value
+func (m *modeIndex) match(value string, report func(ref patternRef, offset int)) {
+	m.contains.match(value, report)
+	m.prefix.match(value, false, report)
+	m.suffix.match(value, true, report)
+	reportAll(m.equal[value], 0, report)
+	if len(m.word) > 0 {
+		offset := 0
+		for _, word := range strings.Split(value, " ") {
+			reportAll(m.word[word], offset, report)
+			offset += len(word) + 1
+		}
+	}
+}
+
+func reportAll(refs []patternRef, offset int, report func(ref patternRef, offset int)) {
+	for _, ref := range refs {
+		report(ref, offset)
+	}
+}
+
+// trieNode implements a byte trie used for prefix, and when inserted in reverse, suffix matching.
+type trieNode struct {
+	children map[byte]*trieNode
+	refs     []patternRef
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: map[byte]*trieNode{}}
+}
+
+func (t *trieNode) add(pattern string, reverse bool, ref patternRef) {
+	node := t
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if reverse {
+			c = pattern[len(pattern)-1-i]
+		}
+		child, found := node.children[c]
+		if !found {
+			child = newTrieNode()
+			node.children[c] = child
+		}
+		node = child
+	}
+	node.refs = append(node.refs, ref)
+}
+
+// match reports every pattern which is a prefix of the value, or a suffix when reverse is set
+func (t *trieNode) match(value string, reverse bool, report func(ref patternRef, offset int)) {
+	node := t
+	t.report(node.refs, value, reverse, report)
+	for i := 0; i < len(value) && len(node.children) > 0; i++ {
+		c := value[i]
+		if reverse {
+			c = value[len(value)-1-i]
+		}
+		child, found := node.children[c]
+		if !found {
+			return
+		}
+		node = child
+		t.report(node.refs, value, reverse, report)
+	}
+}
+
+func (t *trieNode) report(refs []patternRef, value string, reverse bool, report func(ref patternRef, offset int)) {
+	for _, ref := range refs {
+		if reverse {
+			report(ref, len(value)-ref.length)
+		} else {
+			report(ref, 0)
+		}
+	}
+}
+
+type acNode struct {
+	next map[byte]int
+	fail int
+	refs []patternRef
+}
+
+// ahoCorasick implements the Aho-Corasick automaton for matching many substrings in a single pass
+// over the input.
+type ahoCorasick struct {
+	nodes []acNode
+}
+
+func newAhoCorasick() *ahoCorasick {
+	return &ahoCorasick{nodes: []acNode{{next: map[byte]int{}}}}
+}
+
+func (a *ahoCorasick) add(pattern string, ref patternRef) {
+	state := 0
+	for i := 0; i < len(pattern); i++ {
+		next, found := a.nodes[state].next[pattern[i]]
+		if !found {
+			next = len(a.nodes)
+			a.nodes = append(a.nodes, acNode{next: map[byte]int{}})
+			a.nodes[state].next[pattern[i]] = next
+		}
+		state = next
+	}
+	a.nodes[state].refs = append(a.nodes[state].refs, ref)
+}
+
+// build computes the failure links, must be called after all patterns are added
+func (a *ahoCorasick) build() {
+	var queue []int
+	for _, child := range a.nodes[0].next {
+		a.nodes[child].fail = 0
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for c, child := range a.nodes[state].next {
+			fail := a.nodes[state].fail
+			for {
+				if next, found := a.nodes[fail].next[c]; found {
+					fail = next
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = a.nodes[fail].fail
+			}
+			a.nodes[child].fail = fail
+			// Inherit the matches of the longest proper suffix so that a single lookup per
+			// position is enough.
+			a.nodes[child].refs = append(a.nodes[child].refs, a.nodes[fail].refs...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// match reports every occurrence of the patterns in the value, in order of where they end
+func (a *ahoCorasick) match(value string, report func(ref patternRef, offset int)) {
+	if len(a.nodes) == 1 && len(a.nodes[0].refs) == 0 {
+		return
+	}
+	state := 0
+	reportAll(a.nodes[0].refs, 0, report)
+	for i := 0; i < len(value); i++ {
+		for {
+			if next, found := a.nodes[state].next[value[i]]; found {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = a.nodes[state].fail
+		}
+		for _, ref := range a.nodes[state].refs {
+			report(ref, i+1-ref.length)
+		}
+	}
+}
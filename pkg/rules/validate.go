@@ -0,0 +1,189 @@
+package rules
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+var (
+	playerListSchema = mustCompileSchema("schemas/playerlist.schema.json")
+	ruleListSchema   = mustCompileSchema("schemas/rules.schema.json")
+)
+
+// maxSchemaErrorsShown limits how many errors are included in the error message of SchemaErrors
+const maxSchemaErrorsShown = 10
+
+// SchemaError describes a single location within a list that does not conform to the list schema
+type SchemaError struct {
+	// Path is the JSON pointer of the invalid value, eg: /players/3/steamid
+	Path    string
+	Message string
+}
+
+func (e SchemaError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// SchemaErrors is returned when a list fails validation against its schema
+type SchemaErrors []SchemaError
+
+func (e SchemaErrors) Error() string {
+	var msgs []string
+	for i, schemaErr := range e {
+		if i == maxSchemaErrorsShown {
+			msgs = append(msgs, fmt.Sprintf("and %d more", len(e)-maxSchemaErrorsShown))
+			break
+		}
+		msgs = append(msgs, schemaErr.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ParsePlayerList decodes the player list and validates it against the player list schema. When lenient
+// is set, players which fail validation are dropped instead of rejecting the entire list. The number
+// of dropped players is returned.
+func ParsePlayerList(body []byte, lenient bool) (PlayerListSchema, int, error) {
+	var list PlayerListSchema
+	dropped, errParse := parseList(playerListSchema, body, lenient, "players", &list, func(player map[string]interface{}) {
+		// Older lists use unquoted integer steam ids which do not fit into our string field
+		if steamID, ok := player["steamid"].(json.Number); ok {
+			player["steamid"] = steamID.String()
+		}
+	})
+	if errParse != nil {
+		return PlayerListSchema{}, 0, errParse
+	}
+	return list, dropped, nil
+}
+
+// ParseRuleList decodes the rules list and validates it against the rules schema. When lenient is set,
+// rules which fail validation are dropped instead of rejecting the entire list. The number of dropped
+// rules is returned.
+func ParseRuleList(body []byte, lenient bool) (RuleSchema, int, error) {
+	var list RuleSchema
+	dropped, errParse := parseList(ruleListSchema, body, lenient, "rules", &list, nil)
+	if errParse != nil {
+		return RuleSchema{}, 0, errParse
+	}
+	return list, dropped, nil
+}
+
+func parseList(schema *jsonschema.Schema, body []byte, lenient bool, entriesKey string, receiver any, normalise func(entry map[string]interface{})) (int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document interface{}
+	if errDecode := decoder.Decode(&document); errDecode != nil {
+		return 0, errors.Wrap(errDecode, "Failed to decode list")
+	}
+	dropped := 0
+	if errValidate := validateList(schema, document); errValidate != nil {
+		var schemaErrs SchemaErrors
+		if !lenient || !errors.As(errValidate, &schemaErrs) {
+			return 0, errValidate
+		}
+		invalid, onlyEntries := invalidEntries(schemaErrs, "/"+entriesKey+"/")
+		if !onlyEntries {
+			// Errors outside the entries can not be fixed by dropping them
+			return 0, schemaErrs
+		}
+		root := document.(map[string]interface{})
+		var valid []interface{}
+		for idx, entry := range root[entriesKey].([]interface{}) {
+			if invalid[idx] {
+				continue
+			}
+			valid = append(valid, entry)
+		}
+		dropped = len(invalid)
+		root[entriesKey] = valid
+	}
+	root, isObject := document.(map[string]interface{})
+	if isObject && normalise != nil {
+		entries, _ := root[entriesKey].([]interface{})
+		for _, entry := range entries {
+			if entryMap, ok := entry.(map[string]interface{}); ok {
+				normalise(entryMap)
+			}
+		}
+	}
+	normalised, errEncode := json.Marshal(document)
+	if errEncode != nil {
+		return 0, errors.Wrap(errEncode, "Failed to encode list")
+	}
+	if errDecode := json.Unmarshal(normalised, receiver); errDecode != nil {
+		return 0, errors.Wrap(errDecode, "Failed to decode list")
+	}
+	return dropped, nil
+}
+
+// invalidEntries returns the set of entry indexes which have errors. onlyEntries is false if any of the
+// errors are not within an entry.
+func invalidEntries(schemaErrs SchemaErrors, prefix string) (map[int]bool, bool) {
+	invalid := map[int]bool{}
+	for _, schemaErr := range schemaErrs {
+		if !strings.HasPrefix(schemaErr.Path, prefix) {
+			return nil, false
+		}
+		idx, errIdx := strconv.Atoi(strings.SplitN(schemaErr.Path[len(prefix):], "/", 2)[0])
+		if errIdx != nil {
+			return nil, false
+		}
+		invalid[idx] = true
+	}
+	return invalid, true
+}
+
+// mustCompileSchema compiles an embedded list schema. References are resolved within the schema itself, so
+// nothing is ever fetched.
+func mustCompileSchema(name string) *jsonschema.Schema {
+	body, errRead := schemaFS.ReadFile(name)
+	if errRead != nil {
+		panic(errRead)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, errors.Errorf("Schema is not embedded: %s", url)
+	}
+	if errAdd := compiler.AddResource(name, bytes.NewReader(body)); errAdd != nil {
+		panic(errors.Wrapf(errAdd, "Invalid schema: %s", name))
+	}
+	return compiler.MustCompile(name)
+}
+
+// validateList validates the decoded list, returning the failed constraints as SchemaErrors
+func validateList(schema *jsonschema.Schema, document interface{}) error {
+	errValidate := schema.Validate(document)
+	if errValidate == nil {
+		return nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(errValidate, &validationErr) {
+		return errors.Wrap(errValidate, "Failed to validate list")
+	}
+	var schemaErrs SchemaErrors
+	var collect func(validationErr *jsonschema.ValidationError)
+	collect = func(validationErr *jsonschema.ValidationError) {
+		if len(validationErr.Causes) == 0 {
+			schemaErrs = append(schemaErrs, SchemaError{Path: validationErr.InstanceLocation, Message: validationErr.Message})
+			return
+		}
+		for _, cause := range validationErr.Causes {
+			collect(cause)
+		}
+	}
+	collect(validationErr)
+	return schemaErrs
+}
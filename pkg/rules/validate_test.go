@@ -0,0 +1,123 @@
+package rules
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const testPlayerList = `{
+    "$schema": "https://raw.githubusercontent.com/PazerOP/tf2_bot_detector/master/schemas/v3/playerlist.schema.json",
+    "file_info": {"authors": ["test"], "title": "test list"},
+    "players": [
+        {"attributes": ["cheater"], "steamid": "76561197961279983", "last_seen": {"time": 1677390631}},
+        {"attributes": ["bot"], "steamid": 76561198084134025},
+        {"attributes": ["bot"], "steamid": "[U:1:1014255]"},
+        {"attributes": [], "steamid": "76561197961279984"},
+        {"attributes": ["bot"], "steamid": "not a steamid"}
+    ]
+}`
+
+func TestParsePlayerList(t *testing.T) {
+	_, _, errStrict := ParsePlayerList([]byte(testPlayerList), false)
+	var schemaErrs SchemaErrors
+	require.ErrorAs(t, errStrict, &schemaErrs)
+	// The steam id fails both of its allowed forms
+	require.Equal(t, 3, len(schemaErrs))
+	require.Equal(t, "/players/3/attributes", schemaErrs[0].Path)
+	require.Equal(t, "/players/4/steamid", schemaErrs[1].Path)
+	require.Equal(t, "/players/4/steamid", schemaErrs[2].Path)
+
+	list, dropped, errLenient := ParsePlayerList([]byte(testPlayerList), true)
+	require.NoError(t, errLenient)
+	require.Equal(t, 2, dropped)
+	require.Equal(t, "test list", list.FileInfo.Title)
+	require.Equal(t, 3, len(list.Players))
+	require.Equal(t, "76561198084134025", list.Players[1].SteamID)
+
+	re, _ := New(nil, nil, nil)
+	count, errImport := re.ImportPlayers(&list)
+	require.NoError(t, errImport)
+	require.Equal(t, 3, count)
+
+	// Errors outside the players can not be dropped
+	_, _, errMissing := ParsePlayerList([]byte(`{"file_info": {"title": 1}}`), true)
+	require.ErrorAs(t, errMissing, &schemaErrs)
+	require.Equal(t, SchemaErrors{
+		{Path: "", Message: "missing properties: 'players'"},
+		{Path: "/file_info/title", Message: "expected string, but got number"},
+	}, schemaErrs)
+	require.Equal(t, "missing properties: 'players'; /file_info/title: expected string, but got number", schemaErrs.Error())
+}
+
+func TestParseRuleList(t *testing.T) {
+	body := []byte(`{
+    "file_info": {"title": "test rules"},
+    "rules": [
+        {"description": "valid", "triggers": {"mode": "match_all", "username_text_match": {"mode": "contains", "patterns": ["bot"]}}},
+        {"description": "bad mode", "triggers": {"username_text_match": {"mode": "fuzzy", "patterns": ["bot"]}}},
+        {"description": "bad hash", "triggers": {"avatar_match": [{"avatar_hash": "abc"}]}},
+        {"triggers": {}},
+        {"description": "perceptual", "triggers": {"avatar_match": [{"perceptual_hash": "00ff00ff00ff00ff", "max_distance": 8}]}},
+        {"description": "bad distance", "triggers": {"avatar_match": [{"perceptual_hash": "00ff00ff00ff00ff", "max_distance": 65}]}},
+        {"description": "no hash", "triggers": {"avatar_match": [{"max_distance": 4}]}}
+    ]
+}`)
+	_, _, errStrict := ParseRuleList(body, false)
+	var schemaErrs SchemaErrors
+	require.ErrorAs(t, errStrict, &schemaErrs)
+	require.Equal(t, []string{
+		"/rules/1/triggers/username_text_match/mode",
+		"/rules/2/triggers/avatar_match/0/avatar_hash",
+		"/rules/3",
+		"/rules/5/triggers/avatar_match/0/max_distance",
+		"/rules/6/triggers/avatar_match/0",
+	}, []string{schemaErrs[0].Path, schemaErrs[1].Path, schemaErrs[2].Path, schemaErrs[3].Path, schemaErrs[4].Path})
+
+	list, dropped, errLenient := ParseRuleList(body, true)
+	require.NoError(t, errLenient)
+	require.Equal(t, 5, dropped)
+	require.Equal(t, 2, len(list.Rules))
+	require.Equal(t, "valid", list.Rules[0].Description)
+	require.Equal(t, "perceptual", list.Rules[1].Description)
+	require.Equal(t, 8, *list.Rules[1].Triggers.AvatarMatch[0].MaxDistance)
+}
+
+func TestParseExportedLists(t *testing.T) {
+	re, _ := New(nil, nil, nil)
+	require.NoError(t, re.Mark(MarkOpts{SteamID: 76561197961279983, Attributes: []string{"cheater"}, Name: "test"}))
+	players := bytes.NewBuffer(nil)
+	require.NoError(t, re.ExportPlayers(LocalRuleName, players))
+	playerList, _, errPlayers := ParsePlayerList(players.Bytes(), false)
+	require.NoError(t, errPlayers)
+	require.Equal(t, urlPlayerSchema, playerList.Schema)
+
+	// Lists using fields only supported by bd reference the bd schema
+	require.NoError(t, re.Mark(MarkOpts{SteamID: 76561197961279984, Attributes: []string{"cheater"}, Name: "expiring",
+		ExpiresOn: time.Now().Add(time.Hour)}))
+	players.Reset()
+	require.NoError(t, re.ExportPlayers(LocalRuleName, players))
+	playerList, _, errPlayers = ParsePlayerList(players.Bytes(), false)
+	require.NoError(t, errPlayers)
+	require.Equal(t, urlBDPlayerSchema, playerList.Schema)
+
+	rulesList := genTestRules()
+	rulesList.FileInfo.Title = "exported"
+	_, errImport := re.ImportRules(&rulesList)
+	require.NoError(t, errImport)
+	rules := bytes.NewBuffer(nil)
+	require.NoError(t, re.ExportRules("exported", rules))
+	ruleList, _, errRules := ParseRuleList(rules.Bytes(), false)
+	require.NoError(t, errRules)
+	require.Equal(t, urlRuleSchema, ruleList.Schema)
+
+	rulesList.Rules[0].Triggers.UsernameTextMatch.Normalise = true
+	_, errImport = re.ImportRules(&rulesList)
+	require.NoError(t, errImport)
+	rules.Reset()
+	require.NoError(t, re.ExportRules("exported", rules))
+	ruleList, _, errRules = ParseRuleList(rules.Bytes(), false)
+	require.NoError(t, errRules)
+	require.Equal(t, urlBDRuleSchema, ruleList.Schema)
+}
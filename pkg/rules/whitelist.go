@@ -0,0 +1,106 @@
+package rules
+
+import (
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/pkg/errors"
+	"io"
+)
+
+const (
+	// LocalWhitelistName is the title of the local whitelist
+	LocalWhitelistName = "whitelist"
+	// whitelistAttribute is the attribute given to entries added to the local whitelist, as the player list
+	// format requires every entry to have at least one attribute
+	whitelistAttribute   = "whitelist"
+	matcherTypeWhitelist = "whitelist"
+)
+
+// NewWhitelistSchema creates a local whitelist. Whitelists use the same format as player lists so that they
+// can be shared and subscribed to in the same way.
+func NewWhitelistSchema(players ...playerDefinition) PlayerListSchema {
+	list := NewPlayerListSchema(players...)
+	list.FileInfo.Title = LocalWhitelistName
+	list.FileInfo.Description = "local whitelist"
+	return list
+}
+
+// ImportWhitelist loads the provided whitelist. Every player on the list is whitelisted regardless of the
+// attributes of their entry. Importing a whitelist which is already loaded replaces the existing list and
+// its matchers. Entries which have expired are removed from the list.
+func (e *Engine) ImportWhitelist(list *PlayerListSchema) (int, error) {
+	matchers, players, errMatchers := newPlayerListMatchers(list)
+	if errMatchers != nil {
+		return 0, errMatchers
+	}
+	e.Lock()
+	e.whitelists = replacePlayerList(e.whitelists, e.matchersWhitelist, list, players, matchers)
+	e.Unlock()
+	return len(matchers), nil
+}
+
+// Whitelist adds the player to the local whitelist
+func (e *Engine) Whitelist(steamID steamid.SID64, name string) error {
+	e.Lock()
+	defer e.Unlock()
+	local := e.whitelists[0]
+	for _, known := range local.Players {
+		if known.SteamID == steamID.String() {
+			return ErrDuplicateSteamID
+		}
+	}
+	definition := newMarkDefinition(MarkOpts{SteamID: steamID, Attributes: []string{whitelistAttribute}, Name: name})
+	local.Players = append(local.Players, definition)
+	matcher := newSteamIDMatcher(local.FileInfo.Title, steamID, definition.Attributes)
+	matcher.source = local.SourceURL
+	matcher.lastSeen = definition.LastSeen
+	e.matchersWhitelist.add(matcher)
+	return nil
+}
+
+// Unwhitelist removes the player from the local whitelist. Players whitelisted by other lists remain whitelisted.
+func (e *Engine) Unwhitelist(steamID steamid.SID64) bool {
+	e.Lock()
+	defer e.Unlock()
+	local := e.whitelists[0]
+	found := false
+	players := []playerDefinition{}
+	for _, known := range local.Players {
+		if known.SteamID == steamID.String() {
+			found = true
+			continue
+		}
+		players = append(players, known)
+	}
+	local.Players = players
+	e.matchersWhitelist.removeList(steamID, local.FileInfo.Title, local.SourceURL)
+	return found
+}
+
+// MatchWhitelist returns the whitelist entry of the player, or nil if they are not whitelisted. The origin
+// of the result is the whitelist the player is on, preferring the local whitelist.
+func (e *Engine) MatchWhitelist(steamID steamid.SID64) *MatchResult {
+	e.RLock()
+	defer e.RUnlock()
+	var whitelisted *MatchResult
+	for _, match := range e.matchersWhitelist.match(steamID) {
+		if whitelisted == nil || match.Origin == e.whitelists[0].FileInfo.Title {
+			whitelisted = match
+		}
+	}
+	if whitelisted != nil {
+		whitelisted.MatcherType = matcherTypeWhitelist
+	}
+	return whitelisted
+}
+
+// ExportWhitelist writes the json encoded whitelist matching the listName provided to the io.Writer
+func (e *Engine) ExportWhitelist(listName string, w io.Writer) error {
+	e.RLock()
+	defer e.RUnlock()
+	for _, list := range e.whitelists {
+		if listName == list.FileInfo.Title {
+			return newJSONPrettyEncoder(w).Encode(list.exportCopy())
+		}
+	}
+	return errors.Errorf("Unknown whitelist: %s", listName)
+}